@@ -1,7 +1,253 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/maximegorov13/chat-app/id/pkg/lastseen"
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+	"github.com/maximegorov13/chat-app/id/pkg/tokencheck"
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+	"github.com/maximegorov13/chat-app/id/pkg/verifier"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	chathttp "github.com/maximegorov13/chat-app/chat/internal/chat/delivery/http"
+	chatpg "github.com/maximegorov13/chat-app/chat/internal/chat/repository/pg"
+	chatservice "github.com/maximegorov13/chat-app/chat/internal/chat/service"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	memorybus "github.com/maximegorov13/chat-app/chat/internal/event/bus/memory"
+	redisbus "github.com/maximegorov13/chat-app/chat/internal/event/bus/redis"
+	messagehttp "github.com/maximegorov13/chat-app/chat/internal/message/delivery/http"
+	messagepg "github.com/maximegorov13/chat-app/chat/internal/message/repository/pg"
+	messageservice "github.com/maximegorov13/chat-app/chat/internal/message/service"
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+	"github.com/maximegorov13/chat-app/chat/internal/middleware"
+	presencehttp "github.com/maximegorov13/chat-app/chat/internal/presence/delivery/http"
+	presenceredis "github.com/maximegorov13/chat-app/chat/internal/presence/repository/redis"
+	presenceservice "github.com/maximegorov13/chat-app/chat/internal/presence/service"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+	"github.com/maximegorov13/chat-app/chat/internal/revocation"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+	"github.com/maximegorov13/chat-app/chat/internal/ws"
+	wshttp "github.com/maximegorov13/chat-app/chat/internal/ws/delivery/http"
+)
+
+// serviceName identifies the service in traces.
+const serviceName = "chat"
 
 func main() {
-	fmt.Println("Chat Service")
+	conf, err := configs.Load()
+	if err != nil {
+		fatal("Error loading config", err)
+	}
+
+	appLogger, err := logger.New(os.Stdout, conf.Log.Level, conf.Log.Format)
+	if err != nil {
+		fatal("Error creating logger", err)
+	}
+	slog.SetDefault(appLogger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: serviceName,
+		Exporter:    conf.Tracing.Exporter,
+		FilePath:    conf.Tracing.FilePath,
+		OTLPURL:     conf.Tracing.OTLPURL,
+		SampleRatio: conf.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Error setting up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error when flushing traces", "error", err)
+		}
+	}()
+
+	pgClient, err := pg.NewPostgres(conf)
+	if err != nil {
+		fatal("Error connecting to Postgres", err)
+	}
+	defer func() {
+		if err := pgClient.Sqlx.Close(); err != nil {
+			slog.Error("Error when closing the Postgres connection", "error", err)
+		}
+	}()
+
+	redisClient, err := redis.NewRedis(context.Background(), conf)
+	if err != nil {
+		fatal("Error connecting to Redis", err)
+	}
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			slog.Error("Error when closing the Redis connection", "error", err)
+		}
+	}()
+
+	tokenVerifier := verifier.NewVerifier(verifier.Config{
+		JWKSURL:  conf.IDService.Url + "/.well-known/jwks.json",
+		Issuer:   conf.Auth.Issuer,
+		Audience: conf.Auth.Audience,
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			res.Error(w, middleware.AuthError(err))
+		},
+	})
+
+	// Repositories
+	chatRepo := chatpg.NewChatRepository(pgClient)
+	messageRepo := messagepg.NewMessageRepository(pgClient)
+	presenceRepo := presenceredis.NewPresenceRepository(redisClient)
+
+	bus := newEventBus(conf, redisClient)
+	defer func() {
+		if err := bus.Close(); err != nil {
+			slog.Error("Error when closing the event bus", "error", err)
+		}
+	}()
+
+	hub := ws.NewHub(ws.HubDeps{
+		ChatRepo: chatRepo,
+		Bus:      bus,
+	})
+
+	// Services
+	chatService := chatservice.NewChatService(chatservice.ChatServiceDeps{
+		ChatRepo:  chatRepo,
+		Publisher: hub,
+	})
+	messageService := messageservice.NewMessageService(messageservice.MessageServiceDeps{
+		MessageRepo: messageRepo,
+		ChatRepo:    chatRepo,
+		Publisher:   hub,
+	})
+	presenceService := presenceservice.NewPresenceService(presenceservice.PresenceServiceDeps{
+		PresenceRepo: presenceRepo,
+		ChatRepo:     chatRepo,
+		Publisher:    hub,
+		LastSeen: lastseen.NewClient(lastseen.Config{
+			ServiceURL:   conf.IDService.Url,
+			ServiceToken: conf.IDService.ServiceToken,
+		}),
+	})
+
+	router := http.NewServeMux()
+
+	authDeps := middleware.AuthDeps{
+		Conf:     conf,
+		Verifier: tokenVerifier,
+		Revocation: revocation.NewChecker(revocation.CheckerDeps{
+			TokenCheck: tokencheck.NewClient(tokencheck.Config{
+				ServiceURL: conf.IDService.Url,
+			}),
+			CacheTTL: conf.Auth.RevocationCacheTTL,
+		}),
+	}
+	rateLimiter := newRateLimiter(conf, redisClient)
+	messageRateLimit := middleware.RateLimitDeps{
+		Limiter: rateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "messages",
+			Limit: conf.RateLimit.Messages,
+		},
+	}
+	typingRateLimit := middleware.RateLimitDeps{
+		Limiter: rateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "typing",
+			Limit: conf.RateLimit.Typing,
+		},
+	}
+
+	// Handlers
+	chathttp.NewChatHandler(router, chathttp.ChatHandlerDeps{
+		Conf:        conf,
+		ChatService: chatService,
+		AuthDeps:    authDeps,
+	})
+	messagehttp.NewMessageHandler(router, messagehttp.MessageHandlerDeps{
+		Conf:             conf,
+		MessageService:   messageService,
+		AuthDeps:         authDeps,
+		MessageRateLimit: messageRateLimit,
+	})
+	presencehttp.NewPresenceHandler(router, presencehttp.PresenceHandlerDeps{
+		Conf:            conf,
+		PresenceService: presenceService,
+		AuthDeps:        authDeps,
+	})
+	wshttp.NewWSHandler(router, wshttp.WSHandlerDeps{
+		Conf:             conf,
+		Hub:              hub,
+		MessageService:   messageService,
+		PresenceService:  presenceService,
+		AuthDeps:         authDeps,
+		MessageRateLimit: messageRateLimit,
+		TypingRateLimit:  typingRateLimit,
+	})
+
+	router.Handle("GET /metrics", promhttp.Handler())
+
+	handler := middleware.ClientIP(middleware.AccessLog(tracing.Middleware(metrics.HTTP.Middleware(router))), middleware.ClientIPDeps{
+		TrustedProxies: conf.Server.TrustedProxies,
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%v", conf.Server.Port),
+		Handler: middleware.RequestID(handler),
+	}
+
+	go func() {
+		slog.Info("Starting server", "port", conf.Server.Port)
+		if err = server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fatal("HTTP server error", err)
+		}
+		slog.Info("Stopped serving new connections")
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownRelease()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fatal("HTTP shutdown error", err)
+	}
+	hub.Close()
+	slog.Info("Graceful shutdown complete")
+}
+
+func newEventBus(conf *configs.Config, redisClient *redis.Redis) event.Bus {
+	if conf.EventBus.Driver == configs.EventBusDriverMemory {
+		return memorybus.NewBus(nil)
+	}
+
+	return redisbus.NewBus(context.Background(), redisClient)
+}
+
+func newRateLimiter(conf *configs.Config, redisClient *redis.Redis) ratelimit.Limiter {
+	if conf.RateLimit.Driver == configs.RateLimitDriverMemory {
+		return ratelimit.NewMemoryLimiter()
+	}
+
+	return ratelimit.NewRedisLimiter(redisClient)
+}
+
+// fatal logs err and exits. Deferred functions are not run.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,332 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/joho/godotenv"
+
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+)
+
+type Config struct {
+	Log       LogConfig
+	Tracing   TracingConfig
+	Server    ServerConfig
+	Postgres  PostgresConfig
+	Redis     RedisConfig
+	Auth      AuthConfig
+	IDService IDServiceConfig
+	EventBus  EventBusConfig
+	RateLimit RateLimitConfig
+}
+
+func (c Config) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Log),
+		validation.Field(&c.Tracing),
+		validation.Field(&c.Server),
+		validation.Field(&c.Postgres),
+		validation.Field(&c.Redis),
+		validation.Field(&c.Auth),
+		validation.Field(&c.IDService),
+		validation.Field(&c.EventBus),
+		validation.Field(&c.RateLimit),
+	)
+}
+
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string
+}
+
+func (l LogConfig) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.Level, validation.Required, validation.In("debug", "info", "warn", "error")),
+		validation.Field(&l.Format, validation.Required, validation.In(logger.FormatText, logger.FormatJSON)),
+	)
+}
+
+type TracingConfig struct {
+	Exporter    string  // none, stdout or otlp
+	FilePath    string  // File the stdout exporter appends to, stdout if empty
+	OTLPURL     string  // OTLP/HTTP endpoint of the collector
+	SampleRatio float64 // Share of new traces that are sampled, from 0 to 1
+}
+
+func (t TracingConfig) Validate() error {
+	err := validation.ValidateStruct(&t,
+		validation.Field(&t.Exporter, validation.Required, validation.In(tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)),
+		validation.Field(&t.SampleRatio, validation.Min(0.0), validation.Max(1.0)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if t.Exporter == tracing.ExporterOTLP {
+		return validation.ValidateStruct(&t,
+			validation.Field(&t.OTLPURL, validation.Required, is.URL),
+		)
+	}
+
+	return nil
+}
+
+type ServerConfig struct {
+	Port           string
+	TrustedProxies []string // IPs or CIDR ranges allowed to set X-Forwarded-For
+}
+
+func (s ServerConfig) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Port, validation.Required, is.Port),
+		validation.Field(&s.TrustedProxies, validation.Each(validation.By(proxyAddress))),
+	)
+}
+
+func proxyAddress(value interface{}) error {
+	proxy, _ := value.(string)
+	if _, err := netip.ParsePrefix(proxy); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddr(proxy); err == nil {
+		return nil
+	}
+
+	return errors.New("must be an IP address or a CIDR range")
+}
+
+type PostgresConfig struct {
+	Url string
+}
+
+func (p PostgresConfig) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Url, validation.Required, is.URL),
+	)
+}
+
+type RedisConfig struct {
+	Url string
+}
+
+func (r RedisConfig) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Url, validation.Required, is.URL),
+	)
+}
+
+// AuthConfig holds the expected issuer and audience of access tokens. The
+// signing keys are fetched from the ID service.
+type AuthConfig struct {
+	Issuer   string
+	Audience string
+	// RevocationCacheTTL is how long the ID service answer on whether a token
+	// was revoked is reused.
+	RevocationCacheTTL time.Duration
+}
+
+func (a AuthConfig) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Issuer, validation.Required),
+		validation.Field(&a.Audience, validation.Required),
+		validation.Field(&a.RevocationCacheTTL, validation.Required, validation.Max(5*time.Minute)),
+	)
+}
+
+type IDServiceConfig struct {
+	Url          string
+	ServiceToken string // Token of the ID service internal API
+}
+
+func (i IDServiceConfig) Validate() error {
+	return validation.ValidateStruct(&i,
+		validation.Field(&i.Url, validation.Required, is.URL),
+		validation.Field(&i.ServiceToken, validation.Required),
+	)
+}
+
+const (
+	EventBusDriverMemory = "memory"
+	EventBusDriverRedis  = "redis"
+)
+
+// EventBusConfig selects how chat events reach other instances. The memory
+// driver only works for a single instance.
+type EventBusConfig struct {
+	Driver string
+}
+
+func (e EventBusConfig) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.Driver, validation.Required, validation.In(EventBusDriverMemory, EventBusDriverRedis)),
+	)
+}
+
+const (
+	RateLimitDriverMemory = "memory"
+	RateLimitDriverRedis  = "redis"
+)
+
+// RateLimitConfig holds the request limits per user. Messages limits sending
+// over both HTTP and WebSocket, Typing limits typing frames. The memory
+// driver keeps limits per instance.
+type RateLimitConfig struct {
+	Driver   string
+	Messages ratelimit.Limit
+	Typing   ratelimit.Limit
+}
+
+func (r RateLimitConfig) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Driver, validation.Required, validation.In(RateLimitDriverMemory, RateLimitDriverRedis)),
+		validation.Field(&r.Messages, validation.By(limitRequired)),
+		validation.Field(&r.Typing, validation.By(limitRequired)),
+	)
+}
+
+func limitRequired(value interface{}) error {
+	if limit, _ := value.(ratelimit.Limit); limit.Burst == 0 {
+		return errors.New("cannot be blank")
+	}
+
+	return nil
+}
+
+func Load(envPath ...string) (*Config, error) {
+	if len(envPath) > 0 {
+		if err := godotenv.Load(envPath[0]); err != nil {
+			return nil, fmt.Errorf("error loading .env file at %s: %w", envPath[0], err)
+		}
+	} else {
+		if err := godotenv.Load(); err != nil {
+			return nil, fmt.Errorf("error loading .env file: %w", err)
+		}
+	}
+
+	traceSampleRatio, err := getEnvFloat("TRACING_SAMPLE_RATIO")
+	if err != nil {
+		return nil, err
+	}
+	messagesRateLimit, err := getEnvLimit("RATE_LIMIT_MESSAGES")
+	if err != nil {
+		return nil, err
+	}
+	typingRateLimit, err := getEnvLimit("RATE_LIMIT_TYPING")
+	if err != nil {
+		return nil, err
+	}
+	revocationCacheTTL, err := getEnvDuration("REVOCATION_CACHE_TTL")
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &Config{
+		Log: LogConfig{
+			Level:  os.Getenv("LOG_LEVEL"),
+			Format: os.Getenv("LOG_FORMAT"),
+		},
+		Tracing: TracingConfig{
+			Exporter:    os.Getenv("TRACING_EXPORTER"),
+			FilePath:    os.Getenv("TRACING_FILE_PATH"),
+			OTLPURL:     os.Getenv("TRACING_OTLP_URL"),
+			SampleRatio: traceSampleRatio,
+		},
+		Server: ServerConfig{
+			Port:           os.Getenv("PORT"),
+			TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+		},
+		Postgres: PostgresConfig{
+			Url: os.Getenv("POSTGRES_URL"),
+		},
+		Redis: RedisConfig{
+			Url: os.Getenv("REDIS_URL"),
+		},
+		Auth: AuthConfig{
+			Issuer:             os.Getenv("JWT_ISSUER"),
+			Audience:           os.Getenv("JWT_AUDIENCE"),
+			RevocationCacheTTL: revocationCacheTTL,
+		},
+		IDService: IDServiceConfig{
+			Url:          os.Getenv("ID_SERVICE_URL"),
+			ServiceToken: os.Getenv("ID_SERVICE_TOKEN"),
+		},
+		EventBus: EventBusConfig{
+			Driver: os.Getenv("EVENT_BUS_DRIVER"),
+		},
+		RateLimit: RateLimitConfig{
+			Driver:   os.Getenv("RATE_LIMIT_DRIVER"),
+			Messages: messagesRateLimit,
+			Typing:   typingRateLimit,
+		},
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("error validating config: %w", err)
+	}
+
+	return conf, nil
+}
+
+func getEnvDuration(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+// getEnvList splits a comma-separated value, skipping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func getEnvFloat(key string) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+func getEnvLimit(key string) (ratelimit.Limit, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return ratelimit.Limit{}, nil
+	}
+
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return limit, nil
+}
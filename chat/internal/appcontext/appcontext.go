@@ -0,0 +1,35 @@
+package appcontext
+
+import "context"
+
+type contextKey string
+
+const (
+	contextUserIDKey   contextKey = "ContextUserIDKey"
+	contextClientIPKey contextKey = "ContextClientIPKey"
+)
+
+func SetContextUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, contextUserIDKey, userID)
+}
+
+func GetContextUserID(ctx context.Context) int64 {
+	return ctx.Value(contextUserIDKey).(int64)
+}
+
+// LookupContextUserID returns the user ID of authenticated requests and false
+// for anonymous ones.
+func LookupContextUserID(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(contextUserIDKey).(int64)
+	return userID, ok
+}
+
+func SetContextClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextClientIPKey, ip)
+}
+
+// LookupContextClientIP returns the client IP resolved by middleware.ClientIP.
+func LookupContextClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(contextClientIPKey).(string)
+	return ip, ok
+}
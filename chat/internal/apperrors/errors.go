@@ -0,0 +1,65 @@
+package apperrors
+
+import (
+	"net/http"
+	"time"
+)
+
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewError(code int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+var (
+	ErrBadRequest         = NewError(http.StatusBadRequest, "bad request")
+	ErrInvalidRequestBody = NewError(http.StatusBadRequest, "invalid request body")
+	ErrValidationFailed   = NewError(http.StatusBadRequest, "validation failed")
+	ErrInvalidCursor      = NewError(http.StatusBadRequest, "invalid cursor")
+	ErrInvalidReply       = NewError(http.StatusBadRequest, "quoted message is not in the same thread")
+
+	ErrUnauthorized = NewError(http.StatusUnauthorized, "unauthorized")
+
+	ErrForbidden = NewError(http.StatusForbidden, "forbidden")
+
+	ErrNotFound = NewError(http.StatusNotFound, "not found")
+
+	ErrChatExists   = NewError(http.StatusConflict, "chat already exists")
+	ErrMemberExists = NewError(http.StatusConflict, "user is already a chat member")
+	ErrOwnerLeave   = NewError(http.StatusConflict, "the owner cannot leave the chat")
+
+	ErrTooManyRequests = NewError(http.StatusTooManyRequests, "too many requests")
+
+	ErrInternalServerError = NewError(http.StatusInternalServerError, "internal server error")
+)
+
+// RetryError is an Error the client may retry after RetryAfter.
+type RetryError struct {
+	Err        *Error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func WithRetryAfter(err *Error, retryAfter time.Duration) *RetryError {
+	return &RetryError{
+		Err:        err,
+		RetryAfter: retryAfter,
+	}
+}
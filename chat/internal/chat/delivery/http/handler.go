@@ -0,0 +1,175 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/appcontext"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/middleware"
+	"github.com/maximegorov13/chat-app/chat/internal/req"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+type ChatHandlerDeps struct {
+	Conf        *configs.Config
+	ChatService chat.ChatService
+	AuthDeps    middleware.AuthDeps
+}
+
+type ChatHandler struct {
+	conf        *configs.Config
+	chatService chat.ChatService
+}
+
+func NewChatHandler(router *http.ServeMux, deps ChatHandlerDeps) {
+	handler := &ChatHandler{
+		conf:        deps.Conf,
+		chatService: deps.ChatService,
+	}
+
+	router.Handle("POST /api/chats", middleware.Auth(handler.CreateChat(), deps.AuthDeps))
+	router.Handle("GET /api/chats", middleware.Auth(handler.ListChats(), deps.AuthDeps))
+	router.Handle("GET /api/chats/{chatID}", middleware.Auth(handler.GetChat(), deps.AuthDeps))
+	router.Handle("POST /api/chats/{chatID}/members", middleware.Auth(handler.AddMember(), deps.AuthDeps))
+	router.Handle("POST /api/chats/{chatID}/read", middleware.Auth(handler.MarkRead(), deps.AuthDeps))
+	router.Handle("DELETE /api/chats/{chatID}/members/{userID}", middleware.Auth(handler.RemoveMember(), deps.AuthDeps))
+}
+
+func (h *ChatHandler) CreateChat() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[chat.CreateChatRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		c, err := h.chatService.CreateChat(r.Context(), userID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusCreated, chat.NewChatResponse(c), nil)
+	}
+}
+
+func (h *ChatHandler) ListChats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := appcontext.GetContextUserID(r.Context())
+
+		chats, err := h.chatService.ListChats(r.Context(), userID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := make([]chat.ChatSummaryResponse, 0, len(chats))
+		for _, c := range chats {
+			data = append(data, chat.NewChatSummaryResponse(c))
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *ChatHandler) GetChat() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		c, err := h.chatService.GetChat(r.Context(), userID, chatID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, chat.NewChatResponse(c), nil)
+	}
+}
+
+func (h *ChatHandler) AddMember() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[chat.AddMemberRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		m, err := h.chatService.AddMember(r.Context(), userID, chatID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusCreated, chat.NewMemberResponse(m), nil)
+	}
+}
+
+func (h *ChatHandler) MarkRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[chat.MarkReadRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		m, err := h.chatService.MarkRead(r.Context(), userID, chatID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, chat.NewMemberResponse(m), nil)
+	}
+}
+
+func (h *ChatHandler) RemoveMember() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		memberID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		if err = h.chatService.RemoveMember(r.Context(), userID, chatID, memberID); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
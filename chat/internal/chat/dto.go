@@ -0,0 +1,145 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation"
+)
+
+type CreateChatRequest struct {
+	Type      Type    `json:"type"`
+	Title     string  `json:"title"`
+	MemberIDs []int64 `json:"member_ids"`
+}
+
+func (r CreateChatRequest) Validate() error {
+	titleRules := []validation.Rule{validation.RuneLength(1, 100)}
+	memberIDsRules := []validation.Rule{validation.Each(validation.Required, validation.Min(int64(1)))}
+
+	switch r.Type {
+	case TypeDirect:
+		memberIDsRules = append(memberIDsRules, validation.Required, validation.Length(1, 1))
+	case TypeGroup:
+		titleRules = append(titleRules, validation.Required)
+		memberIDsRules = append(memberIDsRules, validation.Length(0, 200))
+	}
+
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Type, validation.Required, validation.In(TypeDirect, TypeGroup)),
+		validation.Field(&r.Title, titleRules...),
+		validation.Field(&r.MemberIDs, memberIDsRules...),
+	)
+}
+
+type AddMemberRequest struct {
+	UserID int64 `json:"user_id"`
+	Role   Role  `json:"role"`
+}
+
+func (r AddMemberRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.UserID, validation.Required, validation.Min(int64(1))),
+		validation.Field(&r.Role, validation.In(RoleAdmin, RoleMember)),
+	)
+}
+
+type MarkReadRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+func (r MarkReadRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MessageID, validation.Required, validation.Min(int64(1))),
+	)
+}
+
+type MemberResponse struct {
+	UserID            int64      `json:"user_id"`
+	Role              Role       `json:"role"`
+	JoinedAt          time.Time  `json:"joined_at"`
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+}
+
+type ChatResponse struct {
+	ID        int64            `json:"id"`
+	Type      Type             `json:"type"`
+	Title     string           `json:"title"`
+	CreatedBy int64            `json:"created_by"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Members   []MemberResponse `json:"members,omitempty"`
+}
+
+func NewMemberResponse(m *Member) MemberResponse {
+	return MemberResponse{
+		UserID:            m.UserID,
+		Role:              m.Role,
+		JoinedAt:          m.JoinedAt,
+		LastReadMessageID: m.LastReadMessageID,
+		LastReadAt:        m.LastReadAt,
+	}
+}
+
+func NewChatResponse(c *Chat) ChatResponse {
+	data := ChatResponse{
+		ID:        c.ID,
+		Type:      c.Type,
+		Title:     c.Title,
+		CreatedBy: c.CreatedBy,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+
+	if c.Members != nil {
+		data.Members = make([]MemberResponse, 0, len(c.Members))
+		for _, m := range c.Members {
+			data.Members = append(data.Members, NewMemberResponse(m))
+		}
+	}
+
+	return data
+}
+
+// previewLength limits the body of the last message in the chat list.
+const previewLength = 100
+
+type MessagePreviewResponse struct {
+	ID        int64     `json:"id"`
+	SenderID  int64     `json:"sender_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	Deleted   bool      `json:"deleted"`
+}
+
+type ChatSummaryResponse struct {
+	ChatResponse
+	LastReadMessageID int64                   `json:"last_read_message_id"`
+	UnreadCount       int64                   `json:"unread_count"`
+	LastMessage       *MessagePreviewResponse `json:"last_message"`
+}
+
+func NewChatSummaryResponse(s *Summary) ChatSummaryResponse {
+	data := ChatSummaryResponse{
+		ChatResponse:      NewChatResponse(&s.Chat),
+		LastReadMessageID: s.LastReadMessageID,
+		UnreadCount:       s.UnreadCount,
+	}
+
+	if s.LastMessage != nil {
+		body := []rune(s.LastMessage.Body)
+		if len(body) > previewLength {
+			body = body[:previewLength]
+		}
+
+		data.LastMessage = &MessagePreviewResponse{
+			ID:        s.LastMessage.ID,
+			SenderID:  s.LastMessage.SenderID,
+			Body:      string(body),
+			CreatedAt: s.LastMessage.CreatedAt,
+			Deleted:   s.LastMessage.Deleted,
+		}
+	}
+
+	return data
+}
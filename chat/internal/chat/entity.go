@@ -0,0 +1,76 @@
+package chat
+
+import "time"
+
+type Type string
+
+const (
+	TypeDirect Type = "direct"
+	TypeGroup  Type = "group"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// CanManage reports whether a member with this role may remove a member with
+// the other role from a group chat.
+func (r Role) CanManage(other Role) bool {
+	switch r {
+	case RoleOwner:
+		return other != RoleOwner
+	case RoleAdmin:
+		return other == RoleMember
+	default:
+		return false
+	}
+}
+
+// IsAdmin reports whether the role may moderate the chat, e.g. delete
+// messages of other members.
+func (r Role) IsAdmin() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+type Chat struct {
+	ID        int64     `db:"id"`
+	Type      Type      `db:"type"`
+	Title     string    `db:"title"`
+	DirectKey *string   `db:"direct_key"`
+	CreatedBy int64     `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Members   []*Member `db:"-"`
+}
+
+// Member is a user in a chat. LastReadMessageID is the read marker, the
+// messages after it are unread for the member.
+type Member struct {
+	ChatID            int64      `db:"chat_id"`
+	UserID            int64      `db:"user_id"`
+	Role              Role       `db:"role"`
+	JoinedAt          time.Time  `db:"joined_at"`
+	LastReadMessageID int64      `db:"last_read_message_id"`
+	LastReadAt        *time.Time `db:"last_read_at"`
+}
+
+// Summary is a chat as listed for one of its members.
+type Summary struct {
+	Chat
+	LastReadMessageID int64
+	UnreadCount       int64
+	LastMessage       *MessagePreview
+}
+
+// MessagePreview is the latest message of a chat shown in the chat list.
+type MessagePreview struct {
+	ID        int64
+	SenderID  int64
+	Body      string
+	CreatedAt time.Time
+	Deleted   bool
+}
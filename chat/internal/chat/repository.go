@@ -0,0 +1,16 @@
+package chat
+
+import "context"
+
+type ChatRepository interface {
+	Create(ctx context.Context, chat *Chat) error
+	FindByID(ctx context.Context, id int64) (*Chat, error)
+	FindByDirectKey(ctx context.Context, directKey string) (*Chat, error)
+	FindSummariesByUserID(ctx context.Context, userID int64) ([]*Summary, error)
+	FindIDsByUserID(ctx context.Context, userID int64) ([]int64, error)
+	AddMember(ctx context.Context, member *Member) error
+	UpdateLastRead(ctx context.Context, member *Member, messageID int64) (bool, error)
+	RemoveMember(ctx context.Context, chatID, userID int64) error
+	FindMember(ctx context.Context, chatID, userID int64) (*Member, error)
+	FindMembers(ctx context.Context, chatID int64) ([]*Member, error)
+}
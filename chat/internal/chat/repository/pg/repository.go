@@ -0,0 +1,357 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+)
+
+type ChatRepository struct {
+	db *pg.Postgres
+}
+
+func NewChatRepository(db *pg.Postgres) *ChatRepository {
+	return &ChatRepository{
+		db: db,
+	}
+}
+
+// Create inserts the chat together with its initial members in a single
+// transaction.
+func (r *ChatRepository) Create(ctx context.Context, c *chat.Chat) error {
+	defer metrics.Storage.Observe("postgres", "chat", "Create", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.db.Sb.
+		Insert("chats").
+		Columns("type", "title", "direct_key", "created_by").
+		Values(c.Type, c.Title, c.DirectKey, c.CreatedBy).
+		Suffix("RETURNING id, created_at, updated_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRowContext(ctx, query, args...).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if pg.IsUniqueViolation(err) {
+			return apperrors.ErrChatExists
+		}
+		return err
+	}
+
+	for _, m := range c.Members {
+		m.ChatID = c.ID
+
+		query, args, err = r.db.Sb.
+			Insert("chat_members").
+			Columns("chat_id", "user_id", "role").
+			Values(m.ChatID, m.UserID, m.Role).
+			Suffix("RETURNING joined_at").
+			ToSql()
+		if err != nil {
+			return err
+		}
+
+		if err = tx.QueryRowContext(ctx, query, args...).Scan(&m.JoinedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ChatRepository) FindByID(ctx context.Context, id int64) (*chat.Chat, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindByID", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("chats").
+		Where(squirrel.Eq{
+			"id": id,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var c chat.Chat
+	if err = r.db.Sqlx.GetContext(ctx, &c, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *ChatRepository) FindByDirectKey(ctx context.Context, directKey string) (*chat.Chat, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindByDirectKey", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("chats").
+		Where(squirrel.Eq{
+			"direct_key": directKey,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var c chat.Chat
+	if err = r.db.Sqlx.GetContext(ctx, &c, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// summaryRow is a row of the chat list query. The last message columns are
+// NULL for chats without messages.
+type summaryRow struct {
+	chat.Chat
+	LastReadMessageID    int64      `db:"last_read_message_id"`
+	UnreadCount          int64      `db:"unread_count"`
+	LastMessageID        *int64     `db:"last_message_id"`
+	LastMessageSenderID  *int64     `db:"last_message_sender_id"`
+	LastMessageBody      *string    `db:"last_message_body"`
+	LastMessageCreatedAt *time.Time `db:"last_message_created_at"`
+	LastMessageDeletedAt *time.Time `db:"last_message_deleted_at"`
+}
+
+// FindSummariesByUserID lists the chats of the user with the unread count
+// and the last message of each in a single query, leaving out replies in
+// threads, which have their own read markers. Both are lateral lookups
+// on the messages (chat_id, id) index, so a chat costs an index probe plus
+// its unread messages. Chats with the latest message come first, chats
+// without messages by their creation time.
+func (r *ChatRepository) FindSummariesByUserID(ctx context.Context, userID int64) ([]*chat.Summary, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindSummariesByUserID", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select(
+			"c.*",
+			"cm.last_read_message_id",
+			"unread.count AS unread_count",
+			"lm.id AS last_message_id",
+			"lm.sender_id AS last_message_sender_id",
+			"lm.body AS last_message_body",
+			"lm.created_at AS last_message_created_at",
+			"lm.deleted_at AS last_message_deleted_at",
+		).
+		From("chats c").
+		Join("chat_members cm ON cm.chat_id = c.id").
+		JoinClause(`LEFT JOIN LATERAL (
+			SELECT id, sender_id, body, created_at, deleted_at FROM messages
+			WHERE chat_id = c.id AND thread_id IS NULL
+			ORDER BY id DESC
+			LIMIT 1
+		) lm ON true`).
+		JoinClause(`CROSS JOIN LATERAL (
+			SELECT count(*) AS count FROM messages
+			WHERE chat_id = c.id AND thread_id IS NULL AND id > cm.last_read_message_id AND sender_id <> cm.user_id AND deleted_at IS NULL
+		) unread`).
+		Where(squirrel.Eq{
+			"cm.user_id": userID,
+		}).
+		OrderBy("COALESCE(lm.created_at, c.created_at) DESC", "c.id DESC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*summaryRow, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*chat.Summary, 0, len(rows))
+	for _, row := range rows {
+		summary := &chat.Summary{
+			Chat:              row.Chat,
+			LastReadMessageID: row.LastReadMessageID,
+			UnreadCount:       row.UnreadCount,
+		}
+		if row.LastMessageID != nil {
+			summary.LastMessage = &chat.MessagePreview{
+				ID:        *row.LastMessageID,
+				SenderID:  *row.LastMessageSenderID,
+				Body:      *row.LastMessageBody,
+				CreatedAt: *row.LastMessageCreatedAt,
+				Deleted:   row.LastMessageDeletedAt != nil,
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+func (r *ChatRepository) FindIDsByUserID(ctx context.Context, userID int64) ([]int64, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindIDsByUserID", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("chat_id").
+		From("chat_members").
+		Where(squirrel.Eq{
+			"user_id": userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *ChatRepository) AddMember(ctx context.Context, member *chat.Member) error {
+	defer metrics.Storage.Observe("postgres", "chat", "AddMember", time.Now())
+
+	// History sent before the member joined is not unread
+	query, args, err := r.db.Sb.
+		Insert("chat_members").
+		Columns("chat_id", "user_id", "role", "last_read_message_id").
+		Values(member.ChatID, member.UserID, member.Role,
+			squirrel.Expr("(SELECT COALESCE(MAX(id), 0) FROM messages WHERE chat_id = ?)", member.ChatID),
+		).
+		Suffix("RETURNING joined_at, last_read_message_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&member.JoinedAt, &member.LastReadMessageID)
+	if pg.IsUniqueViolation(err) {
+		return apperrors.ErrMemberExists
+	}
+
+	return err
+}
+
+// UpdateLastRead advances the read marker of the member to messageID, which
+// has to be a message of the chat history, not a reply in a thread. Markers
+// never move back, it reports whether the marker moved.
+func (r *ChatRepository) UpdateLastRead(ctx context.Context, member *chat.Member, messageID int64) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "UpdateLastRead", time.Now())
+
+	query, args, err := r.db.Sb.
+		Update("chat_members").
+		Set("last_read_message_id", messageID).
+		Set("last_read_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Where(squirrel.And{
+			squirrel.Eq{
+				"chat_id": member.ChatID,
+				"user_id": member.UserID,
+			},
+			squirrel.Lt{
+				"last_read_message_id": messageID,
+			},
+			squirrel.Expr("EXISTS (SELECT 1 FROM messages WHERE id = ? AND chat_id = ? AND thread_id IS NULL)", messageID, member.ChatID),
+		}).
+		Suffix("RETURNING last_read_message_id, last_read_at").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	err = r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&member.LastReadMessageID, &member.LastReadAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *ChatRepository) RemoveMember(ctx context.Context, chatID, userID int64) error {
+	defer metrics.Storage.Observe("postgres", "chat", "RemoveMember", time.Now())
+
+	query, args, err := r.db.Sb.
+		Delete("chat_members").
+		Where(squirrel.Eq{
+			"chat_id": chatID,
+			"user_id": userID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Sqlx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *ChatRepository) FindMember(ctx context.Context, chatID, userID int64) (*chat.Member, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindMember", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("chat_members").
+		Where(squirrel.Eq{
+			"chat_id": chatID,
+			"user_id": userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var m chat.Member
+	if err = r.db.Sqlx.GetContext(ctx, &m, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (r *ChatRepository) FindMembers(ctx context.Context, chatID int64) ([]*chat.Member, error) {
+	defer metrics.Storage.Observe("postgres", "chat", "FindMembers", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("chat_members").
+		Where(squirrel.Eq{
+			"chat_id": chatID,
+		}).
+		OrderBy("joined_at", "user_id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*chat.Member, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &members, query, args...); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
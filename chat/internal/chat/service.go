@@ -0,0 +1,12 @@
+package chat
+
+import "context"
+
+type ChatService interface {
+	CreateChat(ctx context.Context, userID int64, req *CreateChatRequest) (*Chat, error)
+	GetChat(ctx context.Context, userID, chatID int64) (*Chat, error)
+	ListChats(ctx context.Context, userID int64) ([]*Summary, error)
+	AddMember(ctx context.Context, userID, chatID int64, req *AddMemberRequest) (*Member, error)
+	MarkRead(ctx context.Context, userID, chatID int64, req *MarkReadRequest) (*Member, error)
+	RemoveMember(ctx context.Context, userID, chatID, memberID int64) error
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+)
+
+type ChatServiceDeps struct {
+	ChatRepo  chat.ChatRepository
+	Publisher event.Publisher
+}
+
+type ChatService struct {
+	chatRepo  chat.ChatRepository
+	publisher event.Publisher
+}
+
+func NewChatService(deps ChatServiceDeps) *ChatService {
+	return &ChatService{
+		chatRepo:  deps.ChatRepo,
+		publisher: deps.Publisher,
+	}
+}
+
+func (s *ChatService) CreateChat(ctx context.Context, userID int64, req *chat.CreateChatRequest) (*chat.Chat, error) {
+	c := &chat.Chat{
+		Type:      req.Type,
+		CreatedBy: userID,
+		Members: []*chat.Member{
+			{
+				UserID: userID,
+				Role:   chat.RoleOwner,
+			},
+		},
+	}
+
+	switch req.Type {
+	case chat.TypeDirect:
+		peerID := req.MemberIDs[0]
+		if peerID == userID {
+			return nil, apperrors.ErrBadRequest
+		}
+
+		directKey := directChatKey(userID, peerID)
+		existedChat, err := s.chatRepo.FindByDirectKey(ctx, directKey)
+		if err != nil {
+			return nil, err
+		}
+		if existedChat != nil {
+			return nil, apperrors.ErrChatExists
+		}
+
+		c.DirectKey = &directKey
+		c.Members[0].Role = chat.RoleMember
+		c.Members = append(c.Members, &chat.Member{
+			UserID: peerID,
+			Role:   chat.RoleMember,
+		})
+	case chat.TypeGroup:
+		c.Title = req.Title
+
+		seen := map[int64]bool{userID: true}
+		for _, memberID := range req.MemberIDs {
+			if seen[memberID] {
+				continue
+			}
+			seen[memberID] = true
+
+			c.Members = append(c.Members, &chat.Member{
+				UserID: memberID,
+				Role:   chat.RoleMember,
+			})
+		}
+	default:
+		return nil, apperrors.ErrBadRequest
+	}
+
+	if err := s.chatRepo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+
+	for _, m := range c.Members {
+		s.publish(ctx, event.TypeMemberAdded, c.ID, chat.NewMemberResponse(m))
+	}
+
+	return c, nil
+}
+
+func (s *ChatService) GetChat(ctx context.Context, userID, chatID int64) (*chat.Chat, error) {
+	c, _, err := s.findChatAsMember(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Members, err = s.chatRepo.FindMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ListChats returns the chats of the user with their unread counts and last
+// messages.
+func (s *ChatService) ListChats(ctx context.Context, userID int64) ([]*chat.Summary, error) {
+	return s.chatRepo.FindSummariesByUserID(ctx, userID)
+}
+
+// MarkRead advances the read marker of the user to the message and notifies
+// the other members. Marking an older message is a no-op.
+func (s *ChatService) MarkRead(ctx context.Context, userID, chatID int64, req *chat.MarkReadRequest) (*chat.Member, error) {
+	member, err := s.chatRepo.FindMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	advanced, err := s.chatRepo.UpdateLastRead(ctx, member, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		// The marker is already past the message, possibly moved by another
+		// device meanwhile, or the message is not in the chat
+		member, err = s.chatRepo.FindMember(ctx, chatID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if member == nil || member.LastReadMessageID < req.MessageID {
+			return nil, apperrors.ErrNotFound
+		}
+
+		return member, nil
+	}
+
+	s.publish(ctx, event.TypeReadUpdated, chatID, event.ReadData{
+		UserID:            userID,
+		LastReadMessageID: member.LastReadMessageID,
+		ReadAt:            member.LastReadAt,
+	})
+
+	return member, nil
+}
+
+func (s *ChatService) AddMember(ctx context.Context, userID, chatID int64, req *chat.AddMemberRequest) (*chat.Member, error) {
+	c, requester, err := s.findChatAsMember(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Type != chat.TypeGroup {
+		return nil, apperrors.ErrBadRequest
+	}
+	if requester.Role != chat.RoleOwner && requester.Role != chat.RoleAdmin {
+		return nil, apperrors.ErrForbidden
+	}
+
+	role := req.Role
+	if role == "" {
+		role = chat.RoleMember
+	}
+	if role == chat.RoleAdmin && requester.Role != chat.RoleOwner {
+		return nil, apperrors.ErrForbidden
+	}
+
+	m := &chat.Member{
+		ChatID: chatID,
+		UserID: req.UserID,
+		Role:   role,
+	}
+
+	if err = s.chatRepo.AddMember(ctx, m); err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, event.TypeMemberAdded, chatID, chat.NewMemberResponse(m))
+
+	return m, nil
+}
+
+// RemoveMember removes memberID from a group chat. Members can always leave on
+// their own, while removing somebody else requires a more privileged role.
+func (s *ChatService) RemoveMember(ctx context.Context, userID, chatID, memberID int64) error {
+	c, requester, err := s.findChatAsMember(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	if c.Type != chat.TypeGroup {
+		return apperrors.ErrBadRequest
+	}
+
+	// A group without an owner could no longer be managed
+	if memberID == userID && requester.Role == chat.RoleOwner {
+		return apperrors.ErrOwnerLeave
+	}
+
+	if memberID != userID {
+		target, err := s.chatRepo.FindMember(ctx, chatID, memberID)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return apperrors.ErrNotFound
+		}
+		if !requester.Role.CanManage(target.Role) {
+			return apperrors.ErrForbidden
+		}
+	}
+
+	if err = s.chatRepo.RemoveMember(ctx, chatID, memberID); err != nil {
+		return err
+	}
+
+	s.publish(ctx, event.TypeMemberRemoved, chatID, event.MemberData{
+		UserID: memberID,
+	})
+
+	return nil
+}
+
+// findChatAsMember loads the chat and the requesting user's membership. Chats
+// the user is not a member of are reported as not found.
+func (s *ChatService) findChatAsMember(ctx context.Context, userID, chatID int64) (*chat.Chat, *chat.Member, error) {
+	member, err := s.chatRepo.FindMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member == nil {
+		return nil, nil, apperrors.ErrNotFound
+	}
+
+	c, err := s.chatRepo.FindByID(ctx, chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c == nil {
+		return nil, nil, apperrors.ErrNotFound
+	}
+
+	return c, member, nil
+}
+
+func directChatKey(userID, peerID int64) string {
+	return fmt.Sprintf("%d:%d", min(userID, peerID), max(userID, peerID))
+}
+
+// publish notifies connected clients about a change that has already been
+// persisted, so delivery failures are logged rather than returned.
+func (s *ChatService) publish(ctx context.Context, eventType event.Type, chatID int64, data any) {
+	e, err := event.New(eventType, chatID, data)
+	if err == nil {
+		err = s.publisher.Publish(ctx, e)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error publishing event", "event_type", eventType, "chat_id", chatID, "error", err)
+	}
+}
@@ -0,0 +1,340 @@
+package service_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	chatpg "github.com/maximegorov13/chat-app/chat/internal/chat/repository/pg"
+	chatservice "github.com/maximegorov13/chat-app/chat/internal/chat/service"
+	memorybus "github.com/maximegorov13/chat-app/chat/internal/event/bus/memory"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	messagepg "github.com/maximegorov13/chat-app/chat/internal/message/repository/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/ws"
+)
+
+type testDependencies struct {
+	chatService chat.ChatService
+	chatRepo    chat.ChatRepository
+	messageRepo message.MessageRepository
+	cleanupChat func(chatID int64)
+}
+
+func getUniqueUserID() int64 {
+	return rand.Int64N(1<<62) + 1<<62
+}
+
+func setupTest(t testing.TB) *testDependencies {
+	t.Helper()
+
+	conf, err := configs.Load("../../../.env")
+	require.NoError(t, err)
+
+	pgClient, err := pg.NewPostgres(conf)
+	require.NoError(t, err)
+
+	chatRepo := chatpg.NewChatRepository(pgClient)
+
+	cleanupChat := func(chatID int64) {
+		query, args, err := pgClient.Sb.
+			Delete("chats").
+			Where(squirrel.Eq{
+				"id": chatID,
+			}).
+			ToSql()
+		if err != nil {
+			t.Logf("cleanup query build error: %v", err)
+		}
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	bus := memorybus.NewBus(nil)
+	t.Cleanup(func() {
+		bus.Close()
+	})
+
+	return &testDependencies{
+		chatService: chatservice.NewChatService(chatservice.ChatServiceDeps{
+			ChatRepo: chatRepo,
+			Publisher: ws.NewHub(ws.HubDeps{
+				ChatRepo: chatRepo,
+				Bus:      bus,
+			}),
+		}),
+		chatRepo:    chatRepo,
+		messageRepo: messagepg.NewMessageRepository(pgClient),
+		cleanupChat: cleanupChat,
+	}
+}
+
+func TestChatService_CreateChat(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful create group chat", func(t *testing.T) {
+		ownerID := getUniqueUserID()
+		memberID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+			Type:      chat.TypeGroup,
+			Title:     "Test Group",
+			MemberIDs: []int64{memberID, memberID, ownerID},
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+		require.NotZero(t, c.ID)
+		require.Equal(t, chat.TypeGroup, c.Type)
+		require.Equal(t, "Test Group", c.Title)
+		require.Len(t, c.Members, 2)
+
+		owner, err := deps.chatRepo.FindMember(ctx, c.ID, ownerID)
+		require.NoError(t, err)
+		require.NotNil(t, owner)
+		require.Equal(t, chat.RoleOwner, owner.Role)
+	})
+
+	t.Run("successful create direct chat", func(t *testing.T) {
+		userID := getUniqueUserID()
+		peerID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:      chat.TypeDirect,
+			MemberIDs: []int64{peerID},
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+		require.Equal(t, chat.TypeDirect, c.Type)
+		require.Len(t, c.Members, 2)
+	})
+
+	t.Run("direct chat already exists", func(t *testing.T) {
+		userID := getUniqueUserID()
+		peerID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:      chat.TypeDirect,
+			MemberIDs: []int64{peerID},
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.chatService.CreateChat(ctx, peerID, &chat.CreateChatRequest{
+			Type:      chat.TypeDirect,
+			MemberIDs: []int64{userID},
+		})
+		require.ErrorIs(t, err, apperrors.ErrChatExists)
+	})
+}
+
+func TestChatService_Members(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("add and remove member", func(t *testing.T) {
+		ownerID := getUniqueUserID()
+		memberID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Test Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		m, err := deps.chatService.AddMember(ctx, ownerID, c.ID, &chat.AddMemberRequest{
+			UserID: memberID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, chat.RoleMember, m.Role)
+
+		_, err = deps.chatService.AddMember(ctx, ownerID, c.ID, &chat.AddMemberRequest{
+			UserID: memberID,
+		})
+		require.ErrorIs(t, err, apperrors.ErrMemberExists)
+
+		err = deps.chatService.RemoveMember(ctx, memberID, c.ID, ownerID)
+		require.ErrorIs(t, err, apperrors.ErrForbidden)
+
+		err = deps.chatService.RemoveMember(ctx, ownerID, c.ID, memberID)
+		require.NoError(t, err)
+
+		_, err = deps.chatService.GetChat(ctx, memberID, c.ID)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("owner cannot leave", func(t *testing.T) {
+		ownerID := getUniqueUserID()
+		memberID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+			Type:      chat.TypeGroup,
+			Title:     "Test Group",
+			MemberIDs: []int64{memberID},
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		err = deps.chatService.RemoveMember(ctx, ownerID, c.ID, ownerID)
+		require.ErrorIs(t, err, apperrors.ErrOwnerLeave)
+
+		owner, err := deps.chatRepo.FindMember(ctx, c.ID, ownerID)
+		require.NoError(t, err)
+		require.NotNil(t, owner)
+
+		// Other members can still leave
+		err = deps.chatService.RemoveMember(ctx, memberID, c.ID, memberID)
+		require.NoError(t, err)
+	})
+
+	t.Run("member cannot add members", func(t *testing.T) {
+		ownerID := getUniqueUserID()
+		memberID := getUniqueUserID()
+
+		c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+			Type:      chat.TypeGroup,
+			Title:     "Test Group",
+			MemberIDs: []int64{memberID},
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.chatService.AddMember(ctx, memberID, c.ID, &chat.AddMemberRequest{
+			UserID: getUniqueUserID(),
+		})
+		require.ErrorIs(t, err, apperrors.ErrForbidden)
+	})
+}
+
+func TestChatService_ReadMarkers(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	ownerID := getUniqueUserID()
+	memberID := getUniqueUserID()
+	c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+		Type:      chat.TypeGroup,
+		Title:     "Test Group",
+		MemberIDs: []int64{memberID},
+	})
+	t.Cleanup(func() {
+		deps.cleanupChat(c.ID)
+	})
+	require.NoError(t, err)
+
+	sent := make([]*message.Message, 0, 3)
+	for range 3 {
+		m := &message.Message{
+			ChatID:   c.ID,
+			SenderID: ownerID,
+			Body:     "hello",
+		}
+		require.NoError(t, deps.messageRepo.Create(ctx, m))
+		sent = append(sent, m)
+	}
+
+	findSummary := func(userID int64) *chat.Summary {
+		summaries, err := deps.chatService.ListChats(ctx, userID)
+		require.NoError(t, err)
+		for _, s := range summaries {
+			if s.ID == c.ID {
+				return s
+			}
+		}
+		t.Fatalf("chat %d not listed", c.ID)
+		return nil
+	}
+
+	t.Run("unread counts and last message", func(t *testing.T) {
+		summary := findSummary(memberID)
+		require.Equal(t, int64(3), summary.UnreadCount)
+		require.NotNil(t, summary.LastMessage)
+		require.Equal(t, sent[2].ID, summary.LastMessage.ID)
+
+		// Own messages are read
+		summary = findSummary(ownerID)
+		require.Zero(t, summary.UnreadCount)
+		require.Equal(t, sent[2].ID, summary.LastReadMessageID)
+	})
+
+	t.Run("mark read", func(t *testing.T) {
+		member, err := deps.chatService.MarkRead(ctx, memberID, c.ID, &chat.MarkReadRequest{
+			MessageID: sent[1].ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, sent[1].ID, member.LastReadMessageID)
+		require.NotNil(t, member.LastReadAt)
+		require.Equal(t, int64(1), findSummary(memberID).UnreadCount)
+
+		// Markers do not move back
+		member, err = deps.chatService.MarkRead(ctx, memberID, c.ID, &chat.MarkReadRequest{
+			MessageID: sent[0].ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, sent[1].ID, member.LastReadMessageID)
+	})
+
+	t.Run("message of another chat", func(t *testing.T) {
+		_, err := deps.chatService.MarkRead(ctx, memberID, c.ID, &chat.MarkReadRequest{
+			MessageID: sent[2].ID + 1000000,
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		_, err := deps.chatService.MarkRead(ctx, getUniqueUserID(), c.ID, &chat.MarkReadRequest{
+			MessageID: sent[2].ID,
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("latest message first", func(t *testing.T) {
+		other, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Other Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(other.ID)
+		})
+		require.NoError(t, err)
+
+		summaries, err := deps.chatService.ListChats(ctx, ownerID)
+		require.NoError(t, err)
+		require.Equal(t, other.ID, summaries[0].ID)
+
+		require.NoError(t, deps.messageRepo.Create(ctx, &message.Message{
+			ChatID:   c.ID,
+			SenderID: ownerID,
+			Body:     "hello",
+		}))
+
+		summaries, err = deps.chatService.ListChats(ctx, ownerID)
+		require.NoError(t, err)
+		require.Equal(t, c.ID, summaries[0].ID)
+	})
+}
@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+)
+
+const messagesBufferSize = 1024
+
+// Broker is an in-process stand-in for a message broker. Every Bus connected
+// to the same Broker behaves like a separate service instance.
+type Broker struct {
+	mu    sync.RWMutex
+	buses map[*Bus]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		buses: make(map[*Bus]struct{}),
+	}
+}
+
+type Bus struct {
+	broker *Broker
+
+	mu       sync.RWMutex
+	topics   map[string]struct{}
+	messages chan *event.Message
+	closed   bool
+	done     chan struct{}
+	// senders tracks deliveries in flight, so messages is closed only after
+	// nobody can send to it anymore.
+	senders sync.WaitGroup
+}
+
+// NewBus connects a new bus to the broker. If broker is nil, the bus gets a
+// private broker, which is enough for a single instance.
+func NewBus(broker *Broker) *Bus {
+	if broker == nil {
+		broker = NewBroker()
+	}
+
+	b := &Bus{
+		broker:   broker,
+		topics:   make(map[string]struct{}),
+		messages: make(chan *event.Message, messagesBufferSize),
+		done:     make(chan struct{}),
+	}
+
+	broker.mu.Lock()
+	broker.buses[b] = struct{}{}
+	broker.mu.Unlock()
+
+	return b
+}
+
+func (b *Bus) Publish(_ context.Context, topic string, e *event.Event) error {
+	b.broker.mu.RLock()
+	subscribers := make([]*Bus, 0, len(b.broker.buses))
+	for bus := range b.broker.buses {
+		if bus.subscribed(topic) {
+			subscribers = append(subscribers, bus)
+		}
+	}
+	b.broker.mu.RUnlock()
+
+	for _, bus := range subscribers {
+		bus.deliver(&event.Message{
+			Topic: topic,
+			Event: e,
+		})
+	}
+
+	return nil
+}
+
+func (b *Bus) Subscribe(_ context.Context, topics ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, topic := range topics {
+		b.topics[topic] = struct{}{}
+	}
+
+	return nil
+}
+
+func (b *Bus) Unsubscribe(_ context.Context, topics ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(b.topics, topic)
+	}
+
+	return nil
+}
+
+func (b *Bus) Messages() <-chan *event.Message {
+	return b.messages
+}
+
+func (b *Bus) Close() error {
+	b.broker.mu.Lock()
+	delete(b.broker.buses, b)
+	b.broker.mu.Unlock()
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+
+	b.senders.Wait()
+	close(b.messages)
+
+	return nil
+}
+
+func (b *Bus) subscribed(topic string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, ok := b.topics[topic]
+	return ok
+}
+
+func (b *Bus) deliver(msg *event.Message) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	b.senders.Add(1)
+	b.mu.RUnlock()
+	defer b.senders.Done()
+
+	select {
+	case b.messages <- msg:
+	case <-b.done:
+	}
+}
@@ -0,0 +1,88 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/event/bus/memory"
+)
+
+func receive(t testing.TB, bus event.Bus) *event.Message {
+	t.Helper()
+
+	select {
+	case msg := <-bus.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func requireNoMessage(t testing.TB, bus event.Bus) {
+	t.Helper()
+
+	select {
+	case msg := <-bus.Messages():
+		t.Fatalf("unexpected message on %s", msg.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+
+	e, err := event.New(event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+	require.NoError(t, err)
+
+	t.Run("delivers to subscribed buses only", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewBus(broker)
+		subscriber := memory.NewBus(broker)
+		outsider := memory.NewBus(broker)
+		t.Cleanup(func() {
+			publisher.Close()
+			subscriber.Close()
+			outsider.Close()
+		})
+
+		require.NoError(t, subscriber.Subscribe(ctx, event.ChatTopic(10)))
+		require.NoError(t, outsider.Subscribe(ctx, event.ChatTopic(20)))
+
+		require.NoError(t, publisher.Publish(ctx, event.ChatTopic(10), e))
+
+		msg := receive(t, subscriber)
+		require.Equal(t, event.ChatTopic(10), msg.Topic)
+		require.Equal(t, e, msg.Event)
+
+		requireNoMessage(t, publisher)
+		requireNoMessage(t, outsider)
+	})
+
+	t.Run("stops delivering after unsubscribe", func(t *testing.T) {
+		bus := memory.NewBus(nil)
+		t.Cleanup(func() {
+			bus.Close()
+		})
+
+		require.NoError(t, bus.Subscribe(ctx, event.ChatTopic(10)))
+		require.NoError(t, bus.Unsubscribe(ctx, event.ChatTopic(10)))
+
+		require.NoError(t, bus.Publish(ctx, event.ChatTopic(10), e))
+
+		requireNoMessage(t, bus)
+	})
+
+	t.Run("close closes messages", func(t *testing.T) {
+		bus := memory.NewBus(nil)
+		require.NoError(t, bus.Close())
+		require.NoError(t, bus.Close())
+
+		_, ok := <-bus.Messages()
+		require.False(t, ok)
+	})
+}
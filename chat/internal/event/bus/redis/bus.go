@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/rediskeys"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+)
+
+const messagesBufferSize = 1024
+
+// Bus delivers events between instances over Redis Pub/Sub. Every topic is
+// mapped to its own channel, so Redis only sends an instance the events of
+// the topics it has subscribed to.
+type Bus struct {
+	rdb      *redis.Redis
+	pubsub   *goredis.PubSub
+	messages chan *event.Message
+}
+
+func NewBus(ctx context.Context, rdb *redis.Redis) *Bus {
+	b := &Bus{
+		rdb:      rdb,
+		pubsub:   rdb.Subscribe(ctx),
+		messages: make(chan *event.Message, messagesBufferSize),
+	}
+
+	go b.receive()
+
+	return b
+}
+
+func (b *Bus) Publish(ctx context.Context, topic string, e *event.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return b.rdb.Publish(ctx, rediskeys.EventsChannel(topic), payload)
+}
+
+func (b *Bus) Subscribe(ctx context.Context, topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	return b.pubsub.Subscribe(ctx, channels(topics)...)
+}
+
+func (b *Bus) Unsubscribe(ctx context.Context, topics ...string) error {
+	// Unsubscribing from no channels means unsubscribing from all of them.
+	if len(topics) == 0 {
+		return nil
+	}
+
+	return b.pubsub.Unsubscribe(ctx, channels(topics)...)
+}
+
+func (b *Bus) Messages() <-chan *event.Message {
+	return b.messages
+}
+
+// Close closes the Pub/Sub connection. The Redis client itself is owned by
+// the caller.
+func (b *Bus) Close() error {
+	return b.pubsub.Close()
+}
+
+func (b *Bus) receive() {
+	defer close(b.messages)
+
+	for msg := range b.pubsub.Channel() {
+		var e event.Event
+		if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+			slog.Error("Error decoding event", "channel", msg.Channel, "error", err)
+			continue
+		}
+
+		b.messages <- &event.Message{
+			Topic: rediskeys.EventsTopic(msg.Channel),
+			Event: &e,
+		}
+	}
+}
+
+func channels(topics []string) []string {
+	result := make([]string, len(topics))
+	for i, topic := range topics {
+		result[i] = rediskeys.EventsChannel(topic)
+	}
+
+	return result
+}
@@ -0,0 +1,97 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	redisbus "github.com/maximegorov13/chat-app/chat/internal/event/bus/redis"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+)
+
+func setupBus(t testing.TB, mr *miniredis.Miniredis) *redisbus.Bus {
+	t.Helper()
+
+	rdb, err := redis.NewRedis(context.Background(), &configs.Config{
+		Redis: configs.RedisConfig{
+			Url: "redis://" + mr.Addr(),
+		},
+	})
+	require.NoError(t, err)
+
+	bus := redisbus.NewBus(context.Background(), rdb)
+	t.Cleanup(func() {
+		bus.Close()
+		rdb.Close()
+	})
+
+	return bus
+}
+
+func receive(t testing.TB, bus event.Bus) *event.Message {
+	t.Helper()
+
+	select {
+	case msg := <-bus.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func requireNoMessage(t testing.TB, bus event.Bus) {
+	t.Helper()
+
+	select {
+	case msg := <-bus.Messages():
+		t.Fatalf("unexpected message on %s", msg.Topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+
+	e, err := event.New(event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+	require.NoError(t, err)
+
+	t.Run("delivers to subscribed instances only", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		publisher := setupBus(t, mr)
+		subscriber := setupBus(t, mr)
+		outsider := setupBus(t, mr)
+
+		require.NoError(t, subscriber.Subscribe(ctx, event.ChatTopic(10)))
+		require.NoError(t, outsider.Subscribe(ctx, event.ChatTopic(20)))
+
+		require.NoError(t, publisher.Publish(ctx, event.ChatTopic(10), e))
+
+		msg := receive(t, subscriber)
+		require.Equal(t, event.ChatTopic(10), msg.Topic)
+		require.Equal(t, e.Type, msg.Event.Type)
+		require.Equal(t, e.ChatID, msg.Event.ChatID)
+		require.JSONEq(t, string(e.Data), string(msg.Event.Data))
+
+		requireNoMessage(t, outsider)
+	})
+
+	t.Run("stops delivering after unsubscribe", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		bus := setupBus(t, mr)
+
+		require.NoError(t, bus.Subscribe(ctx, event.ChatTopic(10), event.UserTopic(1)))
+		require.NoError(t, bus.Unsubscribe(ctx, event.ChatTopic(10)))
+
+		require.NoError(t, bus.Publish(ctx, event.ChatTopic(10), e))
+		require.NoError(t, bus.Publish(ctx, event.UserTopic(1), e))
+
+		require.Equal(t, event.UserTopic(1), receive(t, bus).Topic)
+		requireNoMessage(t, bus)
+	})
+}
@@ -0,0 +1,130 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeMessageCreated Type = "message.created"
+	TypeMessageUpdated Type = "message.updated"
+	TypeMessageDeleted Type = "message.deleted"
+	TypeMemberAdded    Type = "member.added"
+	TypeMemberRemoved  Type = "member.removed"
+	TypeReadUpdated    Type = "read.updated"
+	TypeThreadUpdated  Type = "thread.updated"
+	// TypeThreadReplied notifies a single thread participant of a reply. It
+	// is delivered on the user topic of the participant only.
+	TypeThreadReplied Type = "thread.replied"
+
+	// Typing and presence events are ephemeral: they are only delivered to
+	// connected clients and never stored.
+	TypeTypingStarted   Type = "typing.started"
+	TypeTypingStopped   Type = "typing.stopped"
+	TypePresenceUpdated Type = "presence.updated"
+)
+
+// Event is a real-time notification about a change in a chat. Data holds the
+// JSON encoded payload, so events can be forwarded without decoding them.
+type Event struct {
+	Type   Type            `json:"type"`
+	ChatID int64           `json:"chat_id"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// MemberData is the payload of membership events.
+type MemberData struct {
+	UserID int64 `json:"user_id"`
+}
+
+// ReadData is the payload of read marker events.
+type ReadData struct {
+	UserID            int64      `json:"user_id"`
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	ReadAt            *time.Time `json:"read_at"`
+}
+
+// ThreadData is the payload of thread events, with the reply stats of the
+// thread root.
+type ThreadData struct {
+	ThreadID    int64      `json:"thread_id"`
+	ReplyCount  int        `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at"`
+}
+
+// ThreadReplyData is the payload of thread notifications. UserID is the
+// notified participant and UnreadCount their unread replies in the thread.
+type ThreadReplyData struct {
+	UserID      int64 `json:"user_id"`
+	ThreadID    int64 `json:"thread_id"`
+	MessageID   int64 `json:"message_id"`
+	SenderID    int64 `json:"sender_id"`
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// TypingData is the payload of typing events. Clients should consider a user
+// stopped typing at ExpiresAt unless the indicator is refreshed.
+type TypingData struct {
+	UserID    int64      `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// PresenceData is the payload of presence events.
+type PresenceData struct {
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+}
+
+func New(eventType Type, chatID int64, data any) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Type:   eventType,
+		ChatID: chatID,
+		Data:   raw,
+	}, nil
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// Message is an event received from a bus topic.
+type Message struct {
+	Topic string
+	Event *Event
+}
+
+// Bus delivers events between chat service instances. Every instance only
+// receives events published to the topics it has subscribed to.
+type Bus interface {
+	Publish(ctx context.Context, topic string, e *Event) error
+	Subscribe(ctx context.Context, topics ...string) error
+	Unsubscribe(ctx context.Context, topics ...string) error
+	// Messages returns the channel of received events. It is closed by Close.
+	Messages() <-chan *Message
+	Close() error
+}
+
+const (
+	chatTopicFormat = "chat:%d"
+	userTopicFormat = "user:%d"
+)
+
+// ChatTopic carries all events of a chat.
+func ChatTopic(chatID int64) string {
+	return fmt.Sprintf(chatTopicFormat, chatID)
+}
+
+// UserTopic carries events addressed to a single user, such as being added to
+// a chat the user's instance is not subscribed to yet.
+func UserTopic(userID int64) string {
+	return fmt.Sprintf(userTopicFormat, userID)
+}
@@ -0,0 +1,37 @@
+package message
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+)
+
+// cursor is the content of page cursors. Clients get it base64 encoded and
+// must treat it as opaque, so the keyset can change without breaking them.
+type cursor struct {
+	ID int64 `json:"id"`
+}
+
+func EncodeCursor(messageID int64) string {
+	b, _ := json.Marshal(cursor{
+		ID: messageID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the message ID the cursor points at.
+func DecodeCursor(s string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, apperrors.ErrInvalidCursor
+	}
+
+	var c cursor
+	if err = json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return 0, apperrors.ErrInvalidCursor
+	}
+
+	return c.ID, nil
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/appcontext"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	"github.com/maximegorov13/chat-app/chat/internal/middleware"
+	"github.com/maximegorov13/chat-app/chat/internal/req"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+type MessageHandlerDeps struct {
+	Conf             *configs.Config
+	MessageService   message.MessageService
+	AuthDeps         middleware.AuthDeps
+	MessageRateLimit middleware.RateLimitDeps
+}
+
+type MessageHandler struct {
+	conf           *configs.Config
+	messageService message.MessageService
+}
+
+func NewMessageHandler(router *http.ServeMux, deps MessageHandlerDeps) {
+	handler := &MessageHandler{
+		conf:           deps.Conf,
+		messageService: deps.MessageService,
+	}
+
+	router.Handle("POST /api/chats/{chatID}/messages", middleware.Auth(middleware.RateLimit(handler.SendMessage(), deps.MessageRateLimit), deps.AuthDeps))
+	router.Handle("GET /api/chats/{chatID}/messages", middleware.Auth(handler.ListMessages(), deps.AuthDeps))
+	router.Handle("PATCH /api/chats/{chatID}/messages/{messageID}", middleware.Auth(middleware.RateLimit(handler.EditMessage(), deps.MessageRateLimit), deps.AuthDeps))
+	router.Handle("DELETE /api/chats/{chatID}/messages/{messageID}", middleware.Auth(handler.DeleteMessage(), deps.AuthDeps))
+	router.Handle("GET /api/chats/{chatID}/messages/{messageID}/revisions", middleware.Auth(handler.ListRevisions(), deps.AuthDeps))
+	router.Handle("GET /api/chats/{chatID}/messages/{messageID}/thread", middleware.Auth(handler.ListThread(), deps.AuthDeps))
+	router.Handle("POST /api/chats/{chatID}/messages/{messageID}/thread/read", middleware.Auth(handler.MarkThreadRead(), deps.AuthDeps))
+}
+
+func (h *MessageHandler) SendMessage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[message.SendMessageRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		m, err := h.messageService.SendMessage(r.Context(), userID, chatID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusCreated, message.NewMessageResponse(m), nil)
+	}
+}
+
+func (h *MessageHandler) EditMessage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[message.EditMessageRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chatID, messageID, err := parseMessagePath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		m, err := h.messageService.EditMessage(r.Context(), userID, chatID, messageID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, message.NewMessageResponse(m), nil)
+	}
+}
+
+func (h *MessageHandler) DeleteMessage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, messageID, err := parseMessagePath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		m, err := h.messageService.DeleteMessage(r.Context(), userID, chatID, messageID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, message.NewMessageResponse(m), nil)
+	}
+}
+
+func (h *MessageHandler) ListRevisions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, messageID, err := parseMessagePath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		revisions, err := h.messageService.ListRevisions(r.Context(), userID, chatID, messageID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := make([]message.RevisionResponse, 0, len(revisions))
+		for _, rev := range revisions {
+			data = append(data, message.NewRevisionResponse(rev))
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *MessageHandler) ListMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		query, err := parseListQuery(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		page, err := h.messageService.ListMessages(r.Context(), userID, chatID, query)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, newPageData(page), &res.ResponseMeta{
+			Page: newPageMeta(page),
+		})
+	}
+}
+
+func (h *MessageHandler) ListThread() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, messageID, err := parseMessagePath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		query, err := parseListQuery(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		page, err := h.messageService.ListThread(r.Context(), userID, chatID, messageID, query)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, newPageData(page), &res.ResponseMeta{
+			Page: newPageMeta(page),
+		})
+	}
+}
+
+func (h *MessageHandler) MarkThreadRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[message.MarkThreadReadRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chatID, messageID, err := parseMessagePath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		p, err := h.messageService.MarkThreadRead(r.Context(), userID, chatID, messageID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, message.NewParticipantResponse(p), nil)
+	}
+}
+
+// parseListQuery reads the cursor, around and limit query parameters of a
+// message list.
+func parseListQuery(r *http.Request) (*message.ListMessagesQuery, error) {
+	query := &message.ListMessagesQuery{
+		Limit: message.DefaultPageLimit,
+	}
+
+	var err error
+	values := r.URL.Query()
+	if before := values.Get("before"); before != "" {
+		query.Before, err = message.DecodeCursor(before)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if after := values.Get("after"); after != "" {
+		query.After, err = message.DecodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if around := values.Get("around"); around != "" {
+		query.Around, err = strconv.ParseInt(around, 10, 64)
+		if err != nil {
+			return nil, apperrors.ErrBadRequest
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return nil, apperrors.ErrBadRequest
+		}
+	}
+	if err = query.Validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func newPageData(page *message.Page) []message.MessageResponse {
+	data := make([]message.MessageResponse, 0, len(page.Messages))
+	for _, m := range page.Messages {
+		data = append(data, message.NewMessageResponse(m))
+	}
+
+	return data
+}
+
+// newPageMeta returns the cursors of the pages next to page. They point at
+// its oldest and newest messages.
+func newPageMeta(page *message.Page) *res.PageMeta {
+	meta := &res.PageMeta{}
+	if len(page.Messages) == 0 {
+		return meta
+	}
+
+	if page.HasOlder {
+		meta.Before = message.EncodeCursor(page.Messages[len(page.Messages)-1].ID)
+	}
+	if page.HasNewer {
+		meta.After = message.EncodeCursor(page.Messages[0].ID)
+	}
+
+	return meta
+}
+
+func parseMessagePath(r *http.Request) (chatID, messageID int64, err error) {
+	chatID, err = strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+	if err != nil {
+		return 0, 0, apperrors.ErrBadRequest
+	}
+	messageID, err = strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+	if err != nil {
+		return 0, 0, apperrors.ErrBadRequest
+	}
+
+	return chatID, messageID, nil
+}
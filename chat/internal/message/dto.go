@@ -0,0 +1,179 @@
+package message
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// SendMessageRequest may quote a message with ReplyToID and post to the
+// thread of a message with ThreadID. A quoted message has to be in the same
+// thread, or in the chat history for messages outside threads.
+type SendMessageRequest struct {
+	Body      string `json:"body"`
+	ReplyToID *int64 `json:"reply_to_id"`
+	ThreadID  *int64 `json:"thread_id"`
+}
+
+func (r SendMessageRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Body, validation.Required, validation.RuneLength(1, 4000)),
+		validation.Field(&r.ReplyToID, validation.NilOrNotEmpty, validation.Min(int64(1))),
+		validation.Field(&r.ThreadID, validation.NilOrNotEmpty, validation.Min(int64(1))),
+	)
+}
+
+type EditMessageRequest struct {
+	Body string `json:"body"`
+}
+
+func (r EditMessageRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Body, validation.Required, validation.RuneLength(1, 4000)),
+	)
+}
+
+type MarkThreadReadRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+func (r MarkThreadReadRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MessageID, validation.Required, validation.Min(int64(1))),
+	)
+}
+
+// ListMessagesQuery selects a page of the chat history or of a thread by
+// message ID. At most one of Before, After and Around is set. The latest
+// messages are listed if none is.
+type ListMessagesQuery struct {
+	Before int64 // Messages older than this one
+	After  int64 // Messages newer than this one
+	Around int64 // Window centred on this message, which is included
+	Limit  uint64
+}
+
+func (q ListMessagesQuery) Validate() error {
+	err := validation.ValidateStruct(&q,
+		validation.Field(&q.Before, validation.Min(int64(0))),
+		validation.Field(&q.After, validation.Min(int64(0))),
+		validation.Field(&q.Around, validation.Min(int64(0))),
+		validation.Field(&q.Limit, validation.Required, validation.Max(uint64(MaxPageLimit))),
+	)
+	if err != nil {
+		return err
+	}
+
+	cursors := 0
+	for _, id := range []int64{q.Before, q.After, q.Around} {
+		if id > 0 {
+			cursors++
+		}
+	}
+	if cursors > 1 {
+		return validation.Errors{
+			"Around": errors.New("only one of before, after and around can be set"),
+		}
+	}
+
+	return nil
+}
+
+type MessageResponse struct {
+	ID          int64          `json:"id"`
+	ChatID      int64          `json:"chat_id"`
+	SenderID    int64          `json:"sender_id"`
+	Body        string         `json:"body"`
+	ReplyToID   *int64         `json:"reply_to_id"`
+	ReplyTo     *QuoteResponse `json:"reply_to,omitempty"`
+	ThreadID    *int64         `json:"thread_id"`
+	ReplyCount  int            `json:"reply_count"`
+	LastReplyAt *time.Time     `json:"last_reply_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	EditedAt    *time.Time     `json:"edited_at"`
+	Deleted     bool           `json:"deleted"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
+}
+
+func NewMessageResponse(m *Message) MessageResponse {
+	data := MessageResponse{
+		ID:          m.ID,
+		ChatID:      m.ChatID,
+		SenderID:    m.SenderID,
+		Body:        m.Body,
+		ReplyToID:   m.ReplyToID,
+		ThreadID:    m.ThreadID,
+		ReplyCount:  m.ReplyCount,
+		LastReplyAt: m.LastReplyAt,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+		EditedAt:    m.EditedAt,
+		Deleted:     m.Deleted(),
+		DeletedAt:   m.DeletedAt,
+	}
+
+	if m.ReplyTo != nil {
+		body := []rune(m.ReplyTo.Body)
+		if len(body) > quoteLength {
+			body = body[:quoteLength]
+		}
+
+		data.ReplyTo = &QuoteResponse{
+			ID:       m.ReplyTo.ID,
+			SenderID: m.ReplyTo.SenderID,
+			Body:     string(body),
+			Deleted:  m.ReplyTo.Deleted(),
+		}
+	}
+
+	return data
+}
+
+// quoteLength limits the body of quoted messages.
+const quoteLength = 100
+
+type QuoteResponse struct {
+	ID       int64  `json:"id"`
+	SenderID int64  `json:"sender_id"`
+	Body     string `json:"body"`
+	Deleted  bool   `json:"deleted"`
+}
+
+type ParticipantResponse struct {
+	ThreadID          int64      `json:"thread_id"`
+	UserID            int64      `json:"user_id"`
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	UnreadCount       int64      `json:"unread_count"`
+}
+
+func NewParticipantResponse(p *Participant) ParticipantResponse {
+	return ParticipantResponse{
+		ThreadID:          p.ThreadID,
+		UserID:            p.UserID,
+		LastReadMessageID: p.LastReadMessageID,
+		LastReadAt:        p.LastReadAt,
+		UnreadCount:       p.UnreadCount,
+	}
+}
+
+type RevisionResponse struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"` // When the body was replaced
+}
+
+func NewRevisionResponse(r *Revision) RevisionResponse {
+	return RevisionResponse{
+		ID:        r.ID,
+		Body:      r.Body,
+		CreatedAt: r.CreatedAt,
+	}
+}
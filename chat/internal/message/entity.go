@@ -0,0 +1,57 @@
+package message
+
+import "time"
+
+// Message is a chat message. A deleted message stays as a tombstone with an
+// empty body, so pages and replies referring to it keep working.
+//
+// A message may quote another one with ReplyToID. A message with ThreadID is
+// a reply in the side thread of that message, the thread root, and is not
+// listed in the chat history. Roots keep the count and time of their replies.
+type Message struct {
+	ID          int64      `db:"id"`
+	ChatID      int64      `db:"chat_id"`
+	SenderID    int64      `db:"sender_id"`
+	Body        string     `db:"body"`
+	ReplyToID   *int64     `db:"reply_to_id"`
+	ThreadID    *int64     `db:"thread_id"`
+	ReplyCount  int        `db:"reply_count"`
+	LastReplyAt *time.Time `db:"last_reply_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	EditedAt    *time.Time `db:"edited_at"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+	// ReplyTo is the quoted message, loaded by the service.
+	ReplyTo *Message `db:"-"`
+}
+
+func (m *Message) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Revision is a previous body of an edited message.
+type Revision struct {
+	ID        int64     `db:"id"`
+	MessageID int64     `db:"message_id"`
+	Body      string    `db:"body"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Participant follows a thread: the author of its root, everyone who replied
+// and members who read it. LastReadMessageID is the read marker in the thread.
+type Participant struct {
+	ThreadID          int64      `db:"thread_id"`
+	UserID            int64      `db:"user_id"`
+	LastReadMessageID int64      `db:"last_read_message_id"`
+	LastReadAt        *time.Time `db:"last_read_at"`
+	JoinedAt          time.Time  `db:"joined_at"`
+	// UnreadCount is the number of replies by others after the marker.
+	UnreadCount int64 `db:"unread_count"`
+}
+
+// Page is a slice of chat history or of a thread, newest messages first.
+type Page struct {
+	Messages []*Message
+	HasOlder bool
+	HasNewer bool
+}
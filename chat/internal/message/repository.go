@@ -0,0 +1,17 @@
+package message
+
+import "context"
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *Message) error
+	FindByID(ctx context.Context, chatID, id int64) (*Message, error)
+	FindByIDs(ctx context.Context, chatID int64, ids []int64) ([]*Message, error)
+	Update(ctx context.Context, message *Message) (bool, error)
+	Delete(ctx context.Context, message *Message) error
+	FindRevisions(ctx context.Context, messageID int64) ([]*Revision, error)
+	FindByChatID(ctx context.Context, chatID int64, query *ListMessagesQuery) ([]*Message, error)
+	FindByThreadID(ctx context.Context, threadID int64, query *ListMessagesQuery) ([]*Message, error)
+	FindParticipant(ctx context.Context, threadID, userID int64) (*Participant, error)
+	FindParticipants(ctx context.Context, threadID int64) ([]*Participant, error)
+	UpdateThreadRead(ctx context.Context, participant *Participant, messageID int64) (bool, error)
+}
@@ -0,0 +1,539 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+)
+
+type MessageRepository struct {
+	db *pg.Postgres
+}
+
+func NewMessageRepository(db *pg.Postgres) *MessageRepository {
+	return &MessageRepository{
+		db: db,
+	}
+}
+
+// Create inserts the message and moves the read marker of the sender to it,
+// in a single transaction, since senders have read the chat up to their own
+// message. Replies in a thread move the marker of the thread instead and
+// update the reply count of the root, whose author becomes a participant.
+func (r *MessageRepository) Create(ctx context.Context, m *message.Message) error {
+	defer metrics.Storage.Observe("postgres", "message", "Create", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.db.Sb.
+		Insert("messages").
+		Columns("chat_id", "sender_id", "body", "reply_to_id", "thread_id").
+		Values(m.ChatID, m.SenderID, m.Body, m.ReplyToID, m.ThreadID).
+		Suffix("RETURNING id, created_at, updated_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRowContext(ctx, query, args...).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return err
+	}
+
+	if m.ThreadID != nil {
+		if err = r.addReply(ctx, tx, m); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	query, args, err = r.db.Sb.
+		Update("chat_members").
+		Set("last_read_message_id", m.ID).
+		Set("last_read_at", m.CreatedAt).
+		Where(squirrel.And{
+			squirrel.Eq{
+				"chat_id": m.ChatID,
+				"user_id": m.SenderID,
+			},
+			squirrel.Lt{
+				"last_read_message_id": m.ID,
+			},
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addReply counts the reply m on its thread root and updates the
+// participants of the thread within tx.
+func (r *MessageRepository) addReply(ctx context.Context, tx *sqlx.Tx, m *message.Message) error {
+	query, args, err := r.db.Sb.
+		Update("messages").
+		Set("reply_count", squirrel.Expr("reply_count + 1")).
+		Set("last_reply_at", m.CreatedAt).
+		Where(squirrel.Eq{
+			"id": *m.ThreadID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	// Replies to the root author are unread for them until they open the
+	// thread
+	query, args, err = r.db.Sb.
+		Insert("thread_members").
+		Columns("thread_id", "user_id").
+		Values(*m.ThreadID, squirrel.Expr("(SELECT sender_id FROM messages WHERE id = ?)", *m.ThreadID)).
+		Suffix("ON CONFLICT (thread_id, user_id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	query, args, err = r.db.Sb.
+		Insert("thread_members").
+		Columns("thread_id", "user_id", "last_read_message_id", "last_read_at").
+		Values(*m.ThreadID, m.SenderID, m.ID, m.CreatedAt).
+		Suffix(`ON CONFLICT (thread_id, user_id) DO UPDATE
+			SET last_read_message_id = EXCLUDED.last_read_message_id, last_read_at = EXCLUDED.last_read_at
+			WHERE thread_members.last_read_message_id < EXCLUDED.last_read_message_id`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *MessageRepository) FindByID(ctx context.Context, chatID, id int64) (*message.Message, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindByID", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("messages").
+		Where(squirrel.Eq{
+			"id":      id,
+			"chat_id": chatID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var m message.Message
+	if err = r.db.Sqlx.GetContext(ctx, &m, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Update replaces the body of the message with m.Body and stores the
+// previous body as a revision, in a single transaction. Deleted messages
+// cannot be edited. An unchanged body is not stored again and false is
+// returned.
+func (r *MessageRepository) Update(ctx context.Context, m *message.Message) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "message", "Update", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.db.Sb.
+		Select("body").
+		From("messages").
+		Where(squirrel.Eq{
+			"id":         m.ID,
+			"chat_id":    m.ChatID,
+			"deleted_at": nil,
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var previousBody string
+	if err = tx.GetContext(ctx, &previousBody, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, apperrors.ErrNotFound
+		}
+		return false, err
+	}
+	if previousBody == m.Body {
+		return false, nil
+	}
+
+	query, args, err = r.db.Sb.
+		Insert("message_revisions").
+		Columns("message_id", "body").
+		Values(m.ID, previousBody).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return false, err
+	}
+
+	query, args, err = r.db.Sb.
+		Update("messages").
+		Set("body", m.Body).
+		Set("edited_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Set("updated_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Where(squirrel.Eq{
+			"id": m.ID,
+		}).
+		Suffix("RETURNING edited_at, updated_at").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.QueryRowContext(ctx, query, args...).Scan(&m.EditedAt, &m.UpdatedAt); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete turns the message into a tombstone. The body and the revisions are
+// removed, since the author retracted them. Deleting a reply in a thread
+// updates the reply stats of its root in the same transaction.
+func (r *MessageRepository) Delete(ctx context.Context, m *message.Message) error {
+	defer metrics.Storage.Observe("postgres", "message", "Delete", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.db.Sb.
+		Update("messages").
+		Set("body", "").
+		Set("deleted_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Set("updated_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Where(squirrel.Eq{
+			"id":         m.ID,
+			"chat_id":    m.ChatID,
+			"deleted_at": nil,
+		}).
+		Suffix("RETURNING deleted_at, updated_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRowContext(ctx, query, args...).Scan(&m.DeletedAt, &m.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+
+	query, args, err = r.db.Sb.
+		Delete("message_revisions").
+		Where(squirrel.Eq{
+			"message_id": m.ID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	if m.ThreadID != nil {
+		if err = r.removeReply(ctx, tx, *m.ThreadID); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	m.Body = ""
+
+	return nil
+}
+
+// removeReply takes a deleted reply out of the stats of the thread root. The
+// last reply time falls back to the latest reply left.
+func (r *MessageRepository) removeReply(ctx context.Context, tx *sqlx.Tx, threadID int64) error {
+	query, args, err := r.db.Sb.
+		Update("messages").
+		Set("reply_count", squirrel.Expr("GREATEST(reply_count - 1, 0)")).
+		Set("last_reply_at", squirrel.Expr(
+			"(SELECT MAX(created_at) FROM messages WHERE thread_id = ? AND deleted_at IS NULL)", threadID,
+		)).
+		Where(squirrel.Eq{
+			"id": threadID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// FindByIDs returns the messages of the chat with the given IDs, in no
+// particular order. Missing messages are skipped.
+func (r *MessageRepository) FindByIDs(ctx context.Context, chatID int64, ids []int64) ([]*message.Message, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindByIDs", time.Now())
+
+	messages := make([]*message.Message, 0, len(ids))
+	if len(ids) == 0 {
+		return messages, nil
+	}
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("messages").
+		Where(squirrel.Eq{
+			"id":      ids,
+			"chat_id": chatID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.db.Sqlx.SelectContext(ctx, &messages, query, args...); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// FindRevisions returns the previous bodies of the message, oldest first.
+func (r *MessageRepository) FindRevisions(ctx context.Context, messageID int64) ([]*message.Revision, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindRevisions", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("message_revisions").
+		Where(squirrel.Eq{
+			"message_id": messageID,
+		}).
+		OrderBy("id ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*message.Revision, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &revisions, query, args...); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// FindByChatID returns up to query.Limit messages of the chat history, newest
+// first. Replies in threads are not part of the history. If query.Before is
+// set, the messages right before that message are returned, and if
+// query.After is set, the ones right after it. Around is handled by the
+// service.
+func (r *MessageRepository) FindByChatID(ctx context.Context, chatID int64, q *message.ListMessagesQuery) ([]*message.Message, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindByChatID", time.Now())
+
+	return r.findPage(ctx, squirrel.Eq{
+		"chat_id":   chatID,
+		"thread_id": nil,
+	}, q)
+}
+
+// FindByThreadID returns up to query.Limit replies in the thread, newest
+// first, paginated like FindByChatID.
+func (r *MessageRepository) FindByThreadID(ctx context.Context, threadID int64, q *message.ListMessagesQuery) ([]*message.Message, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindByThreadID", time.Now())
+
+	return r.findPage(ctx, squirrel.Eq{
+		"thread_id": threadID,
+	}, q)
+}
+
+func (r *MessageRepository) findPage(ctx context.Context, filter squirrel.Eq, q *message.ListMessagesQuery) ([]*message.Message, error) {
+	where := squirrel.And{filter}
+	orderBy := "id DESC"
+	switch {
+	case q.Before > 0:
+		where = append(where, squirrel.Lt{
+			"id": q.Before,
+		})
+	case q.After > 0:
+		where = append(where, squirrel.Gt{
+			"id": q.After,
+		})
+		// The oldest messages after the cursor, reversed below
+		orderBy = "id ASC"
+	}
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("messages").
+		Where(where).
+		OrderBy(orderBy).
+		Limit(q.Limit).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*message.Message, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &messages, query, args...); err != nil {
+		return nil, err
+	}
+
+	if q.After > 0 {
+		slices.Reverse(messages)
+	}
+
+	return messages, nil
+}
+
+// participantColumns select a thread participant with the count of replies
+// by others after the read marker.
+var participantColumns = []string{
+	"tm.*",
+	`(SELECT count(*) FROM messages m
+		WHERE m.thread_id = tm.thread_id AND m.id > tm.last_read_message_id AND m.sender_id <> tm.user_id AND m.deleted_at IS NULL
+	) AS unread_count`,
+}
+
+func (r *MessageRepository) FindParticipant(ctx context.Context, threadID, userID int64) (*message.Participant, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindParticipant", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select(participantColumns...).
+		From("thread_members tm").
+		Where(squirrel.Eq{
+			"tm.thread_id": threadID,
+			"tm.user_id":   userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var p message.Participant
+	if err = r.db.Sqlx.GetContext(ctx, &p, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// FindParticipants lists the participants of the thread who are still members
+// of the chat.
+func (r *MessageRepository) FindParticipants(ctx context.Context, threadID int64) ([]*message.Participant, error) {
+	defer metrics.Storage.Observe("postgres", "message", "FindParticipants", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select(participantColumns...).
+		From("thread_members tm").
+		Join("messages root ON root.id = tm.thread_id").
+		Join("chat_members cm ON cm.chat_id = root.chat_id AND cm.user_id = tm.user_id").
+		Where(squirrel.Eq{
+			"tm.thread_id": threadID,
+		}).
+		OrderBy("tm.joined_at ASC", "tm.user_id ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	participants := make([]*message.Participant, 0)
+	if err = r.db.Sqlx.SelectContext(ctx, &participants, query, args...); err != nil {
+		return nil, err
+	}
+
+	return participants, nil
+}
+
+// UpdateThreadRead advances the read marker of the participant in the thread
+// to messageID, making the user a participant if needed. The caller checks
+// the message is a reply in the thread. Markers never move back, it reports
+// whether the marker moved.
+func (r *MessageRepository) UpdateThreadRead(ctx context.Context, p *message.Participant, messageID int64) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "message", "UpdateThreadRead", time.Now())
+
+	query, args, err := r.db.Sb.
+		Insert("thread_members").
+		Columns("thread_id", "user_id", "last_read_message_id", "last_read_at").
+		Values(p.ThreadID, p.UserID, messageID, squirrel.Expr("CURRENT_TIMESTAMP")).
+		Suffix(`ON CONFLICT (thread_id, user_id) DO UPDATE
+			SET last_read_message_id = EXCLUDED.last_read_message_id, last_read_at = EXCLUDED.last_read_at
+			WHERE thread_members.last_read_message_id < EXCLUDED.last_read_message_id
+			RETURNING last_read_message_id, last_read_at`).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	err = r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&p.LastReadMessageID, &p.LastReadAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
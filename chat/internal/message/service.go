@@ -0,0 +1,13 @@
+package message
+
+import "context"
+
+type MessageService interface {
+	SendMessage(ctx context.Context, userID, chatID int64, req *SendMessageRequest) (*Message, error)
+	EditMessage(ctx context.Context, userID, chatID, messageID int64, req *EditMessageRequest) (*Message, error)
+	DeleteMessage(ctx context.Context, userID, chatID, messageID int64) (*Message, error)
+	ListRevisions(ctx context.Context, userID, chatID, messageID int64) ([]*Revision, error)
+	ListMessages(ctx context.Context, userID, chatID int64, query *ListMessagesQuery) (*Page, error)
+	ListThread(ctx context.Context, userID, chatID, messageID int64, query *ListMessagesQuery) (*Page, error)
+	MarkThreadRead(ctx context.Context, userID, chatID, messageID int64, req *MarkThreadReadRequest) (*Participant, error)
+}
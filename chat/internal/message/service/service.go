@@ -0,0 +1,451 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+)
+
+type MessageServiceDeps struct {
+	MessageRepo message.MessageRepository
+	ChatRepo    chat.ChatRepository
+	Publisher   event.Publisher
+}
+
+type MessageService struct {
+	messageRepo message.MessageRepository
+	chatRepo    chat.ChatRepository
+	publisher   event.Publisher
+}
+
+func NewMessageService(deps MessageServiceDeps) *MessageService {
+	return &MessageService{
+		messageRepo: deps.MessageRepo,
+		chatRepo:    deps.ChatRepo,
+		publisher:   deps.Publisher,
+	}
+}
+
+func (s *MessageService) SendMessage(ctx context.Context, userID, chatID int64, req *message.SendMessageRequest) (*message.Message, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	m := &message.Message{
+		ChatID:   chatID,
+		SenderID: userID,
+		Body:     req.Body,
+	}
+
+	if req.ThreadID != nil {
+		parent, err := s.findMessage(ctx, chatID, *req.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+		// Replying in the thread of a reply continues the same thread
+		m.ThreadID = &parent.ID
+		if parent.ThreadID != nil {
+			m.ThreadID = parent.ThreadID
+		}
+	}
+
+	if req.ReplyToID != nil {
+		quoted, err := s.findMessage(ctx, chatID, *req.ReplyToID)
+		if err != nil {
+			return nil, err
+		}
+		if !inThread(quoted, m.ThreadID) {
+			return nil, apperrors.ErrInvalidReply
+		}
+		m.ReplyToID = &quoted.ID
+		m.ReplyTo = quoted
+	}
+
+	if err := s.messageRepo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+	metrics.MessagesSent.Inc()
+
+	s.publish(ctx, event.TypeMessageCreated, chatID, message.NewMessageResponse(m))
+	if m.ThreadID != nil {
+		s.notifyThread(ctx, m)
+	}
+
+	return m, nil
+}
+
+// notifyThread tells the chat about the new reply stats of the thread of m
+// and notifies the other participants of the thread.
+func (s *MessageService) notifyThread(ctx context.Context, m *message.Message) {
+	s.publishThread(ctx, m.ChatID, *m.ThreadID)
+
+	participants, err := s.messageRepo.FindParticipants(ctx, *m.ThreadID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding thread participants", "thread_id", *m.ThreadID, "error", err)
+		return
+	}
+
+	for _, p := range participants {
+		if p.UserID == m.SenderID {
+			continue
+		}
+		s.publish(ctx, event.TypeThreadReplied, m.ChatID, event.ThreadReplyData{
+			UserID:      p.UserID,
+			ThreadID:    p.ThreadID,
+			MessageID:   m.ID,
+			SenderID:    m.SenderID,
+			UnreadCount: p.UnreadCount,
+		})
+	}
+}
+
+// publishThread tells the chat about the reply stats of the thread.
+func (s *MessageService) publishThread(ctx context.Context, chatID, threadID int64) {
+	root, err := s.messageRepo.FindByID(ctx, chatID, threadID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding thread root", "thread_id", threadID, "error", err)
+		return
+	}
+	if root == nil {
+		return
+	}
+
+	s.publish(ctx, event.TypeThreadUpdated, chatID, event.ThreadData{
+		ThreadID:    root.ID,
+		ReplyCount:  root.ReplyCount,
+		LastReplyAt: root.LastReplyAt,
+	})
+}
+
+// EditMessage replaces the body of a message sent by the user. The previous
+// body is kept as a revision.
+func (s *MessageService) EditMessage(ctx context.Context, userID, chatID, messageID int64, req *message.EditMessageRequest) (*message.Message, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	m, err := s.findMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.SenderID != userID {
+		return nil, apperrors.ErrForbidden
+	}
+
+	m.Body = req.Body
+	updated, err := s.messageRepo.Update(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.loadQuotes(ctx, chatID, []*message.Message{m}); err != nil {
+		return nil, err
+	}
+
+	// Saving the same body changes nothing members need to know about
+	if updated {
+		s.publish(ctx, event.TypeMessageUpdated, chatID, message.NewMessageResponse(m))
+	}
+
+	return m, nil
+}
+
+// DeleteMessage leaves a tombstone in place of a message. Authors may delete
+// their messages, chat admins any message.
+func (s *MessageService) DeleteMessage(ctx context.Context, userID, chatID, messageID int64) (*message.Message, error) {
+	member, err := s.checkMember(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := s.findMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.SenderID != userID && !member.Role.IsAdmin() {
+		return nil, apperrors.ErrForbidden
+	}
+
+	if err = s.messageRepo.Delete(ctx, m); err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, event.TypeMessageDeleted, chatID, message.NewMessageResponse(m))
+	if m.ThreadID != nil {
+		s.publishThread(ctx, chatID, *m.ThreadID)
+	}
+
+	return m, nil
+}
+
+// ListRevisions returns the previous bodies of a message, oldest first.
+func (s *MessageService) ListRevisions(ctx context.Context, userID, chatID, messageID int64) ([]*message.Revision, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	m, err := s.findMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.messageRepo.FindRevisions(ctx, m.ID)
+}
+
+func (s *MessageService) ListMessages(ctx context.Context, userID, chatID int64, query *message.ListMessagesQuery) (*message.Page, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	return s.listPage(ctx, chatID, query, func(q *message.ListMessagesQuery) ([]*message.Message, error) {
+		return s.messageRepo.FindByChatID(ctx, chatID, q)
+	})
+}
+
+// ListThread returns a page of the replies in the thread of a message of the
+// chat history. The thread of a deleted message can still be read.
+func (s *MessageService) ListThread(ctx context.Context, userID, chatID, messageID int64, query *message.ListMessagesQuery) (*message.Page, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	root, err := s.messageRepo.FindByID(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil || root.ThreadID != nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return s.listPage(ctx, chatID, query, func(q *message.ListMessagesQuery) ([]*message.Message, error) {
+		return s.messageRepo.FindByThreadID(ctx, root.ID, q)
+	})
+}
+
+// MarkThreadRead advances the read marker of the user in the thread of a
+// message to a reply in it. Reading a thread makes the user a participant,
+// so they are notified of further replies.
+func (s *MessageService) MarkThreadRead(ctx context.Context, userID, chatID, messageID int64, req *message.MarkThreadReadRequest) (*message.Participant, error) {
+	if _, err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	reply, err := s.messageRepo.FindByID(ctx, chatID, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil || reply.ThreadID == nil || *reply.ThreadID != messageID {
+		return nil, apperrors.ErrNotFound
+	}
+
+	_, err = s.messageRepo.UpdateThreadRead(ctx, &message.Participant{
+		ThreadID: messageID,
+		UserID:   userID,
+	}, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read back for the unread count. The marker may also be past the
+	// message already, moved by another device meanwhile.
+	p, err := s.messageRepo.FindParticipant(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return p, nil
+}
+
+// listPage returns a page of the messages found by find, which lists like
+// MessageRepository.FindByChatID, with their quotes.
+func (s *MessageService) listPage(ctx context.Context, chatID int64, query *message.ListMessagesQuery, find func(q *message.ListMessagesQuery) ([]*message.Message, error)) (*message.Page, error) {
+	var page *message.Page
+	var err error
+	if query.Around > 0 {
+		page, err = s.listAround(query.Around, query.Limit, find)
+	} else {
+		page, err = s.listNext(query, find)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.loadQuotes(ctx, chatID, page.Messages); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// listNext returns the latest messages or the ones before or after a cursor.
+func (s *MessageService) listNext(query *message.ListMessagesQuery, find func(q *message.ListMessagesQuery) ([]*message.Message, error)) (*message.Page, error) {
+	// One extra message tells whether there is more in the listed direction
+	messages, err := find(&message.ListMessagesQuery{
+		Before: query.Before,
+		After:  query.After,
+		Limit:  query.Limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &message.Page{
+		Messages: messages,
+	}
+	more := uint64(len(messages)) > query.Limit
+
+	switch {
+	case query.After > 0:
+		if more {
+			page.Messages = messages[1:]
+		}
+		page.HasNewer = more
+
+		// The page may start at the first message, so look for one older
+		// than the oldest listed message
+		before := query.After + 1
+		if len(page.Messages) > 0 {
+			before = page.Messages[len(page.Messages)-1].ID
+		}
+		older, err := find(&message.ListMessagesQuery{
+			Before: before,
+			Limit:  1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		page.HasOlder = len(older) > 0
+	default:
+		if more {
+			page.Messages = messages[:query.Limit]
+		}
+		page.HasOlder = more
+		page.HasNewer = query.Before > 0
+	}
+
+	return page, nil
+}
+
+// listAround returns a page with the message and the messages right before
+// and after it, the older half taking the odd one.
+func (s *MessageService) listAround(messageID int64, limit uint64, find func(q *message.ListMessagesQuery) ([]*message.Message, error)) (*message.Page, error) {
+	newerLimit := limit / 2
+	olderLimit := limit - newerLimit
+
+	older, err := find(&message.ListMessagesQuery{
+		Before: messageID + 1,
+		Limit:  olderLimit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(older) == 0 || older[0].ID != messageID {
+		return nil, apperrors.ErrNotFound
+	}
+
+	newer, err := find(&message.ListMessagesQuery{
+		After: messageID,
+		Limit: newerLimit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &message.Page{
+		HasOlder: uint64(len(older)) > olderLimit,
+		HasNewer: uint64(len(newer)) > newerLimit,
+	}
+	if page.HasOlder {
+		older = older[:olderLimit]
+	}
+	if page.HasNewer {
+		newer = newer[1:]
+	}
+	page.Messages = append(newer, older...)
+
+	return page, nil
+}
+
+// loadQuotes sets ReplyTo of the messages quoting another message.
+func (s *MessageService) loadQuotes(ctx context.Context, chatID int64, messages []*message.Message) error {
+	ids := make([]int64, 0)
+	for _, m := range messages {
+		if m.ReplyToID != nil {
+			ids = append(ids, *m.ReplyToID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quoted, err := s.messageRepo.FindByIDs(ctx, chatID, ids)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]*message.Message, len(quoted))
+	for _, q := range quoted {
+		byID[q.ID] = q
+	}
+	for _, m := range messages {
+		if m.ReplyToID != nil {
+			m.ReplyTo = byID[*m.ReplyToID]
+		}
+	}
+
+	return nil
+}
+
+func (s *MessageService) checkMember(ctx context.Context, userID, chatID int64) (*chat.Member, error) {
+	member, err := s.chatRepo.FindMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return member, nil
+}
+
+// findMessage returns a message of the chat that has not been deleted.
+func (s *MessageService) findMessage(ctx context.Context, chatID, messageID int64) (*message.Message, error) {
+	m, err := s.messageRepo.FindByID(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.Deleted() {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return m, nil
+}
+
+// inThread reports whether m is the root of or a reply in the thread with
+// threadID, or a message of the chat history if threadID is nil.
+func inThread(m *message.Message, threadID *int64) bool {
+	if threadID == nil {
+		return m.ThreadID == nil
+	}
+
+	return m.ID == *threadID || (m.ThreadID != nil && *m.ThreadID == *threadID)
+}
+
+// publish notifies connected clients about a change that has already been
+// persisted, so delivery failures are logged rather than returned.
+func (s *MessageService) publish(ctx context.Context, eventType event.Type, chatID int64, data any) {
+	e, err := event.New(eventType, chatID, data)
+	if err == nil {
+		err = s.publisher.Publish(ctx, e)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error publishing event", "event_type", eventType, "chat_id", chatID, "error", err)
+	}
+}
@@ -0,0 +1,567 @@
+package service_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	chatpg "github.com/maximegorov13/chat-app/chat/internal/chat/repository/pg"
+	chatservice "github.com/maximegorov13/chat-app/chat/internal/chat/service"
+	memorybus "github.com/maximegorov13/chat-app/chat/internal/event/bus/memory"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	messagepg "github.com/maximegorov13/chat-app/chat/internal/message/repository/pg"
+	messageservice "github.com/maximegorov13/chat-app/chat/internal/message/service"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/ws"
+)
+
+type testDependencies struct {
+	chatService    chat.ChatService
+	messageService message.MessageService
+	cleanupChat    func(chatID int64)
+}
+
+func getUniqueUserID() int64 {
+	return rand.Int64N(1<<62) + 1<<62
+}
+
+func setupTest(t testing.TB) *testDependencies {
+	t.Helper()
+
+	conf, err := configs.Load("../../../.env")
+	require.NoError(t, err)
+
+	pgClient, err := pg.NewPostgres(conf)
+	require.NoError(t, err)
+
+	chatRepo := chatpg.NewChatRepository(pgClient)
+	messageRepo := messagepg.NewMessageRepository(pgClient)
+
+	cleanupChat := func(chatID int64) {
+		query, args, err := pgClient.Sb.
+			Delete("chats").
+			Where(squirrel.Eq{
+				"id": chatID,
+			}).
+			ToSql()
+		if err != nil {
+			t.Logf("cleanup query build error: %v", err)
+		}
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	bus := memorybus.NewBus(nil)
+	t.Cleanup(func() {
+		bus.Close()
+	})
+
+	hub := ws.NewHub(ws.HubDeps{
+		ChatRepo: chatRepo,
+		Bus:      bus,
+	})
+
+	return &testDependencies{
+		chatService: chatservice.NewChatService(chatservice.ChatServiceDeps{
+			ChatRepo:  chatRepo,
+			Publisher: hub,
+		}),
+		messageService: messageservice.NewMessageService(messageservice.MessageServiceDeps{
+			MessageRepo: messageRepo,
+			ChatRepo:    chatRepo,
+			Publisher:   hub,
+		}),
+		cleanupChat: cleanupChat,
+	}
+}
+
+func TestMessageService_SendMessage(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful send message", func(t *testing.T) {
+		userID := getUniqueUserID()
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Test Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+		require.NotZero(t, m.ID)
+		require.Equal(t, c.ID, m.ChatID)
+		require.Equal(t, userID, m.SenderID)
+		require.Equal(t, "hello", m.Body)
+		require.False(t, m.CreatedAt.IsZero())
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		userID := getUniqueUserID()
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Test Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.messageService.SendMessage(ctx, getUniqueUserID(), c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestMessageService_ListMessages(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("pages through history", func(t *testing.T) {
+		userID := getUniqueUserID()
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Test Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		sent := make([]*message.Message, 0, 5)
+		for range 5 {
+			m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+				Body: "hello",
+			})
+			require.NoError(t, err)
+			sent = append(sent, m)
+		}
+
+		page, err := deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			Limit: 3,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 3)
+		require.Equal(t, sent[4].ID, page.Messages[0].ID)
+		require.Equal(t, sent[2].ID, page.Messages[2].ID)
+		require.True(t, page.HasOlder)
+		require.False(t, page.HasNewer)
+
+		page, err = deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			Before: page.Messages[2].ID,
+			Limit:  3,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 2)
+		require.Equal(t, sent[1].ID, page.Messages[0].ID)
+		require.Equal(t, sent[0].ID, page.Messages[1].ID)
+		require.False(t, page.HasOlder)
+		require.True(t, page.HasNewer)
+
+		page, err = deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			After: sent[0].ID,
+			Limit: 3,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 3)
+		require.Equal(t, sent[3].ID, page.Messages[0].ID)
+		require.Equal(t, sent[1].ID, page.Messages[2].ID)
+		require.True(t, page.HasOlder)
+		require.True(t, page.HasNewer)
+
+		page, err = deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			After: sent[0].ID - 1,
+			Limit: 3,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 3)
+		require.Equal(t, sent[0].ID, page.Messages[2].ID)
+		require.False(t, page.HasOlder)
+		require.True(t, page.HasNewer)
+	})
+
+	t.Run("window around a message", func(t *testing.T) {
+		userID := getUniqueUserID()
+		c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+			Type:  chat.TypeGroup,
+			Title: "Test Group",
+		})
+		t.Cleanup(func() {
+			deps.cleanupChat(c.ID)
+		})
+		require.NoError(t, err)
+
+		sent := make([]*message.Message, 0, 7)
+		for range 7 {
+			m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+				Body: "hello",
+			})
+			require.NoError(t, err)
+			sent = append(sent, m)
+		}
+
+		page, err := deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			Around: sent[3].ID,
+			Limit:  3,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 3)
+		require.Equal(t, sent[4].ID, page.Messages[0].ID)
+		require.Equal(t, sent[3].ID, page.Messages[1].ID)
+		require.Equal(t, sent[2].ID, page.Messages[2].ID)
+		require.True(t, page.HasOlder)
+		require.True(t, page.HasNewer)
+
+		page, err = deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			Around: sent[6].ID,
+			Limit:  4,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 2)
+		require.Equal(t, sent[6].ID, page.Messages[0].ID)
+		require.True(t, page.HasOlder)
+		require.False(t, page.HasNewer)
+
+		_, err = deps.messageService.ListMessages(ctx, userID, c.ID, &message.ListMessagesQuery{
+			Around: sent[6].ID + 1000,
+			Limit:  3,
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestMessageService_EditMessage(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	userID := getUniqueUserID()
+	memberID := getUniqueUserID()
+	c, err := deps.chatService.CreateChat(ctx, userID, &chat.CreateChatRequest{
+		Type:  chat.TypeGroup,
+		Title: "Test Group",
+	})
+	t.Cleanup(func() {
+		deps.cleanupChat(c.ID)
+	})
+	require.NoError(t, err)
+
+	_, err = deps.chatService.AddMember(ctx, userID, c.ID, &chat.AddMemberRequest{
+		UserID: memberID,
+		Role:   chat.RoleMember,
+	})
+	require.NoError(t, err)
+
+	t.Run("successful edit keeps revisions", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+			Body: "helo",
+		})
+		require.NoError(t, err)
+
+		edited, err := deps.messageService.EditMessage(ctx, userID, c.ID, m.ID, &message.EditMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "hello", edited.Body)
+		require.NotNil(t, edited.EditedAt)
+
+		_, err = deps.messageService.EditMessage(ctx, userID, c.ID, m.ID, &message.EditMessageRequest{
+			Body: "hello!",
+		})
+		require.NoError(t, err)
+
+		revisions, err := deps.messageService.ListRevisions(ctx, memberID, c.ID, m.ID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		require.Equal(t, "helo", revisions[0].Body)
+		require.Equal(t, "hello", revisions[1].Body)
+	})
+
+	t.Run("unchanged body", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		edited, err := deps.messageService.EditMessage(ctx, userID, c.ID, m.ID, &message.EditMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+		require.Nil(t, edited.EditedAt)
+
+		revisions, err := deps.messageService.ListRevisions(ctx, userID, c.ID, m.ID)
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+	})
+
+	t.Run("not the author", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		_, err = deps.messageService.EditMessage(ctx, memberID, c.ID, m.ID, &message.EditMessageRequest{
+			Body: "bye",
+		})
+		require.ErrorIs(t, err, apperrors.ErrForbidden)
+	})
+
+	t.Run("deleted message", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, userID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		_, err = deps.messageService.DeleteMessage(ctx, userID, c.ID, m.ID)
+		require.NoError(t, err)
+
+		_, err = deps.messageService.EditMessage(ctx, userID, c.ID, m.ID, &message.EditMessageRequest{
+			Body: "bye",
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestMessageService_DeleteMessage(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	ownerID := getUniqueUserID()
+	memberID := getUniqueUserID()
+	c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+		Type:  chat.TypeGroup,
+		Title: "Test Group",
+	})
+	t.Cleanup(func() {
+		deps.cleanupChat(c.ID)
+	})
+	require.NoError(t, err)
+
+	_, err = deps.chatService.AddMember(ctx, ownerID, c.ID, &chat.AddMemberRequest{
+		UserID: memberID,
+		Role:   chat.RoleMember,
+	})
+	require.NoError(t, err)
+
+	t.Run("author leaves a tombstone", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		deleted, err := deps.messageService.DeleteMessage(ctx, memberID, c.ID, m.ID)
+		require.NoError(t, err)
+		require.True(t, deleted.Deleted())
+		require.Empty(t, deleted.Body)
+
+		page, err := deps.messageService.ListMessages(ctx, ownerID, c.ID, &message.ListMessagesQuery{
+			Around: m.ID,
+			Limit:  1,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.True(t, page.Messages[0].Deleted())
+
+		_, err = deps.messageService.DeleteMessage(ctx, memberID, c.ID, m.ID)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("admin deletes any message", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		_, err = deps.messageService.DeleteMessage(ctx, ownerID, c.ID, m.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("member cannot delete others' messages", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, ownerID, c.ID, &message.SendMessageRequest{
+			Body: "hello",
+		})
+		require.NoError(t, err)
+
+		_, err = deps.messageService.DeleteMessage(ctx, memberID, c.ID, m.ID)
+		require.ErrorIs(t, err, apperrors.ErrForbidden)
+	})
+}
+
+func TestMessageService_Threads(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	ownerID := getUniqueUserID()
+	memberID := getUniqueUserID()
+	c, err := deps.chatService.CreateChat(ctx, ownerID, &chat.CreateChatRequest{
+		Type:      chat.TypeGroup,
+		Title:     "Test Group",
+		MemberIDs: []int64{memberID},
+	})
+	t.Cleanup(func() {
+		deps.cleanupChat(c.ID)
+	})
+	require.NoError(t, err)
+
+	root, err := deps.messageService.SendMessage(ctx, ownerID, c.ID, &message.SendMessageRequest{
+		Body: "root",
+	})
+	require.NoError(t, err)
+
+	replies := make([]*message.Message, 0, 3)
+	for range 3 {
+		m, err := deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body:     "reply",
+			ThreadID: &root.ID,
+		})
+		require.NoError(t, err)
+		replies = append(replies, m)
+	}
+
+	t.Run("replies stay out of the history", func(t *testing.T) {
+		page, err := deps.messageService.ListMessages(ctx, ownerID, c.ID, &message.ListMessagesQuery{
+			Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.Equal(t, root.ID, page.Messages[0].ID)
+		require.Equal(t, 3, page.Messages[0].ReplyCount)
+		require.NotNil(t, page.Messages[0].LastReplyAt)
+	})
+
+	t.Run("pages through the thread", func(t *testing.T) {
+		page, err := deps.messageService.ListThread(ctx, ownerID, c.ID, root.ID, &message.ListMessagesQuery{
+			Limit: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 2)
+		require.Equal(t, replies[2].ID, page.Messages[0].ID)
+		require.True(t, page.HasOlder)
+
+		page, err = deps.messageService.ListThread(ctx, ownerID, c.ID, root.ID, &message.ListMessagesQuery{
+			Before: page.Messages[1].ID,
+			Limit:  2,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.Equal(t, replies[0].ID, page.Messages[0].ID)
+		require.False(t, page.HasOlder)
+
+		// A reply has no thread of its own
+		_, err = deps.messageService.ListThread(ctx, ownerID, c.ID, replies[0].ID, &message.ListMessagesQuery{
+			Limit: 2,
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("reply to a reply continues the thread", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, ownerID, c.ID, &message.SendMessageRequest{
+			Body:     "nested",
+			ThreadID: &replies[0].ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, root.ID, *m.ThreadID)
+		replies = append(replies, m)
+	})
+
+	t.Run("quotes a message", func(t *testing.T) {
+		m, err := deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body:      "quote",
+			ReplyToID: &root.ID,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, m.ReplyTo)
+		require.Equal(t, "root", m.ReplyTo.Body)
+
+		page, err := deps.messageService.ListMessages(ctx, ownerID, c.ID, &message.ListMessagesQuery{
+			Limit: 1,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, page.Messages[0].ReplyTo)
+		require.Equal(t, root.ID, page.Messages[0].ReplyTo.ID)
+
+		// Quotes stay within the timeline they are sent to
+		_, err = deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body:      "quote",
+			ReplyToID: &replies[0].ID,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidReply)
+
+		_, err = deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body:      "quote",
+			ThreadID:  &root.ID,
+			ReplyToID: &replies[0].ID,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("thread read markers", func(t *testing.T) {
+		// The author of the root follows the thread from the first reply
+		p, err := deps.messageService.MarkThreadRead(ctx, ownerID, c.ID, root.ID, &message.MarkThreadReadRequest{
+			MessageID: replies[1].ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, replies[1].ID, p.LastReadMessageID)
+		require.NotNil(t, p.LastReadAt)
+		require.Equal(t, int64(2), p.UnreadCount)
+
+		// Markers do not move back
+		p, err = deps.messageService.MarkThreadRead(ctx, ownerID, c.ID, root.ID, &message.MarkThreadReadRequest{
+			MessageID: replies[0].ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, replies[1].ID, p.LastReadMessageID)
+
+		_, err = deps.messageService.MarkThreadRead(ctx, ownerID, c.ID, root.ID, &message.MarkThreadReadRequest{
+			MessageID: root.ID,
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+
+	t.Run("deleting a reply updates the thread", func(t *testing.T) {
+		findRoot := func() *message.Message {
+			page, err := deps.messageService.ListMessages(ctx, ownerID, c.ID, &message.ListMessagesQuery{
+				Around: root.ID,
+				Limit:  1,
+			})
+			require.NoError(t, err)
+			require.Len(t, page.Messages, 1)
+			return page.Messages[0]
+		}
+		before := findRoot()
+
+		m, err := deps.messageService.SendMessage(ctx, memberID, c.ID, &message.SendMessageRequest{
+			Body:     "reply",
+			ThreadID: &root.ID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, before.ReplyCount+1, findRoot().ReplyCount)
+
+		_, err = deps.messageService.DeleteMessage(ctx, memberID, c.ID, m.ID)
+		require.NoError(t, err)
+
+		after := findRoot()
+		require.Equal(t, before.ReplyCount, after.ReplyCount)
+		require.NotNil(t, after.LastReplyAt)
+		require.True(t, before.LastReplyAt.Equal(*after.LastReplyAt))
+	})
+}
@@ -0,0 +1,28 @@
+// Package metrics holds the Prometheus collectors of the Chat service. They
+// are registered with the default registry, which is served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	pkgmetrics "github.com/maximegorov13/chat-app/id/pkg/metrics"
+)
+
+const namespace = "chat"
+
+var (
+	HTTP    = pkgmetrics.NewHTTPMetrics(prometheus.DefaultRegisterer, namespace)
+	Storage = pkgmetrics.NewStorageMetrics(prometheus.DefaultRegisterer, namespace)
+
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Open WebSocket connections.",
+	})
+	MessagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages sent over HTTP and WebSocket, rate() gives messages per second.",
+	})
+)
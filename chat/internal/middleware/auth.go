@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/verifier"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/appcontext"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+	"github.com/maximegorov13/chat-app/chat/internal/revocation"
+)
+
+type AuthDeps struct {
+	Conf       *configs.Config
+	Verifier   *verifier.Verifier
+	Revocation *revocation.Checker
+}
+
+// Auth authenticates requests with access tokens issued by the ID service.
+// Tokens are verified locally against the ID service public keys, while
+// logout and session revocation are checked with the ID service, cached
+// for a short time.
+func Auth(next http.Handler, deps AuthDeps) http.Handler {
+	return deps.Verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := verifier.ClaimsFromContext(r.Context())
+		if !ok {
+			res.Error(w, apperrors.ErrUnauthorized)
+			return
+		}
+
+		userID, err := userIDFromClaims(claims)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err = checkRevoked(r.Context(), token, deps); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		ctx := appcontext.SetContextUserID(r.Context(), userID)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Authenticate validates an access token and returns the ID of its user.
+func Authenticate(ctx context.Context, token string, deps AuthDeps) (int64, error) {
+	claims, err := deps.Verifier.Verify(ctx, token)
+	if err != nil {
+		return 0, AuthError(err)
+	}
+
+	userID, err := userIDFromClaims(claims)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = checkRevoked(ctx, token, deps); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// AuthError maps verification errors to API errors. It is used as the error
+// handler of the verifier middleware.
+func AuthError(err error) error {
+	if errors.Is(err, verifier.ErrKeysUnavailable) {
+		return apperrors.ErrInternalServerError
+	}
+
+	return apperrors.ErrUnauthorized
+}
+
+func checkRevoked(ctx context.Context, token string, deps AuthDeps) error {
+	revoked, err := deps.Revocation.IsRevoked(ctx, token)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return apperrors.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func userIDFromClaims(claims *jwt.Claims) (int64, error) {
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, apperrors.ErrUnauthorized
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+
+	"github.com/maximegorov13/chat-app/chat/internal/appcontext"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/req"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+type RateLimitDeps struct {
+	Limiter ratelimit.Limiter
+	Policy  ratelimit.Policy
+}
+
+// RateLimit limits requests per authenticated user, or per client IP for
+// anonymous requests, so it has to be wrapped by Auth to limit users.
+func RateLimit(next http.Handler, deps RateLimitDeps) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := "ip:" + req.ClientIP(r)
+		if userID, ok := appcontext.LookupContextUserID(r.Context()); ok {
+			subject = UserSubject(userID)
+		}
+
+		result, err := Allow(r.Context(), subject, deps)
+		if result != nil {
+			ratelimit.SetHeaders(w.Header(), deps.Policy, result)
+		}
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow takes a token from the bucket of the subject. It returns a RetryError
+// if the limit is exceeded, so WebSocket commands can be limited as well.
+func Allow(ctx context.Context, subject string, deps RateLimitDeps) (*ratelimit.Result, error) {
+	result, err := deps.Limiter.Allow(ctx, deps.Policy.Key(subject), deps.Policy.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Allowed {
+		return result, apperrors.WithRetryAfter(apperrors.ErrTooManyRequests, result.RetryAfter)
+	}
+
+	return result, nil
+}
+
+// UserSubject is the rate limit subject of an authenticated user.
+func UserSubject(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
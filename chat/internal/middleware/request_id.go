@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+// maxRequestIDLength limits request IDs passed by clients and proxies.
+const maxRequestIDLength = 128
+
+// RequestID honours the X-Request-ID header of the request or generates an
+// ID. The ID is echoed in the response and added to the records logged with
+// the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(res.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(res.RequestIDHeader, requestID)
+
+		ctx := logger.WithAttrs(r.Context(), slog.String("request_id", requestID))
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID allows IDs of printable ASCII characters, so they cannot
+// break log lines or response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/appcontext"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/middleware"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+type PresenceHandlerDeps struct {
+	Conf            *configs.Config
+	PresenceService presence.PresenceService
+	AuthDeps        middleware.AuthDeps
+}
+
+type PresenceHandler struct {
+	conf            *configs.Config
+	presenceService presence.PresenceService
+}
+
+func NewPresenceHandler(router *http.ServeMux, deps PresenceHandlerDeps) {
+	handler := &PresenceHandler{
+		conf:            deps.Conf,
+		presenceService: deps.PresenceService,
+	}
+
+	router.Handle("GET /api/chats/{chatID}/presence", middleware.Auth(handler.ListChatPresence(), deps.AuthDeps))
+}
+
+func (h *PresenceHandler) ListChatPresence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		userID := appcontext.GetContextUserID(r.Context())
+
+		presences, err := h.presenceService.ListChatPresence(r.Context(), userID, chatID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := make([]presence.PresenceResponse, 0, len(presences))
+		for _, p := range presences {
+			data = append(data, presence.NewPresenceResponse(p))
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
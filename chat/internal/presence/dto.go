@@ -0,0 +1,29 @@
+package presence
+
+import (
+	"github.com/go-ozzo/ozzo-validation"
+)
+
+// SetStatusRequest sets the status of the connection it is sent over, e.g.
+// away when the app goes to the background.
+type SetStatusRequest struct {
+	Status Status `json:"status"`
+}
+
+func (r SetStatusRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Status, validation.Required, validation.In(StatusOnline, StatusAway)),
+	)
+}
+
+type PresenceResponse struct {
+	UserID int64  `json:"user_id"`
+	Status Status `json:"status"`
+}
+
+func NewPresenceResponse(p *Presence) PresenceResponse {
+	return PresenceResponse{
+		UserID: p.UserID,
+		Status: p.Status,
+	}
+}
@@ -0,0 +1,43 @@
+package presence
+
+import (
+	"time"
+)
+
+// Status is the presence of a user derived from their live WebSocket
+// connections. A user is online if any connection is online, away if all are
+// away and offline without connections.
+type Status string
+
+const (
+	StatusOnline  Status = "online"
+	StatusAway    Status = "away"
+	StatusOffline Status = "offline"
+)
+
+const (
+	// HeartbeatInterval is how often a connection refreshes its presence.
+	HeartbeatInterval = 30 * time.Second
+	// ConnectionTTL is how long a connection counts as live without a
+	// heartbeat, e.g. after its instance crashed.
+	ConnectionTTL = HeartbeatInterval * 5 / 2
+	// TypingTimeout is how long a typing indicator is shown unless the
+	// client sends typing.start again.
+	TypingTimeout = 6 * time.Second
+)
+
+type Presence struct {
+	UserID int64
+	Status Status
+}
+
+// Change is the status of a user before and after one of their connections
+// was saved or removed.
+type Change struct {
+	Before Status
+	After  Status
+}
+
+func (c *Change) Changed() bool {
+	return c.Before != c.After
+}
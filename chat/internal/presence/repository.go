@@ -0,0 +1,14 @@
+package presence
+
+import (
+	"context"
+	"time"
+)
+
+type PresenceRepository interface {
+	// Save keeps the connection live for ttl and sets its status. An empty
+	// status keeps the current one, or online for a new connection.
+	Save(ctx context.Context, userID int64, connID string, status Status, ttl time.Duration) (*Change, error)
+	Remove(ctx context.Context, userID int64, connID string) (*Change, error)
+	FindStatuses(ctx context.Context, userIDs []int64) (map[int64]Status, error)
+}
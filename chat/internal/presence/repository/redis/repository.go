@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+	"github.com/maximegorov13/chat-app/chat/internal/rediskeys"
+	storageredis "github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+)
+
+// statusFunc returns the status of a user from the connections sorted set and
+// the statuses hash, ignoring expired connections. The Redis clock is used,
+// so instances with skewed clocks agree on which connections are live.
+const statusFunc = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local function status(connections, statuses)
+	local ids = redis.call("ZRANGEBYSCORE", connections, "(" .. now, "+inf")
+	if #ids == 0 then
+		return "offline"
+	end
+
+	for _, value in ipairs(redis.call("HMGET", statuses, unpack(ids))) do
+		if value == "online" or not value then
+			return "online"
+		end
+	end
+
+	return "away"
+end
+`
+
+// saveScript refreshes the connection ARGV[1] of the user with the keys
+// KEYS[1] and KEYS[2] for ARGV[3] milliseconds and sets its status to
+// ARGV[2], if not empty. Expired connections are dropped. It returns the
+// status of the user before and after.
+const saveScript = statusFunc + `
+local before = status(KEYS[1], KEYS[2])
+
+local expired = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", now)
+if #expired > 0 then
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+	redis.call("HDEL", KEYS[2], unpack(expired))
+end
+
+local value = ARGV[2]
+if value == "" then
+	value = redis.call("HGET", KEYS[2], ARGV[1]) or "online"
+end
+
+local ttl = tonumber(ARGV[3])
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[1])
+redis.call("HSET", KEYS[2], ARGV[1], value)
+redis.call("PEXPIRE", KEYS[1], ttl)
+redis.call("PEXPIRE", KEYS[2], ttl)
+
+return {before, status(KEYS[1], KEYS[2])}
+`
+
+// removeScript removes the connection ARGV[1] of the user with the keys
+// KEYS[1] and KEYS[2] and returns the status of the user before and after.
+const removeScript = statusFunc + `
+local before = status(KEYS[1], KEYS[2])
+
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+
+return {before, status(KEYS[1], KEYS[2])}
+`
+
+// statusesScript returns the status of every user, with KEYS holding the
+// connections and statuses keys of each user in turn.
+const statusesScript = statusFunc + `
+local result = {}
+for i = 1, #KEYS, 2 do
+	result[#result + 1] = status(KEYS[i], KEYS[i + 1])
+end
+
+return result
+`
+
+type PresenceRepository struct {
+	redis *storageredis.Redis
+}
+
+func NewPresenceRepository(redis *storageredis.Redis) *PresenceRepository {
+	return &PresenceRepository{
+		redis: redis,
+	}
+}
+
+func (r *PresenceRepository) Save(ctx context.Context, userID int64, connID string, status presence.Status, ttl time.Duration) (*presence.Change, error) {
+	defer metrics.Storage.Observe("redis", "presence", "Save", time.Now())
+
+	reply, err := r.redis.Eval(ctx, saveScript, userKeys(userID), connID, string(status), ttl.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseChange(reply)
+}
+
+func (r *PresenceRepository) Remove(ctx context.Context, userID int64, connID string) (*presence.Change, error) {
+	defer metrics.Storage.Observe("redis", "presence", "Remove", time.Now())
+
+	reply, err := r.redis.Eval(ctx, removeScript, userKeys(userID), connID)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseChange(reply)
+}
+
+func (r *PresenceRepository) FindStatuses(ctx context.Context, userIDs []int64) (map[int64]presence.Status, error) {
+	defer metrics.Storage.Observe("redis", "presence", "FindStatuses", time.Now())
+
+	statuses := make(map[int64]presence.Status, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+
+	keys := make([]string, 0, len(userIDs)*2)
+	for _, userID := range userIDs {
+		keys = append(keys, userKeys(userID)...)
+	}
+
+	reply, err := r.redis.Eval(ctx, statusesScript, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parseStatuses(reply, len(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	for i, userID := range userIDs {
+		statuses[userID] = values[i]
+	}
+
+	return statuses, nil
+}
+
+func userKeys(userID int64) []string {
+	return []string{rediskeys.PresenceConnectionsKey(userID), rediskeys.PresenceStatusesKey(userID)}
+}
+
+func parseChange(reply any) (*presence.Change, error) {
+	values, err := parseStatuses(reply, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return &presence.Change{
+		Before: values[0],
+		After:  values[1],
+	}, nil
+}
+
+func parseStatuses(reply any, n int) ([]presence.Status, error) {
+	values, ok := reply.([]any)
+	if !ok || len(values) != n {
+		return nil, fmt.Errorf("unexpected presence script reply %v", reply)
+	}
+
+	statuses := make([]presence.Status, n)
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected presence script reply %v", reply)
+		}
+		statuses[i] = presence.Status(s)
+	}
+
+	return statuses, nil
+}
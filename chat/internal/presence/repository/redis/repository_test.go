@@ -0,0 +1,98 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+	presenceredis "github.com/maximegorov13/chat-app/chat/internal/presence/repository/redis"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+)
+
+func setupRepo(t testing.TB, mr *miniredis.Miniredis) *presenceredis.PresenceRepository {
+	t.Helper()
+
+	rdb, err := redis.NewRedis(context.Background(), &configs.Config{
+		Redis: configs.RedisConfig{
+			Url: "redis://" + mr.Addr(),
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		rdb.Close()
+	})
+
+	return presenceredis.NewPresenceRepository(rdb)
+}
+
+func TestPresenceRepository(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Minute
+
+	t.Run("aggregates connections", func(t *testing.T) {
+		repo := setupRepo(t, miniredis.RunT(t))
+
+		change, err := repo.Save(ctx, 1, "first", "", ttl)
+		require.NoError(t, err)
+		require.Equal(t, &presence.Change{Before: presence.StatusOffline, After: presence.StatusOnline}, change)
+
+		change, err = repo.Save(ctx, 1, "second", presence.StatusAway, ttl)
+		require.NoError(t, err)
+		require.False(t, change.Changed())
+
+		change, err = repo.Save(ctx, 1, "first", presence.StatusAway, ttl)
+		require.NoError(t, err)
+		require.Equal(t, &presence.Change{Before: presence.StatusOnline, After: presence.StatusAway}, change)
+
+		// A heartbeat keeps the status of the connection.
+		change, err = repo.Save(ctx, 1, "first", "", ttl)
+		require.NoError(t, err)
+		require.Equal(t, presence.StatusAway, change.After)
+
+		statuses, err := repo.FindStatuses(ctx, []int64{1, 2})
+		require.NoError(t, err)
+		require.Equal(t, map[int64]presence.Status{1: presence.StatusAway, 2: presence.StatusOffline}, statuses)
+
+		change, err = repo.Remove(ctx, 1, "first")
+		require.NoError(t, err)
+		require.False(t, change.Changed())
+
+		change, err = repo.Remove(ctx, 1, "second")
+		require.NoError(t, err)
+		require.Equal(t, &presence.Change{Before: presence.StatusAway, After: presence.StatusOffline}, change)
+	})
+
+	t.Run("ignores expired connections", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		repo := setupRepo(t, mr)
+
+		now := time.Now()
+		mr.SetTime(now)
+
+		_, err := repo.Save(ctx, 1, "crashed", presence.StatusOnline, ttl)
+		require.NoError(t, err)
+
+		mr.SetTime(now.Add(ttl / 2))
+		_, err = repo.Save(ctx, 1, "live", presence.StatusAway, ttl)
+		require.NoError(t, err)
+
+		statuses, err := repo.FindStatuses(ctx, []int64{1})
+		require.NoError(t, err)
+		require.Equal(t, presence.StatusOnline, statuses[1])
+
+		mr.SetTime(now.Add(ttl + time.Second))
+		statuses, err = repo.FindStatuses(ctx, []int64{1})
+		require.NoError(t, err)
+		require.Equal(t, presence.StatusAway, statuses[1])
+
+		mr.SetTime(now.Add(2 * ttl))
+		statuses, err = repo.FindStatuses(ctx, []int64{1})
+		require.NoError(t, err)
+		require.Equal(t, presence.StatusOffline, statuses[1])
+	})
+}
@@ -0,0 +1,21 @@
+package presence
+
+import (
+	"context"
+	"time"
+)
+
+type PresenceService interface {
+	Connect(ctx context.Context, userID int64, connID string) error
+	Heartbeat(ctx context.Context, userID int64, connID string) error
+	SetStatus(ctx context.Context, userID int64, connID string, req *SetStatusRequest) error
+	Disconnect(ctx context.Context, userID int64, connID string) error
+	Typing(ctx context.Context, userID, chatID int64, typing bool) error
+	ListChatPresence(ctx context.Context, userID, chatID int64) ([]*Presence, error)
+}
+
+// LastSeenRecorder stores when a user was last connected, for users who are
+// offline.
+type LastSeenRecorder interface {
+	Record(ctx context.Context, userID int64, seenAt time.Time) error
+}
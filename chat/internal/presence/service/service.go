@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+)
+
+type PresenceServiceDeps struct {
+	PresenceRepo presence.PresenceRepository
+	ChatRepo     chat.ChatRepository
+	Publisher    event.Publisher
+	LastSeen     presence.LastSeenRecorder
+}
+
+// PresenceService tracks typing and presence. Neither is stored in Postgres:
+// typing is only fanned out to connected members and presence lives in
+// Redis while the user has live connections.
+type PresenceService struct {
+	presenceRepo presence.PresenceRepository
+	chatRepo     chat.ChatRepository
+	publisher    event.Publisher
+	lastSeen     presence.LastSeenRecorder
+}
+
+func NewPresenceService(deps PresenceServiceDeps) *PresenceService {
+	return &PresenceService{
+		presenceRepo: deps.PresenceRepo,
+		chatRepo:     deps.ChatRepo,
+		publisher:    deps.Publisher,
+		lastSeen:     deps.LastSeen,
+	}
+}
+
+// Connect counts a new connection of the user. If the user came online, the
+// time is recorded as last seen too, so it stays close to the truth even if
+// the instance crashes before Disconnect.
+func (s *PresenceService) Connect(ctx context.Context, userID int64, connID string) error {
+	change, err := s.presenceRepo.Save(ctx, userID, connID, "", presence.ConnectionTTL)
+	if err != nil {
+		return err
+	}
+
+	if change.Before == presence.StatusOffline {
+		s.recordLastSeen(ctx, userID)
+	}
+	s.publishChange(ctx, userID, change)
+
+	return nil
+}
+
+// Heartbeat keeps the connection live for another presence.ConnectionTTL.
+func (s *PresenceService) Heartbeat(ctx context.Context, userID int64, connID string) error {
+	change, err := s.presenceRepo.Save(ctx, userID, connID, "", presence.ConnectionTTL)
+	if err != nil {
+		return err
+	}
+
+	s.publishChange(ctx, userID, change)
+
+	return nil
+}
+
+func (s *PresenceService) SetStatus(ctx context.Context, userID int64, connID string, req *presence.SetStatusRequest) error {
+	change, err := s.presenceRepo.Save(ctx, userID, connID, req.Status, presence.ConnectionTTL)
+	if err != nil {
+		return err
+	}
+
+	s.publishChange(ctx, userID, change)
+
+	return nil
+}
+
+// Disconnect removes a closed connection. The user goes offline once their
+// last connection on any instance is closed.
+func (s *PresenceService) Disconnect(ctx context.Context, userID int64, connID string) error {
+	change, err := s.presenceRepo.Remove(ctx, userID, connID)
+	if err != nil {
+		return err
+	}
+
+	if change.Changed() && change.After == presence.StatusOffline {
+		s.recordLastSeen(ctx, userID)
+	}
+	s.publishChange(ctx, userID, change)
+
+	return nil
+}
+
+// Typing tells the other connected members of the chat that the user started
+// or stopped typing.
+func (s *PresenceService) Typing(ctx context.Context, userID, chatID int64, typing bool) error {
+	if err := s.checkMember(ctx, userID, chatID); err != nil {
+		return err
+	}
+
+	if !typing {
+		s.publish(ctx, event.TypeTypingStopped, chatID, event.TypingData{UserID: userID})
+		return nil
+	}
+
+	expiresAt := time.Now().Add(presence.TypingTimeout)
+	s.publish(ctx, event.TypeTypingStarted, chatID, event.TypingData{
+		UserID:    userID,
+		ExpiresAt: &expiresAt,
+	})
+
+	return nil
+}
+
+// ListChatPresence returns the presence of every member of the chat.
+func (s *PresenceService) ListChatPresence(ctx context.Context, userID, chatID int64) ([]*presence.Presence, error) {
+	if err := s.checkMember(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.chatRepo.FindMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, len(members))
+	for i, m := range members {
+		userIDs[i] = m.UserID
+	}
+
+	statuses, err := s.presenceRepo.FindStatuses(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*presence.Presence, len(userIDs))
+	for i, id := range userIDs {
+		result[i] = &presence.Presence{
+			UserID: id,
+			Status: statuses[id],
+		}
+	}
+
+	return result, nil
+}
+
+func (s *PresenceService) checkMember(ctx context.Context, userID, chatID int64) error {
+	member, err := s.chatRepo.FindMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return apperrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// recordLastSeen logs failures, since the ID service being unavailable must
+// not break WebSocket connections.
+func (s *PresenceService) recordLastSeen(ctx context.Context, userID int64) {
+	if err := s.lastSeen.Record(ctx, userID, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Error recording last seen", "user_id", userID, "error", err)
+	}
+}
+
+// publishChange sends a presence event to every chat of the user if their
+// status changed.
+func (s *PresenceService) publishChange(ctx context.Context, userID int64, change *presence.Change) {
+	if !change.Changed() {
+		return
+	}
+
+	chatIDs, err := s.chatRepo.FindIDsByUserID(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding chats for presence", "user_id", userID, "error", err)
+		return
+	}
+
+	data := event.PresenceData{
+		UserID: userID,
+		Status: string(change.After),
+	}
+	for _, chatID := range chatIDs {
+		s.publish(ctx, event.TypePresenceUpdated, chatID, data)
+	}
+}
+
+func (s *PresenceService) publish(ctx context.Context, eventType event.Type, chatID int64, data any) {
+	e, err := event.New(eventType, chatID, data)
+	if err == nil {
+		err = s.publisher.Publish(ctx, e)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error publishing event", "event_type", eventType, "chat_id", chatID, "error", err)
+	}
+}
@@ -0,0 +1,264 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	chatpg "github.com/maximegorov13/chat-app/chat/internal/chat/repository/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+	presenceredis "github.com/maximegorov13/chat-app/chat/internal/presence/repository/redis"
+	presenceservice "github.com/maximegorov13/chat-app/chat/internal/presence/service"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/chat/internal/storage/redis"
+)
+
+// recorder records published events and last seen times.
+type recorder struct {
+	mu       sync.Mutex
+	events   []*event.Event
+	lastSeen map[int64]int
+}
+
+func (r *recorder) Publish(_ context.Context, e *event.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) Record(_ context.Context, userID int64, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastSeen[userID]++
+	return nil
+}
+
+// take returns the events published since the last call.
+func (r *recorder) take() []*event.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func (r *recorder) lastSeenCount(userID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastSeen[userID]
+}
+
+type testDependencies struct {
+	presenceService presence.PresenceService
+	chatRepo        chat.ChatRepository
+	recorder        *recorder
+	cleanupChat     func(chatID int64)
+}
+
+func getUniqueUserID() int64 {
+	return rand.Int64N(1<<62) + 1<<62
+}
+
+func setupTest(t testing.TB) *testDependencies {
+	t.Helper()
+
+	conf, err := configs.Load("../../../.env")
+	require.NoError(t, err)
+
+	pgClient, err := pg.NewPostgres(conf)
+	require.NoError(t, err)
+
+	redisClient, err := redis.NewRedis(context.Background(), conf)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		redisClient.Close()
+	})
+
+	chatRepo := chatpg.NewChatRepository(pgClient)
+
+	cleanupChat := func(chatID int64) {
+		query, args, err := pgClient.Sb.
+			Delete("chats").
+			Where(squirrel.Eq{
+				"id": chatID,
+			}).
+			ToSql()
+		if err != nil {
+			t.Logf("cleanup query build error: %v", err)
+		}
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	rec := &recorder{
+		lastSeen: make(map[int64]int),
+	}
+
+	return &testDependencies{
+		presenceService: presenceservice.NewPresenceService(presenceservice.PresenceServiceDeps{
+			PresenceRepo: presenceredis.NewPresenceRepository(redisClient),
+			ChatRepo:     chatRepo,
+			Publisher:    rec,
+			LastSeen:     rec,
+		}),
+		chatRepo:    chatRepo,
+		recorder:    rec,
+		cleanupChat: cleanupChat,
+	}
+}
+
+func createChat(t testing.TB, deps *testDependencies, userIDs ...int64) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+
+	c := &chat.Chat{
+		Type:      chat.TypeGroup,
+		Title:     "Test Group",
+		CreatedBy: userIDs[0],
+	}
+	require.NoError(t, deps.chatRepo.Create(ctx, c))
+	t.Cleanup(func() {
+		deps.cleanupChat(c.ID)
+	})
+
+	for _, userID := range userIDs {
+		require.NoError(t, deps.chatRepo.AddMember(ctx, &chat.Member{
+			ChatID: c.ID,
+			UserID: userID,
+			Role:   chat.RoleMember,
+		}))
+	}
+
+	return c.ID
+}
+
+func requirePresence(t testing.TB, deps *testDependencies, userID, chatID, memberID int64, status presence.Status) {
+	t.Helper()
+
+	presences, err := deps.presenceService.ListChatPresence(context.Background(), userID, chatID)
+	require.NoError(t, err)
+	for _, p := range presences {
+		if p.UserID == memberID {
+			require.Equal(t, status, p.Status)
+			return
+		}
+	}
+	require.Fail(t, "member not found", memberID)
+}
+
+func requirePresenceEvent(t testing.TB, deps *testDependencies, chatID, userID int64, status presence.Status) {
+	t.Helper()
+
+	events := deps.recorder.take()
+	require.Len(t, events, 1)
+	require.Equal(t, event.TypePresenceUpdated, events[0].Type)
+	require.Equal(t, chatID, events[0].ChatID)
+
+	var data event.PresenceData
+	require.NoError(t, json.Unmarshal(events[0].Data, &data))
+	require.Equal(t, userID, data.UserID)
+	require.Equal(t, string(status), data.Status)
+}
+
+func TestPresenceService_Presence(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("derived from every connection", func(t *testing.T) {
+		userID := getUniqueUserID()
+		peerID := getUniqueUserID()
+		chatID := createChat(t, deps, userID, peerID)
+
+		requirePresence(t, deps, peerID, chatID, userID, presence.StatusOffline)
+
+		require.NoError(t, deps.presenceService.Connect(ctx, userID, "first"))
+		requirePresenceEvent(t, deps, chatID, userID, presence.StatusOnline)
+		require.NoError(t, deps.presenceService.Connect(ctx, userID, "second"))
+		require.Empty(t, deps.recorder.take())
+		require.Equal(t, 1, deps.recorder.lastSeenCount(userID))
+
+		err := deps.presenceService.SetStatus(ctx, userID, "first", &presence.SetStatusRequest{Status: presence.StatusAway})
+		require.NoError(t, err)
+		requirePresence(t, deps, peerID, chatID, userID, presence.StatusOnline)
+
+		err = deps.presenceService.SetStatus(ctx, userID, "second", &presence.SetStatusRequest{Status: presence.StatusAway})
+		require.NoError(t, err)
+		requirePresenceEvent(t, deps, chatID, userID, presence.StatusAway)
+		requirePresence(t, deps, peerID, chatID, userID, presence.StatusAway)
+
+		require.NoError(t, deps.presenceService.Heartbeat(ctx, userID, "first"))
+		requirePresence(t, deps, peerID, chatID, userID, presence.StatusAway)
+
+		require.NoError(t, deps.presenceService.Disconnect(ctx, userID, "first"))
+		require.Empty(t, deps.recorder.take())
+		require.NoError(t, deps.presenceService.Disconnect(ctx, userID, "second"))
+		requirePresenceEvent(t, deps, chatID, userID, presence.StatusOffline)
+		requirePresence(t, deps, peerID, chatID, userID, presence.StatusOffline)
+		require.Equal(t, 2, deps.recorder.lastSeenCount(userID))
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		chatID := createChat(t, deps, getUniqueUserID())
+
+		_, err := deps.presenceService.ListChatPresence(ctx, getUniqueUserID(), chatID)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestPresenceService_Typing(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("publishes typing events", func(t *testing.T) {
+		userID := getUniqueUserID()
+		chatID := createChat(t, deps, userID, getUniqueUserID())
+
+		require.NoError(t, deps.presenceService.Typing(ctx, userID, chatID, true))
+		require.NoError(t, deps.presenceService.Typing(ctx, userID, chatID, false))
+
+		events := deps.recorder.take()
+		require.Len(t, events, 2)
+
+		require.Equal(t, event.TypeTypingStarted, events[0].Type)
+		var started event.TypingData
+		require.NoError(t, json.Unmarshal(events[0].Data, &started))
+		require.Equal(t, userID, started.UserID)
+		require.NotNil(t, started.ExpiresAt)
+		require.True(t, started.ExpiresAt.After(time.Now()))
+
+		require.Equal(t, event.TypeTypingStopped, events[1].Type)
+		var stopped event.TypingData
+		require.NoError(t, json.Unmarshal(events[1].Data, &stopped))
+		require.Equal(t, userID, stopped.UserID)
+		require.Nil(t, stopped.ExpiresAt)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		chatID := createChat(t, deps, getUniqueUserID())
+
+		err := deps.presenceService.Typing(ctx, getUniqueUserID(), chatID, true)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+		require.Empty(t, deps.recorder.take())
+	})
+}
@@ -0,0 +1,34 @@
+package rediskeys
+
+import (
+	"fmt"
+	"strings"
+)
+
+const eventsChannelPrefix = "events:"
+
+const (
+	presenceConnectionsFormat = "presence:%d:connections"
+	presenceStatusesFormat    = "presence:%d:statuses"
+)
+
+// EventsChannel is the Pub/Sub channel carrying the events of a bus topic.
+func EventsChannel(topic string) string {
+	return eventsChannelPrefix + topic
+}
+
+// EventsTopic returns the bus topic of an events channel.
+func EventsTopic(channel string) string {
+	return strings.TrimPrefix(channel, eventsChannelPrefix)
+}
+
+// PresenceConnectionsKey is a sorted set of the live WebSocket connections of
+// a user, scored by the time in milliseconds they expire at.
+func PresenceConnectionsKey(userID int64) string {
+	return fmt.Sprintf(presenceConnectionsFormat, userID)
+}
+
+// PresenceStatusesKey is a hash of the status of every connection of a user.
+func PresenceStatusesKey(userID int64) string {
+	return fmt.Sprintf(presenceStatusesFormat, userID)
+}
@@ -0,0 +1,110 @@
+package res
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/go-ozzo/ozzo-validation"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+)
+
+// RequestIDHeader carries the request ID. It is set on the response by the
+// request ID middleware and echoed in ResponseMeta.
+const RequestIDHeader = "X-Request-ID"
+
+func JSON[T any](w http.ResponseWriter, statusCode int, data T, meta *ResponseMeta) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if meta == nil {
+		meta = &ResponseMeta{}
+	}
+	meta.RequestID = w.Header().Get(RequestIDHeader)
+
+	if !reflect.ValueOf(data).IsValid() {
+		json.NewEncoder(w).Encode(Response[map[string]any]{
+			Data: map[string]any{},
+			Meta: meta,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(Response[T]{
+		Data: data,
+		Meta: meta,
+	})
+}
+
+func Error(w http.ResponseWriter, err error) {
+	errRes := NewErrorResponse(err)
+	if errRes.Code == http.StatusInternalServerError {
+		// Unexpected errors are hidden from clients, they are logged with the
+		// request ID to find them by the ID in the response.
+		slog.Error("Internal server error", "request_id", w.Header().Get(RequestIDHeader), "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if errRes.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(errRes.RetryAfter, 10))
+	}
+	w.WriteHeader(errRes.Code)
+	json.NewEncoder(w).Encode(Response[map[string]any]{
+		Meta:  newMeta(w),
+		Data:  map[string]any{},
+		Error: errRes,
+	})
+}
+
+// NewErrorResponse converts err into the error payload sent to clients, so
+// transports other than plain HTTP can report errors in the same format.
+func NewErrorResponse(err error) *ErrorResponse {
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		errRes := &ErrorResponse{
+			Code:    appErr.Code,
+			Message: appErr.Message,
+		}
+
+		var retryErr *apperrors.RetryError
+		if errors.As(err, &retryErr) {
+			// Rounded up so clients do not retry early.
+			errRes.RetryAfter = int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		}
+
+		return errRes
+	}
+
+	var valErr validation.Errors
+	if errors.As(err, &valErr) {
+		details := make([]ErrorDetail, 0, len(valErr))
+		for field, err := range valErr {
+			details = append(details, ErrorDetail{
+				Field:   field,
+				Message: err.Error(),
+			})
+		}
+
+		return &ErrorResponse{
+			Code:    apperrors.ErrValidationFailed.Code,
+			Message: apperrors.ErrValidationFailed.Message,
+			Details: details,
+		}
+	}
+
+	return &ErrorResponse{
+		Code:    apperrors.ErrInternalServerError.Code,
+		Message: apperrors.ErrInternalServerError.Message,
+	}
+}
+
+func newMeta(w http.ResponseWriter) *ResponseMeta {
+	return &ResponseMeta{
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+}
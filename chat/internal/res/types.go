@@ -0,0 +1,31 @@
+package res
+
+type Response[T any] struct {
+	Meta  *ResponseMeta  `json:"meta"`
+	Data  T              `json:"data"`
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+type ResponseMeta struct {
+	RequestID string    `json:"request_id,omitempty"`
+	Page      *PageMeta `json:"page,omitempty"`
+}
+
+// PageMeta carries the opaque cursors of the pages next to the returned one.
+// A cursor is empty if there is nothing more in that direction.
+type PageMeta struct {
+	Before string `json:"before,omitempty"` // Older items
+	After  string `json:"after,omitempty"`  // Newer items
+}
+
+type ErrorResponse struct {
+	Code       int           `json:"code"`
+	Message    string        `json:"message"`
+	Details    []ErrorDetail `json:"details,omitempty"`
+	RetryAfter int64         `json:"retry_after,omitempty"` // Seconds, also sent in the Retry-After header
+}
+
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
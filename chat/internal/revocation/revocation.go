@@ -0,0 +1,119 @@
+/*
+Package revocation checks with the ID service whether access tokens were
+revoked by logout, session revocation or a password change.
+
+Signatures are verified locally, so a revoked token would otherwise stay valid
+until it expires. Results are cached for a short TTL to keep the ID service
+off the path of most requests.
+*/
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tokencheck"
+)
+
+const (
+	// DefaultCacheTTL is how long a check result is reused.
+	DefaultCacheTTL = 30 * time.Second
+	// staleTTL is how long a result is still used while the ID service is
+	// unreachable.
+	staleTTL = 5 * time.Minute
+	// checkTimeout bounds a call to the ID service.
+	checkTimeout = 5 * time.Second
+	// sweepSize is the cache size past which expired results are dropped.
+	sweepSize = 10000
+)
+
+// ErrCheckFailed is returned when the ID service cannot be asked and no
+// earlier result is known.
+var ErrCheckFailed = errors.New("token check failed")
+
+type CheckerDeps struct {
+	TokenCheck *tokencheck.Client
+	CacheTTL   time.Duration // Defaults to DefaultCacheTTL
+}
+
+// Checker caches revocation checks per token. It is safe for concurrent use.
+type Checker struct {
+	tokenCheck *tokencheck.Client
+	cacheTTL   time.Duration
+
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]result
+}
+
+type result struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+func NewChecker(deps CheckerDeps) *Checker {
+	if deps.CacheTTL <= 0 {
+		deps.CacheTTL = DefaultCacheTTL
+	}
+
+	return &Checker{
+		tokenCheck: deps.TokenCheck,
+		cacheTTL:   deps.CacheTTL,
+		results:    make(map[[sha256.Size]byte]result),
+	}
+}
+
+// IsRevoked reports whether the token was revoked. Revoked tokens are
+// remembered, a token is not revoked back.
+func (c *Checker) IsRevoked(ctx context.Context, token string) (bool, error) {
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	cached, ok := c.results[key]
+	c.mu.Unlock()
+
+	if ok && (cached.revoked || time.Since(cached.checkedAt) < c.cacheTTL) {
+		return cached.revoked, nil
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	checkRes, err := c.tokenCheck.IsTokenInvalid(checkCtx, token)
+	if err == nil && checkRes.Error != nil {
+		err = errors.New(checkRes.Error.Message)
+	}
+	if err != nil {
+		// Keep the last result for a while, the ID service may be restarting.
+		if ok && time.Since(cached.checkedAt) < staleTTL {
+			slog.WarnContext(ctx, "Error checking token, using the cached result", "error", err)
+			return cached.revoked, nil
+		}
+		return false, errors.Join(ErrCheckFailed, err)
+	}
+
+	c.mu.Lock()
+	if len(c.results) >= sweepSize {
+		c.sweep()
+	}
+	c.results[key] = result{
+		revoked:   checkRes.Data.Invalid,
+		checkedAt: time.Now(),
+	}
+	c.mu.Unlock()
+
+	return checkRes.Data.Invalid, nil
+}
+
+// sweep drops results past staleTTL. Revoked tokens are dropped too, they
+// expire within the access token TTL anyway. The caller holds c.mu.
+func (c *Checker) sweep() {
+	for key, r := range c.results {
+		if time.Since(r.checkedAt) >= staleTTL {
+			delete(c.results, key)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package revocation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tokencheck"
+
+	"github.com/maximegorov13/chat-app/chat/internal/revocation"
+)
+
+// idServer answers token checks with the revoked flag and counts requests.
+// Requests fail while down is set.
+func idServer(t testing.TB, revoked, down *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, "/api/auth/is-token-invalid", r.URL.Path)
+
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]bool{"invalid": revoked.Load()},
+		}))
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requests
+}
+
+func newChecker(ts *httptest.Server, cacheTTL time.Duration) *revocation.Checker {
+	return revocation.NewChecker(revocation.CheckerDeps{
+		TokenCheck: tokencheck.NewClient(tokencheck.Config{
+			ServiceURL: ts.URL,
+		}),
+		CacheTTL: cacheTTL,
+	})
+}
+
+func TestChecker_IsRevoked(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches results", func(t *testing.T) {
+		var revoked, down atomic.Bool
+		ts, requests := idServer(t, &revoked, &down)
+		c := newChecker(ts, time.Minute)
+
+		for range 3 {
+			isRevoked, err := c.IsRevoked(ctx, "token")
+			require.NoError(t, err)
+			require.False(t, isRevoked)
+		}
+		require.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("notices revocation after the cache TTL", func(t *testing.T) {
+		var revoked, down atomic.Bool
+		ts, _ := idServer(t, &revoked, &down)
+		c := newChecker(ts, 50*time.Millisecond)
+
+		isRevoked, err := c.IsRevoked(ctx, "token")
+		require.NoError(t, err)
+		require.False(t, isRevoked)
+
+		revoked.Store(true)
+		time.Sleep(60 * time.Millisecond)
+
+		isRevoked, err = c.IsRevoked(ctx, "token")
+		require.NoError(t, err)
+		require.True(t, isRevoked)
+	})
+
+	t.Run("uses the last result while the ID service is down", func(t *testing.T) {
+		var revoked, down atomic.Bool
+		ts, _ := idServer(t, &revoked, &down)
+		c := newChecker(ts, 50*time.Millisecond)
+
+		_, err := c.IsRevoked(ctx, "token")
+		require.NoError(t, err)
+
+		down.Store(true)
+		time.Sleep(60 * time.Millisecond)
+
+		isRevoked, err := c.IsRevoked(ctx, "token")
+		require.NoError(t, err)
+		require.False(t, isRevoked)
+
+		_, err = c.IsRevoked(ctx, "other")
+		require.ErrorIs(t, err, revocation.ErrCheckFailed)
+	})
+}
@@ -0,0 +1,47 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+)
+
+const uniqueViolationCode = "23505"
+
+type Postgres struct {
+	Sqlx *sqlx.DB
+	Sb   squirrel.StatementBuilderType
+}
+
+func NewPostgres(conf *configs.Config) (*Postgres, error) {
+	sqlDB, err := tracing.OpenPostgres(conf.Postgres.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	db := sqlx.NewDb(sqlDB, "postgres")
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	builder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	return &Postgres{
+		Sqlx: db,
+		Sb:   builder,
+	}, nil
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode
+}
@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+const (
+	// writeWait is the time allowed to write a single frame to the peer.
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from the peer.
+	pongWait = 60 * time.Second
+	// pingPeriod must be less than pongWait.
+	pingPeriod = pongWait * 9 / 10
+	// maxFrameSize limits the size of inbound frames.
+	maxFrameSize = 16 << 10
+	// sendBufferSize is the number of outbound frames queued per connection
+	// before the client is considered too slow and disconnected.
+	sendBufferSize = 256
+)
+
+// FrameHandler processes commands received from a client.
+type FrameHandler func(ctx context.Context, c *Client, frame *InboundFrame)
+
+// Client is a single WebSocket connection of an authenticated user.
+type Client struct {
+	hub     *Hub
+	conn    *websocket.Conn
+	id      string
+	userID  int64
+	handler FrameHandler
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ID identifies the connection among all connections of the user, on every
+// instance.
+func (c *Client) ID() string {
+	return c.id
+}
+
+func (c *Client) UserID() int64 {
+	return c.userID
+}
+
+// Done is closed when the connection is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Reply sends an acknowledgement for the inbound frame with requestID.
+func (c *Client) Reply(requestID string, data any) {
+	c.sendFrame(&ReplyFrame{
+		Type:      FrameTypeAck,
+		RequestID: requestID,
+		Data:      data,
+	})
+}
+
+// ReplyError reports a failure to process the inbound frame with requestID.
+func (c *Client) ReplyError(requestID string, err error) {
+	errRes := res.NewErrorResponse(err)
+	if errRes.Code == http.StatusInternalServerError {
+		slog.Error("Internal server error", "user_id", c.userID, "ws_request_id", requestID, "error", err)
+	}
+
+	c.sendFrame(&ReplyFrame{
+		Type:      FrameTypeError,
+		RequestID: requestID,
+		Error:     errRes,
+	})
+}
+
+func (c *Client) sendFrame(frame *ReplyFrame) {
+	msg, err := json.Marshal(frame)
+	if err != nil {
+		slog.Error("Error encoding WebSocket frame", "user_id", c.userID, "error", err)
+		return
+	}
+
+	c.enqueue(msg)
+}
+
+// enqueue queues msg for delivery without blocking. A client whose queue is
+// full cannot keep up with the fan-out and is disconnected.
+func (c *Client) enqueue(msg []byte) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		c.close()
+	}
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *Client) readPump(ctx context.Context) {
+	defer func() {
+		c.hub.unregister(c)
+		c.close()
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var frame InboundFrame
+		if err := c.conn.ReadJSON(&frame); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.ReplyError("", apperrors.ErrInvalidRequestBody)
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Warn("WebSocket read error", "user_id", c.userID, "error", err)
+			}
+			return
+		}
+
+		if c.handler != nil {
+			c.handler(ctx, c, &frame)
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/maximegorov13/chat-app/chat/configs"
+	"github.com/maximegorov13/chat-app/chat/internal/apperrors"
+	"github.com/maximegorov13/chat-app/chat/internal/message"
+	"github.com/maximegorov13/chat-app/chat/internal/middleware"
+	"github.com/maximegorov13/chat-app/chat/internal/presence"
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+	"github.com/maximegorov13/chat-app/chat/internal/ws"
+)
+
+// tokenSubprotocol is the WebSocket subprotocol browser clients use to pass
+// the access token: "Sec-WebSocket-Protocol: access_token, <token>".
+const tokenSubprotocol = "access_token"
+
+type WSHandlerDeps struct {
+	Conf             *configs.Config
+	Hub              *ws.Hub
+	MessageService   message.MessageService
+	PresenceService  presence.PresenceService
+	AuthDeps         middleware.AuthDeps
+	MessageRateLimit middleware.RateLimitDeps
+	TypingRateLimit  middleware.RateLimitDeps
+}
+
+type WSHandler struct {
+	conf             *configs.Config
+	hub              *ws.Hub
+	messageService   message.MessageService
+	presenceService  presence.PresenceService
+	authDeps         middleware.AuthDeps
+	messageRateLimit middleware.RateLimitDeps
+	typingRateLimit  middleware.RateLimitDeps
+	upgrader         websocket.Upgrader
+}
+
+func NewWSHandler(router *http.ServeMux, deps WSHandlerDeps) {
+	handler := &WSHandler{
+		conf:             deps.Conf,
+		hub:              deps.Hub,
+		messageService:   deps.MessageService,
+		presenceService:  deps.PresenceService,
+		authDeps:         deps.AuthDeps,
+		messageRateLimit: deps.MessageRateLimit,
+		typingRateLimit:  deps.TypingRateLimit,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{tokenSubprotocol},
+			// Connections are authenticated with a bearer token rather than
+			// cookies, so cross-origin clients are allowed.
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+
+	router.HandleFunc("GET /ws", handler.Connect())
+}
+
+func (h *WSHandler) Connect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := extractToken(r)
+		if token == "" {
+			res.Error(w, apperrors.ErrUnauthorized)
+			return
+		}
+
+		userID, err := middleware.Authenticate(r.Context(), token, h.authDeps)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client, err := h.hub.Connect(r.Context(), conn, userID, h.handleFrame)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error registering WebSocket connection", "user_id", userID, "error", err)
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""))
+			conn.Close()
+			return
+		}
+
+		go h.trackPresence(context.WithoutCancel(r.Context()), client)
+	}
+}
+
+// trackPresence counts the connection towards the presence of its user until
+// the connection is closed.
+func (h *WSHandler) trackPresence(ctx context.Context, c *ws.Client) {
+	if err := h.presenceService.Connect(ctx, c.UserID(), c.ID()); err != nil {
+		slog.ErrorContext(ctx, "Error connecting presence", "user_id", c.UserID(), "error", err)
+	}
+
+	ticker := time.NewTicker(presence.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.presenceService.Heartbeat(ctx, c.UserID(), c.ID()); err != nil {
+				slog.ErrorContext(ctx, "Error refreshing presence", "user_id", c.UserID(), "error", err)
+			}
+		case <-c.Done():
+			if err := h.presenceService.Disconnect(ctx, c.UserID(), c.ID()); err != nil {
+				slog.ErrorContext(ctx, "Error disconnecting presence", "user_id", c.UserID(), "error", err)
+			}
+			return
+		}
+	}
+}
+
+func (h *WSHandler) handleFrame(ctx context.Context, c *ws.Client, frame *ws.InboundFrame) {
+	switch frame.Type {
+	case ws.FrameTypeMessageSend:
+		var body message.SendMessageRequest
+		if err := json.Unmarshal(frame.Data, &body); err != nil {
+			c.ReplyError(frame.RequestID, apperrors.ErrInvalidRequestBody)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+		// Shares the bucket with sending over HTTP.
+		if _, err := middleware.Allow(ctx, middleware.UserSubject(c.UserID()), h.messageRateLimit); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		m, err := h.messageService.SendMessage(ctx, c.UserID(), frame.ChatID, &body)
+		if err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		c.Reply(frame.RequestID, message.NewMessageResponse(m))
+	case ws.FrameTypeTypingStart, ws.FrameTypeTypingStop:
+		if _, err := middleware.Allow(ctx, middleware.UserSubject(c.UserID()), h.typingRateLimit); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		typing := frame.Type == ws.FrameTypeTypingStart
+		if err := h.presenceService.Typing(ctx, c.UserID(), frame.ChatID, typing); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		c.Reply(frame.RequestID, nil)
+	case ws.FrameTypePresenceSet:
+		var body presence.SetStatusRequest
+		if err := json.Unmarshal(frame.Data, &body); err != nil {
+			c.ReplyError(frame.RequestID, apperrors.ErrInvalidRequestBody)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		if err := h.presenceService.SetStatus(ctx, c.UserID(), c.ID(), &body); err != nil {
+			c.ReplyError(frame.RequestID, err)
+			return
+		}
+
+		c.Reply(frame.RequestID, nil)
+	default:
+		c.ReplyError(frame.RequestID, apperrors.ErrBadRequest)
+	}
+}
+
+// extractToken reads the access token from the "token" query parameter or
+// from the Sec-WebSocket-Protocol header.
+func extractToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == tokenSubprotocol && i+1 < len(protocols) {
+			return strings.TrimSpace(protocols[i+1])
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,36 @@
+package ws
+
+import (
+	"encoding/json"
+
+	"github.com/maximegorov13/chat-app/chat/internal/res"
+)
+
+type FrameType string
+
+const (
+	FrameTypeMessageSend FrameType = "message.send"
+	FrameTypeTypingStart FrameType = "typing.start"
+	FrameTypeTypingStop  FrameType = "typing.stop"
+	FrameTypePresenceSet FrameType = "presence.set"
+
+	FrameTypeAck   FrameType = "ack"
+	FrameTypeError FrameType = "error"
+)
+
+// InboundFrame is a command sent by a client over the WebSocket connection.
+// RequestID is echoed back in the reply so clients can match acknowledgements.
+type InboundFrame struct {
+	Type      FrameType       `json:"type"`
+	RequestID string          `json:"request_id,omitempty"`
+	ChatID    int64           `json:"chat_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// ReplyFrame acknowledges or rejects an inbound frame.
+type ReplyFrame struct {
+	Type      FrameType          `json:"type"`
+	RequestID string             `json:"request_id,omitempty"`
+	Data      any                `json:"data,omitempty"`
+	Error     *res.ErrorResponse `json:"error,omitempty"`
+}
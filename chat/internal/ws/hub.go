@@ -0,0 +1,366 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	"github.com/maximegorov13/chat-app/chat/internal/metrics"
+)
+
+type HubDeps struct {
+	ChatRepo chat.ChatRepository
+	Bus      event.Bus
+}
+
+// Hub keeps track of the WebSocket connections of this instance and fans out
+// chat events to connected members of the chat. Events go through the bus, so
+// they reach members connected to other instances too. The hub is subscribed
+// only to the chats of its connected users and to those users' own topics.
+type Hub struct {
+	chatRepo chat.ChatRepository
+	bus      event.Bus
+
+	// subMu orders bus subscription changes, see update.
+	subMu sync.Mutex
+
+	mu sync.RWMutex
+	// clients holds the connections of every connected user.
+	clients map[int64]map[*Client]struct{}
+	// members holds the connected members of every chat.
+	members map[int64]map[int64]struct{}
+	// chats holds the chats of every connected user.
+	chats map[int64]map[int64]struct{}
+}
+
+// NewHub starts consuming events from the bus until the bus is closed.
+func NewHub(deps HubDeps) *Hub {
+	h := &Hub{
+		chatRepo: deps.ChatRepo,
+		bus:      deps.Bus,
+		clients:  make(map[int64]map[*Client]struct{}),
+		members:  make(map[int64]map[int64]struct{}),
+		chats:    make(map[int64]map[int64]struct{}),
+	}
+
+	go h.run()
+
+	return h
+}
+
+// Connect registers an upgraded connection of userID and starts serving it.
+// Inbound frames are passed to handler.
+func (h *Hub) Connect(ctx context.Context, conn *websocket.Conn, userID int64, handler FrameHandler) (*Client, error) {
+	chatIDs, err := h.chatRepo.FindIDsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		hub:     h,
+		conn:    conn,
+		id:      uuid.NewString(),
+		userID:  userID,
+		handler: handler,
+		send:    make(chan []byte, sendBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	h.register(c, chatIDs)
+
+	go c.writePump()
+	go c.readPump(context.WithoutCancel(ctx))
+
+	return c, nil
+}
+
+// Publish sends the event to the chat topic. A user added to a chat also gets
+// the event on their own topic, because the instances they are connected to
+// are not subscribed to the chat yet. Thread notifications only go to the
+// topic of the notified user.
+func (h *Hub) Publish(ctx context.Context, e *event.Event) error {
+	if e.Type == event.TypeThreadReplied {
+		reply, err := threadReplyData(e)
+		if err != nil {
+			return err
+		}
+
+		return h.bus.Publish(ctx, event.UserTopic(reply.UserID), e)
+	}
+
+	if err := h.bus.Publish(ctx, event.ChatTopic(e.ChatID), e); err != nil {
+		return err
+	}
+
+	if e.Type != event.TypeMemberAdded {
+		return nil
+	}
+
+	member, err := memberData(e)
+	if err != nil {
+		return err
+	}
+
+	return h.bus.Publish(ctx, event.UserTopic(member.UserID), e)
+}
+
+// Close disconnects every client.
+func (h *Hub) Close() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, clients := range h.clients {
+		for c := range clients {
+			c.close()
+		}
+	}
+}
+
+func (h *Hub) run() {
+	for msg := range h.bus.Messages() {
+		if err := h.dispatch(msg); err != nil {
+			slog.Error("Error dispatching event", "event_type", msg.Event.Type, "topic", msg.Topic, "error", err)
+		}
+	}
+}
+
+// dispatch delivers an event received from the bus to local connections.
+// Membership events also update which chats the affected user receives
+// events for.
+func (h *Hub) dispatch(msg *event.Message) error {
+	e := msg.Event
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case event.TypeMemberAdded, event.TypeMemberRemoved:
+	case event.TypeThreadReplied:
+		reply, err := threadReplyData(e)
+		if err != nil {
+			return err
+		}
+		h.send(reply.UserID, payload)
+		return nil
+	case event.TypeTypingStarted, event.TypeTypingStopped:
+		typing, err := typingData(e)
+		if err != nil {
+			return err
+		}
+		// Users do not need to see themselves typing.
+		h.broadcast(e.ChatID, payload, typing.UserID)
+		return nil
+	default:
+		h.broadcast(e.ChatID, payload, 0)
+		return nil
+	}
+
+	member, err := memberData(e)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case msg.Topic == event.UserTopic(member.UserID):
+		h.join(member.UserID, e.ChatID)
+		h.send(member.UserID, payload)
+	case e.Type == event.TypeMemberAdded:
+		// The added user gets the event from their own topic.
+		h.broadcast(e.ChatID, payload, member.UserID)
+	default:
+		h.broadcast(e.ChatID, payload, 0)
+		h.leave(member.UserID, e.ChatID)
+	}
+
+	return nil
+}
+
+// broadcast delivers the payload to connected members of the chat, except the
+// skipped user.
+func (h *Hub) broadcast(chatID int64, payload []byte, skipUserID int64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for userID := range h.members[chatID] {
+		if userID == skipUserID {
+			continue
+		}
+		for c := range h.clients[userID] {
+			c.enqueue(payload)
+		}
+	}
+}
+
+func (h *Hub) send(userID int64, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients[userID] {
+		c.enqueue(payload)
+	}
+}
+
+func (h *Hub) register(c *Client, chatIDs []int64) {
+	h.update(func() []topicChange {
+		if h.clients[c.userID] == nil {
+			h.clients[c.userID] = make(map[*Client]struct{})
+		}
+		h.clients[c.userID][c] = struct{}{}
+		metrics.WebSocketConnections.Inc()
+
+		if h.chats[c.userID] != nil {
+			return nil
+		}
+
+		changes := []topicChange{{topic: event.UserTopic(c.userID), subscribe: true}}
+		h.chats[c.userID] = make(map[int64]struct{}, len(chatIDs))
+		for _, chatID := range chatIDs {
+			changes = h.addMember(changes, c.userID, chatID)
+		}
+		return changes
+	})
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.update(func() []topicChange {
+		clients := h.clients[c.userID]
+		if _, ok := clients[c]; !ok {
+			return nil
+		}
+
+		delete(clients, c)
+		metrics.WebSocketConnections.Dec()
+		if len(clients) > 0 {
+			return nil
+		}
+
+		var changes []topicChange
+		delete(h.clients, c.userID)
+		for chatID := range h.chats[c.userID] {
+			changes = h.removeMember(changes, c.userID, chatID)
+		}
+		delete(h.chats, c.userID)
+		return append(changes, topicChange{topic: event.UserTopic(c.userID)})
+	})
+}
+
+func (h *Hub) join(userID, chatID int64) {
+	h.update(func() []topicChange {
+		if h.chats[userID] == nil {
+			return nil
+		}
+
+		return h.addMember(nil, userID, chatID)
+	})
+}
+
+func (h *Hub) leave(userID, chatID int64) {
+	h.update(func() []topicChange {
+		if h.chats[userID] == nil {
+			return nil
+		}
+
+		return h.removeMember(nil, userID, chatID)
+	})
+}
+
+// topicChange is a bus subscription change worked out from the hub state.
+type topicChange struct {
+	topic     string
+	subscribe bool
+}
+
+// update runs fn with h.mu held for writing and then makes the subscription
+// changes it returns. The bus is called after h.mu is released, so a slow bus
+// does not hold up delivery. h.subMu is held throughout, so bus subscriptions
+// change in the same order as the hub state.
+func (h *Hub) update(fn func() []topicChange) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	h.mu.Lock()
+	changes := fn()
+	h.mu.Unlock()
+
+	for _, change := range changes {
+		if change.subscribe {
+			h.subscribe(change.topic)
+		} else {
+			h.unsubscribe(change.topic)
+		}
+	}
+}
+
+// addMember and removeMember must be called with h.mu held for writing. They
+// append the needed subscription changes to changes. The chat topic is
+// subscribed while the chat has at least one connected member.
+func (h *Hub) addMember(changes []topicChange, userID, chatID int64) []topicChange {
+	h.chats[userID][chatID] = struct{}{}
+
+	if h.members[chatID] == nil {
+		h.members[chatID] = make(map[int64]struct{})
+		changes = append(changes, topicChange{topic: event.ChatTopic(chatID), subscribe: true})
+	}
+	h.members[chatID][userID] = struct{}{}
+
+	return changes
+}
+
+func (h *Hub) removeMember(changes []topicChange, userID, chatID int64) []topicChange {
+	delete(h.chats[userID], chatID)
+
+	delete(h.members[chatID], userID)
+	if _, ok := h.members[chatID]; ok && len(h.members[chatID]) == 0 {
+		delete(h.members, chatID)
+		changes = append(changes, topicChange{topic: event.ChatTopic(chatID)})
+	}
+
+	return changes
+}
+
+func (h *Hub) subscribe(topic string) {
+	if err := h.bus.Subscribe(context.Background(), topic); err != nil {
+		slog.Error("Error subscribing", "topic", topic, "error", err)
+	}
+}
+
+func (h *Hub) unsubscribe(topic string) {
+	if err := h.bus.Unsubscribe(context.Background(), topic); err != nil {
+		slog.Error("Error unsubscribing", "topic", topic, "error", err)
+	}
+}
+
+func memberData(e *event.Event) (*event.MemberData, error) {
+	var member event.MemberData
+	if err := json.Unmarshal(e.Data, &member); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func threadReplyData(e *event.Event) (*event.ThreadReplyData, error) {
+	var reply event.ThreadReplyData
+	if err := json.Unmarshal(e.Data, &reply); err != nil {
+		return nil, err
+	}
+
+	return &reply, nil
+}
+
+func typingData(e *event.Event) (*event.TypingData, error) {
+	var typing event.TypingData
+	if err := json.Unmarshal(e.Data, &typing); err != nil {
+		return nil, err
+	}
+
+	return &typing, nil
+}
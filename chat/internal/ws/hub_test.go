@@ -0,0 +1,340 @@
+package ws_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/chat/internal/chat"
+	"github.com/maximegorov13/chat-app/chat/internal/event"
+	memorybus "github.com/maximegorov13/chat-app/chat/internal/event/bus/memory"
+	"github.com/maximegorov13/chat-app/chat/internal/ws"
+)
+
+type fakeChatRepo struct {
+	chat.ChatRepository
+	chatIDs map[int64][]int64
+}
+
+func (r *fakeChatRepo) FindIDsByUserID(_ context.Context, userID int64) ([]int64, error) {
+	return r.chatIDs[userID], nil
+}
+
+// topicsBus records the topics the hub is subscribed to.
+type topicsBus struct {
+	event.Bus
+
+	mu     sync.Mutex
+	topics map[string]struct{}
+}
+
+func newTopicsBus(bus event.Bus) *topicsBus {
+	return &topicsBus{
+		Bus:    bus,
+		topics: make(map[string]struct{}),
+	}
+}
+
+func (b *topicsBus) Subscribe(ctx context.Context, topics ...string) error {
+	b.mu.Lock()
+	for _, topic := range topics {
+		b.topics[topic] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	return b.Bus.Subscribe(ctx, topics...)
+}
+
+func (b *topicsBus) Unsubscribe(ctx context.Context, topics ...string) error {
+	b.mu.Lock()
+	for _, topic := range topics {
+		delete(b.topics, topic)
+	}
+	b.mu.Unlock()
+
+	return b.Bus.Unsubscribe(ctx, topics...)
+}
+
+func (b *topicsBus) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topics := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		topics = append(topics, topic)
+	}
+
+	return topics
+}
+
+func setupHub(t testing.TB, chatIDs map[int64][]int64, handler ws.FrameHandler) (*ws.Hub, func(userID int64) *websocket.Conn) {
+	t.Helper()
+
+	return setupHubOnBus(t, memorybus.NewBus(nil), chatIDs, handler)
+}
+
+// setupHubOnBus starts a hub on the given bus. Hubs on buses of the same broker
+// behave like separate service instances.
+func setupHubOnBus(t testing.TB, bus event.Bus, chatIDs map[int64][]int64, handler ws.FrameHandler) (*ws.Hub, func(userID int64) *websocket.Conn) {
+	t.Helper()
+
+	hub := ws.NewHub(ws.HubDeps{
+		ChatRepo: &fakeChatRepo{
+			chatIDs: chatIDs,
+		},
+		Bus: bus,
+	})
+	t.Cleanup(func() {
+		hub.Close()
+		bus.Close()
+	})
+
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+		require.NoError(t, err)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+
+		_, err = hub.Connect(r.Context(), conn, userID, handler)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+
+	connect := func(userID int64) *websocket.Conn {
+		url := strings.Replace(ts.URL, "http", "ws", 1) + "?user_id=" + strconv.FormatInt(userID, 10)
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			conn.Close()
+		})
+
+		return conn
+	}
+
+	return hub, connect
+}
+
+func publish(t testing.TB, hub *ws.Hub, eventType event.Type, chatID int64, data any) {
+	t.Helper()
+
+	e, err := event.New(eventType, chatID, data)
+	require.NoError(t, err)
+	require.NoError(t, hub.Publish(context.Background(), e))
+}
+
+func readEvent(t testing.TB, conn *websocket.Conn) *event.Event {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	var e event.Event
+	require.NoError(t, conn.ReadJSON(&e))
+
+	return &e
+}
+
+func requireNoEvent(t testing.TB, conn *websocket.Conn) {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+
+	_, _, err := conn.ReadMessage()
+	require.Error(t, err)
+}
+
+func TestHub_Publish(t *testing.T) {
+	t.Run("delivers to chat members only", func(t *testing.T) {
+		hub, connect := setupHub(t, map[int64][]int64{
+			1: {10},
+			2: {20},
+		}, nil)
+
+		member := connect(1)
+		outsider := connect(2)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, hub, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+
+		e := readEvent(t, member)
+		require.Equal(t, event.TypeMessageCreated, e.Type)
+		require.Equal(t, int64(10), e.ChatID)
+		require.JSONEq(t, `{"body":"hello"}`, string(e.Data))
+
+		requireNoEvent(t, outsider)
+	})
+
+	t.Run("delivers to every connection of a user", func(t *testing.T) {
+		hub, connect := setupHub(t, map[int64][]int64{
+			1: {10},
+		}, nil)
+
+		first := connect(1)
+		second := connect(1)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, hub, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, first).Type)
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, second).Type)
+	})
+
+	t.Run("follows membership changes", func(t *testing.T) {
+		hub, connect := setupHub(t, map[int64][]int64{}, nil)
+
+		conn := connect(1)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, hub, event.TypeMemberAdded, 10, event.MemberData{UserID: 1})
+		require.Equal(t, event.TypeMemberAdded, readEvent(t, conn).Type)
+
+		publish(t, hub, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, conn).Type)
+
+		publish(t, hub, event.TypeMemberRemoved, 10, event.MemberData{UserID: 1})
+		require.Equal(t, event.TypeMemberRemoved, readEvent(t, conn).Type)
+
+		publish(t, hub, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+		requireNoEvent(t, conn)
+	})
+
+	t.Run("skips the typing user", func(t *testing.T) {
+		hub, connect := setupHub(t, map[int64][]int64{
+			1: {10},
+			2: {10},
+		}, nil)
+
+		typer := connect(1)
+		other := connect(2)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, hub, event.TypeTypingStarted, 10, event.TypingData{UserID: 1})
+
+		e := readEvent(t, other)
+		require.Equal(t, event.TypeTypingStarted, e.Type)
+		require.JSONEq(t, `{"user_id":1}`, string(e.Data))
+
+		requireNoEvent(t, typer)
+	})
+
+	t.Run("delivers thread replies to the participant only", func(t *testing.T) {
+		hub, connect := setupHub(t, map[int64][]int64{
+			1: {10},
+			2: {10},
+		}, nil)
+
+		sender := connect(1)
+		participant := connect(2)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, hub, event.TypeThreadReplied, 10, event.ThreadReplyData{
+			UserID:      2,
+			ThreadID:    100,
+			MessageID:   101,
+			SenderID:    1,
+			UnreadCount: 1,
+		})
+		require.Equal(t, event.TypeThreadReplied, readEvent(t, participant).Type)
+
+		requireNoEvent(t, sender)
+	})
+}
+
+func TestHub_CrossInstance(t *testing.T) {
+	chatIDs := map[int64][]int64{
+		1: {10},
+		2: {10},
+	}
+
+	t.Run("delivers events to members on other instances", func(t *testing.T) {
+		broker := memorybus.NewBroker()
+		first, connectFirst := setupHubOnBus(t, memorybus.NewBus(broker), chatIDs, nil)
+		_, connectSecond := setupHubOnBus(t, memorybus.NewBus(broker), chatIDs, nil)
+
+		local := connectFirst(1)
+		remote := connectSecond(2)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, first, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, local).Type)
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, remote).Type)
+	})
+
+	t.Run("delivers member added to the added user on another instance", func(t *testing.T) {
+		broker := memorybus.NewBroker()
+		first, connectFirst := setupHubOnBus(t, memorybus.NewBus(broker), chatIDs, nil)
+		_, connectSecond := setupHubOnBus(t, memorybus.NewBus(broker), map[int64][]int64{}, nil)
+
+		owner := connectFirst(1)
+		added := connectSecond(3)
+		time.Sleep(50 * time.Millisecond)
+
+		publish(t, first, event.TypeMemberAdded, 10, event.MemberData{UserID: 3})
+		require.Equal(t, event.TypeMemberAdded, readEvent(t, owner).Type)
+		require.Equal(t, event.TypeMemberAdded, readEvent(t, added).Type)
+
+		// The next event also proves the added user did not get member added
+		// twice, once per topic.
+		publish(t, first, event.TypeMessageCreated, 10, map[string]string{"body": "hello"})
+		require.Equal(t, event.TypeMessageCreated, readEvent(t, added).Type)
+	})
+
+	t.Run("subscribes only to chats of connected users", func(t *testing.T) {
+		bus := newTopicsBus(memorybus.NewBus(nil))
+		_, connect := setupHubOnBus(t, bus, map[int64][]int64{
+			1: {10, 11},
+			2: {20},
+		}, nil)
+
+		conn := connect(1)
+		time.Sleep(50 * time.Millisecond)
+
+		require.ElementsMatch(t, []string{
+			event.UserTopic(1),
+			event.ChatTopic(10),
+			event.ChatTopic(11),
+		}, bus.Topics())
+
+		require.NoError(t, conn.Close())
+		time.Sleep(50 * time.Millisecond)
+
+		require.Empty(t, bus.Topics())
+	})
+}
+
+func TestHub_Frames(t *testing.T) {
+	t.Run("replies to inbound frames", func(t *testing.T) {
+		_, connect := setupHub(t, map[int64][]int64{}, func(_ context.Context, c *ws.Client, frame *ws.InboundFrame) {
+			c.Reply(frame.RequestID, map[string]int64{"user_id": c.UserID()})
+		})
+
+		conn := connect(1)
+		require.NoError(t, conn.WriteJSON(ws.InboundFrame{
+			Type:      ws.FrameTypeMessageSend,
+			RequestID: "req-1",
+		}))
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		var reply struct {
+			Type      ws.FrameType    `json:"type"`
+			RequestID string          `json:"request_id"`
+			Data      json.RawMessage `json:"data"`
+		}
+		require.NoError(t, conn.ReadJSON(&reply))
+		require.Equal(t, ws.FrameTypeAck, reply.Type)
+		require.Equal(t, "req-1", reply.RequestID)
+		require.JSONEq(t, `{"user_id":1}`, string(reply.Data))
+	})
+}
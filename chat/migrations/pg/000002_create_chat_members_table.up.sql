@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS chat_members (
+    chat_id BIGINT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS chat_members_user_id_idx ON chat_members (user_id)
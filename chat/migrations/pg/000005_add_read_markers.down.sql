@@ -0,0 +1,3 @@
+ALTER TABLE chat_members
+    DROP COLUMN IF EXISTS last_read_message_id,
+    DROP COLUMN IF EXISTS last_read_at
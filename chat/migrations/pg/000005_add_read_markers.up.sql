@@ -0,0 +1,3 @@
+ALTER TABLE chat_members
+    ADD COLUMN IF NOT EXISTS last_read_message_id BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMPTZ
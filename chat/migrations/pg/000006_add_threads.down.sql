@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS thread_members;
+
+DROP INDEX IF EXISTS messages_thread_id_id_idx;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS reply_to_id,
+    DROP COLUMN IF EXISTS thread_id,
+    DROP COLUMN IF EXISTS reply_count,
+    DROP COLUMN IF EXISTS last_reply_at
@@ -0,0 +1,16 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS reply_to_id BIGINT REFERENCES messages (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS thread_id BIGINT REFERENCES messages (id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS reply_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_reply_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS messages_thread_id_id_idx ON messages (thread_id, id DESC) WHERE thread_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS thread_members (
+    thread_id BIGINT NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    last_read_message_id BIGINT NOT NULL DEFAULT 0,
+    last_read_at TIMESTAMPTZ,
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (thread_id, user_id)
+)
@@ -4,20 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
 
 	"github.com/maximegorov13/chat-app/id/configs"
 	authhttp "github.com/maximegorov13/chat-app/id/internal/auth/delivery/http"
 	authredis "github.com/maximegorov13/chat-app/id/internal/auth/repository/redis"
 	authservice "github.com/maximegorov13/chat-app/id/internal/auth/service"
+	"github.com/maximegorov13/chat-app/id/internal/health"
+	healthhttp "github.com/maximegorov13/chat-app/id/internal/health/delivery/http"
 	"github.com/maximegorov13/chat-app/id/internal/keyreader"
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	logmailer "github.com/maximegorov13/chat-app/id/internal/mailer/log"
+	smtpmailer "github.com/maximegorov13/chat-app/id/internal/mailer/smtp"
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	mfahttp "github.com/maximegorov13/chat-app/id/internal/mfa/delivery/http"
+	mfapg "github.com/maximegorov13/chat-app/id/internal/mfa/repository/pg"
+	mfaservice "github.com/maximegorov13/chat-app/id/internal/mfa/service"
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
+	sessionhttp "github.com/maximegorov13/chat-app/id/internal/session/delivery/http"
+	sessionredis "github.com/maximegorov13/chat-app/id/internal/session/repository/redis"
+	sessionservice "github.com/maximegorov13/chat-app/id/internal/session/service"
 	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
 	"github.com/maximegorov13/chat-app/id/internal/storage/redis"
 	userhttp "github.com/maximegorov13/chat-app/id/internal/user/delivery/http"
@@ -25,58 +43,122 @@ import (
 	userservice "github.com/maximegorov13/chat-app/id/internal/user/service"
 )
 
+// serviceName identifies the service in traces.
+const serviceName = "id"
+
 func main() {
 	conf, err := configs.Load()
 	if err != nil {
-		log.Fatal(err)
+		fatal("Error loading config", err)
 	}
 
+	appLogger, err := logger.New(os.Stdout, conf.Log.Level, conf.Log.Format)
+	if err != nil {
+		fatal("Error creating logger", err)
+	}
+	slog.SetDefault(appLogger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: serviceName,
+		Exporter:    conf.Tracing.Exporter,
+		FilePath:    conf.Tracing.FilePath,
+		OTLPURL:     conf.Tracing.OTLPURL,
+		SampleRatio: conf.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Error setting up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error when flushing traces", "error", err)
+		}
+	}()
+
 	pgClient, err := pg.NewPostgres(conf)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Error connecting to Postgres", err)
 	}
 	defer func() {
 		if err := pgClient.Sqlx.Close(); err != nil {
-			log.Printf("Error when closing the Postgres connection: %v\n", err)
+			slog.Error("Error when closing the Postgres connection", "error", err)
 		}
 	}()
 
 	redisClient, err := redis.NewRedis(context.Background(), conf)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Error connecting to Redis", err)
 	}
 	defer func() {
 		if err := redisClient.Close(); err != nil {
-			log.Printf("Error when closing the Redis connection: %v\n", err)
+			slog.Error("Error when closing the Redis connection", "error", err)
 		}
 	}()
 
 	keyReader := keyreader.NewKeyReader(conf.Auth.SecretKeysPath)
-	privateKey, err := keyReader.ReadPrivateKey(conf.Auth.PostfixKeyAuth)
+	keyRing, err := keyReader.ReadKeyRing(conf.Auth.PostfixKeyAuth)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Error reading keys", err)
 	}
-	publicKey, err := keyReader.ReadPublicKey(conf.Auth.PostfixKeyAuth)
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go keyReader.Watch(watchCtx, keyRing, conf.Auth.PostfixKeyAuth, conf.Auth.KeysReloadInterval)
+
+	jwtMaker := jwt.NewJWTWithKeyRing(keyRing,
+		jwt.WithIssuer(conf.Auth.Issuer),
+		jwt.WithAudience(conf.Auth.Audience),
+	)
+
+	mail, closeMail, err := newMailer(conf)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Error creating mailer", err)
 	}
-
-	jwtMaker := jwt.NewJWT(privateKey, publicKey)
+	defer func() {
+		if err := closeMail(); err != nil {
+			slog.Error("Error when closing the mail log", "error", err)
+		}
+	}()
 
 	// Repositories
 	userRepo := userpg.NewUserRepository(pgClient)
 	tokenRepo := authredis.NewTokenRepository(redisClient)
+	loginAttemptRepo := authredis.NewLoginAttemptRepository(redisClient)
+	sessionRepo := sessionredis.NewSessionRepository(redisClient)
+	mfaRepo := mfapg.NewMFARepository(pgClient)
 
 	// Services
+	sessionService := sessionservice.NewSessionService(sessionservice.SessionServiceDeps{
+		Conf:        conf,
+		SessionRepo: sessionRepo,
+		TokenRepo:   tokenRepo,
+	})
 	userService := userservice.NewUserService(userservice.UserServiceDeps{
+		Conf:           conf,
+		UserRepo:       userRepo,
+		TokenRepo:      tokenRepo,
+		SessionService: sessionService,
+		Mailer:         mail,
+	})
+	mfaService := mfaservice.NewMFAService(mfaservice.MFAServiceDeps{
+		Conf:     conf,
+		MFARepo:  mfaRepo,
 		UserRepo: userRepo,
 	})
 	authService := authservice.NewAuthService(authservice.AuthServiceDeps{
-		UserRepo:  userRepo,
-		TokenRepo: tokenRepo,
-		JWT:       jwtMaker,
+		Conf:             conf,
+		UserRepo:         userRepo,
+		TokenRepo:        tokenRepo,
+		LoginAttemptRepo: loginAttemptRepo,
+		SessionService:   sessionService,
+		MFAService:       mfaService,
+		Mailer:           mail,
+		JWT:              jwtMaker,
 	})
 
+	rateLimiter := newRateLimiter(conf, redisClient)
+
 	router := http.NewServeMux()
 
 	// Handlers
@@ -84,35 +166,128 @@ func main() {
 		Conf:        conf,
 		UserService: userService,
 		TokenRepo:   tokenRepo,
+		SessionRepo: sessionRepo,
 		JWT:         jwtMaker,
+		RateLimiter: rateLimiter,
 	})
 	authhttp.NewAuthHandler(router, authhttp.AuthHandlerDeps{
 		Conf:        conf,
 		AuthService: authService,
+		KeyRing:     keyRing,
+		RateLimiter: rateLimiter,
+	})
+	mfahttp.NewMFAHandler(router, mfahttp.MFAHandlerDeps{
+		Conf:        conf,
+		MFAService:  mfaService,
+		TokenRepo:   tokenRepo,
+		SessionRepo: sessionRepo,
+		JWT:         jwtMaker,
+		RateLimiter: rateLimiter,
+	})
+	sessionhttp.NewSessionHandler(router, sessionhttp.SessionHandlerDeps{
+		Conf:           conf,
+		SessionService: sessionService,
+		SessionRepo:    sessionRepo,
+		TokenRepo:      tokenRepo,
+		JWT:            jwtMaker,
 	})
 
+	readiness := health.NewReadiness(conf.Server.ReadinessTimeout,
+		health.Check{Name: "postgres", Ping: pgClient.Sqlx.PingContext},
+		health.Check{Name: "redis", Ping: redisClient.Ping},
+	)
+	healthhttp.NewHealthHandler(router, healthhttp.HealthHandlerDeps{
+		Readiness: readiness,
+	})
+
+	router.Handle("GET /metrics", promhttp.Handler())
+
+	// Middlewares are applied from the innermost one, metrics and tracing
+	// have to wrap the router to see the matched route.
+	var handler http.Handler = metrics.HTTP.Middleware(router)
+	handler = tracing.Middleware(handler)
+	handler = middleware.MaxBytes(handler, conf.Server.MaxBodyBytes)
+	handler = middleware.CORS(handler, middleware.CORSDeps{
+		AllowedOrigins: conf.Server.CORS.AllowedOrigins,
+		MaxAge:         conf.Server.CORS.MaxAge,
+	})
+	handler = middleware.SecurityHeaders(handler, conf.Server.HSTSMaxAge)
+	handler = middleware.AccessLog(handler)
+	handler = middleware.ClientIP(handler, middleware.ClientIPDeps{
+		TrustedProxies: conf.Server.TrustedProxies,
+	})
+	handler = middleware.RequestID(handler)
+
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%v", conf.Server.Port),
-		Handler: router,
+		Addr:              fmt.Sprintf(":%v", conf.Server.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: conf.Server.ReadHeaderTimeout,
+		ReadTimeout:       conf.Server.ReadTimeout,
+		WriteTimeout:      conf.Server.WriteTimeout,
+		IdleTimeout:       conf.Server.IdleTimeout,
 	}
 
 	go func() {
-		log.Printf("Starting server on port %v", conf.Server.Port)
+		slog.Info("Starting server", "port", conf.Server.Port)
 		if err = server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("HTTP server error: %v", err)
+			fatal("HTTP server error", err)
 		}
-		log.Println("Stopped serving new connections")
+		slog.Info("Stopped serving new connections")
 	}()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
+	// Fail readiness first and keep serving while load balancers notice it
+	readiness.Drain()
+	slog.Info("Draining before shutdown", "delay", conf.Server.DrainDelay)
+	time.Sleep(conf.Server.DrainDelay)
+
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownRelease()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("HTTP shutdown error: %v", err)
+		fatal("HTTP shutdown error", err)
+	}
+	slog.Info("Graceful shutdown complete")
+}
+
+// newMailer creates the mailer selected by the config along with a function
+// releasing its resources.
+func newMailer(conf *configs.Config) (mailer.Mailer, func() error, error) {
+	if conf.Mail.Driver == configs.MailDriverSMTP {
+		return smtpmailer.NewMailer(smtpmailer.Config{
+			Host:     conf.Mail.SMTP.Host,
+			Port:     conf.Mail.SMTP.Port,
+			Username: conf.Mail.SMTP.Username,
+			Password: conf.Mail.SMTP.Password,
+			From:     conf.Mail.From,
+		}), func() error { return nil }, nil
 	}
-	log.Println("Graceful shutdown complete")
+
+	if conf.Mail.LogPath == "" {
+		return logmailer.NewMailer(conf.Mail.From, os.Stdout), func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(conf.Mail.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open mail log: %w", err)
+	}
+
+	return logmailer.NewMailer(conf.Mail.From, f), f.Close, nil
+}
+
+func newRateLimiter(conf *configs.Config, redisClient *redis.Redis) ratelimit.Limiter {
+	if conf.RateLimit.Driver == configs.RateLimitDriverMemory {
+		return ratelimit.NewMemoryLimiter()
+	}
+
+	return ratelimit.NewRedisLimiter(redisClient)
+}
+
+// fatal logs err and exits. Deferred functions are not run.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
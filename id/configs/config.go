@@ -1,40 +1,156 @@
 package configs
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"github.com/joho/godotenv"
+
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	Redis    RedisConfig
-	Auth     AuthConfig
+	Log           LogConfig
+	Tracing       TracingConfig
+	Server        ServerConfig
+	Postgres      PostgresConfig
+	Redis         RedisConfig
+	Auth          AuthConfig
+	LoginThrottle LoginThrottleConfig
+	RateLimit     RateLimitConfig
+	Mail          MailConfig
 }
 
 func (c Config) Validate() error {
 	return validation.ValidateStruct(&c,
+		validation.Field(&c.Log),
+		validation.Field(&c.Tracing),
 		validation.Field(&c.Server),
 		validation.Field(&c.Postgres),
 		validation.Field(&c.Redis),
 		validation.Field(&c.Auth),
+		validation.Field(&c.LoginThrottle),
+		validation.Field(&c.RateLimit),
+		validation.Field(&c.Mail),
+	)
+}
+
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string
+}
+
+func (l LogConfig) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.Level, validation.Required, validation.In("debug", "info", "warn", "error")),
+		validation.Field(&l.Format, validation.Required, validation.In(logger.FormatText, logger.FormatJSON)),
+	)
+}
+
+type TracingConfig struct {
+	Exporter    string  // none, stdout or otlp
+	FilePath    string  // File the stdout exporter appends to, stdout if empty
+	OTLPURL     string  // OTLP/HTTP endpoint of the collector
+	SampleRatio float64 // Share of new traces that are sampled, from 0 to 1
+}
+
+func (t TracingConfig) Validate() error {
+	err := validation.ValidateStruct(&t,
+		validation.Field(&t.Exporter, validation.Required, validation.In(tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)),
+		validation.Field(&t.SampleRatio, validation.Min(0.0), validation.Max(1.0)),
 	)
+	if err != nil {
+		return err
+	}
+
+	if t.Exporter == tracing.ExporterOTLP {
+		return validation.ValidateStruct(&t,
+			validation.Field(&t.OTLPURL, validation.Required, is.URL),
+		)
+	}
+
+	return nil
 }
 
 type ServerConfig struct {
-	Port string
+	Port              string
+	ReadinessTimeout  time.Duration // Deadline of the dependency checks of GET /readyz
+	DrainDelay        time.Duration // Time between failing readiness and shutting down
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxBodyBytes      int64
+	HSTSMaxAge        time.Duration // Strict-Transport-Security is not sent if zero
+	TrustedProxies    []string      // IPs or CIDR ranges allowed to set X-Forwarded-For
+	CORS              CORSConfig
 }
 
 func (s ServerConfig) Validate() error {
 	return validation.ValidateStruct(&s,
 		validation.Field(&s.Port, validation.Required, is.Port),
+		validation.Field(&s.ReadinessTimeout, validation.Required),
+		validation.Field(&s.DrainDelay, validation.Min(time.Duration(0))),
+		validation.Field(&s.ReadHeaderTimeout, validation.Required),
+		validation.Field(&s.ReadTimeout, validation.Required, validation.Min(s.ReadHeaderTimeout)),
+		validation.Field(&s.WriteTimeout, validation.Required),
+		validation.Field(&s.IdleTimeout, validation.Required),
+		validation.Field(&s.MaxBodyBytes, validation.Required, validation.Min(int64(1))),
+		validation.Field(&s.HSTSMaxAge, validation.Min(time.Duration(0))),
+		validation.Field(&s.TrustedProxies, validation.Each(validation.By(proxyAddress))),
+		validation.Field(&s.CORS),
+	)
+}
+
+func proxyAddress(value interface{}) error {
+	proxy, _ := value.(string)
+	if _, err := netip.ParsePrefix(proxy); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddr(proxy); err == nil {
+		return nil
+	}
+
+	return errors.New("must be an IP address or a CIDR range")
+}
+
+// CORSConfig lists the browser origins allowed to call the API, see
+// middleware.CORSDeps for the accepted patterns.
+type CORSConfig struct {
+	AllowedOrigins []string
+	MaxAge         time.Duration
+}
+
+func (c CORSConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.AllowedOrigins, validation.Each(validation.By(originPattern))),
+		validation.Field(&c.MaxAge, validation.Min(time.Duration(0))),
 	)
 }
 
+func originPattern(value interface{}) error {
+	origin, _ := value.(string)
+	if origin == "*" {
+		return nil
+	}
+
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || (scheme != "http" && scheme != "https") || host == "" || strings.ContainsAny(host, "/?#") {
+		return errors.New("must be an origin such as https://example.com")
+	}
+
+	return nil
+}
+
 type PostgresConfig struct {
 	Url string
 }
@@ -58,12 +174,142 @@ func (r RedisConfig) Validate() error {
 type AuthConfig struct {
 	SecretKeysPath string
 	PostfixKeyAuth string
+	Issuer         string
+	Audience       string
+	// AccessTokenTTL is kept short, at most an hour, since the chat service
+	// verifies access tokens locally.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// KeysReloadInterval is how often keys are reloaded from SecretKeysPath.
+	// Zero means only on SIGHUP.
+	KeysReloadInterval        time.Duration
+	PasswordResetTokenTTL     time.Duration
+	EmailVerificationTokenTTL time.Duration
+	MFAIssuer                 string        // Issuer shown in authenticator apps
+	MFAChallengeTTL           time.Duration // Time to enter the code after the password
+	// ServiceToken authenticates other services of the app calling the
+	// internal API, such as the chat service recording when users were last
+	// seen.
+	ServiceToken string
 }
 
 func (a AuthConfig) Validate() error {
 	return validation.ValidateStruct(&a,
 		validation.Field(&a.SecretKeysPath, validation.Required),
 		validation.Field(&a.PostfixKeyAuth, validation.Required),
+		validation.Field(&a.Issuer, validation.Required),
+		validation.Field(&a.Audience, validation.Required),
+		validation.Field(&a.AccessTokenTTL, validation.Required, validation.Max(time.Hour)),
+		validation.Field(&a.RefreshTokenTTL, validation.Required),
+		validation.Field(&a.PasswordResetTokenTTL, validation.Required),
+		validation.Field(&a.EmailVerificationTokenTTL, validation.Required),
+		validation.Field(&a.MFAIssuer, validation.Required),
+		validation.Field(&a.MFAChallengeTTL, validation.Required),
+		validation.Field(&a.ServiceToken, validation.Required, validation.Length(32, 0)),
+	)
+}
+
+// LoginThrottleConfig limits password guessing. Failed logins are counted per
+// login and per client IP within Window. After DelayAfter failures every
+// further attempt has to wait BaseDelay, doubled with each failure up to
+// MaxDelay, and after the lockout threshold the scope is locked for
+// LockoutDuration.
+type LoginThrottleConfig struct {
+	Window            time.Duration
+	DelayAfter        int
+	BaseDelay         time.Duration
+	MaxDelay          time.Duration
+	LoginLockoutAfter int // Failures per login before it is locked
+	IPLockoutAfter    int // Failures per client IP before it is locked
+	LockoutDuration   time.Duration
+}
+
+func (l LoginThrottleConfig) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.Window, validation.Required),
+		validation.Field(&l.DelayAfter, validation.Required),
+		validation.Field(&l.BaseDelay, validation.Required),
+		validation.Field(&l.MaxDelay, validation.Required, validation.Min(l.BaseDelay)),
+		validation.Field(&l.LoginLockoutAfter, validation.Required, validation.Min(l.DelayAfter)),
+		validation.Field(&l.IPLockoutAfter, validation.Required, validation.Min(l.DelayAfter)),
+		validation.Field(&l.LockoutDuration, validation.Required),
+	)
+}
+
+const (
+	RateLimitDriverMemory = "memory"
+	RateLimitDriverRedis  = "redis"
+)
+
+// RateLimitConfig holds the request limits of the public endpoints. The
+// memory driver keeps limits per instance.
+type RateLimitConfig struct {
+	Driver   string
+	Auth     ratelimit.Limit // Login, token refresh, password reset and email verification
+	Register ratelimit.Limit
+}
+
+func (r RateLimitConfig) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Driver, validation.Required, validation.In(RateLimitDriverMemory, RateLimitDriverRedis)),
+		validation.Field(&r.Auth, validation.By(limitRequired)),
+		validation.Field(&r.Register, validation.By(limitRequired)),
+	)
+}
+
+func limitRequired(value interface{}) error {
+	if limit, _ := value.(ratelimit.Limit); limit.Burst == 0 {
+		return errors.New("cannot be blank")
+	}
+
+	return nil
+}
+
+const (
+	MailDriverLog  = "log"
+	MailDriverSMTP = "smtp"
+)
+
+// MailConfig selects how emails are delivered. The log driver writes them to
+// LogPath, or to stdout if it is empty, and is meant for local development.
+type MailConfig struct {
+	Driver  string
+	From    string
+	AppURL  string // Base URL of the client, links in emails point to it
+	LogPath string
+	SMTP    SMTPConfig
+}
+
+func (m MailConfig) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.Driver, validation.Required, validation.In(MailDriverLog, MailDriverSMTP)),
+		validation.Field(&m.From, validation.Required, is.Email),
+		validation.Field(&m.AppURL, validation.Required, is.URL),
+	)
+	if err != nil {
+		return err
+	}
+
+	if m.Driver == MailDriverSMTP {
+		return validation.Errors{
+			"SMTP": m.SMTP.Validate(),
+		}.Filter()
+	}
+
+	return nil
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (s SMTPConfig) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Host, validation.Required),
+		validation.Field(&s.Port, validation.Required, is.Port),
 	)
 }
 
@@ -78,9 +324,133 @@ func Load(envPath ...string) (*Config, error) {
 		}
 	}
 
+	traceSampleRatio, err := getEnvFloat("TRACING_SAMPLE_RATIO")
+	if err != nil {
+		return nil, err
+	}
+	readinessTimeout, err := getEnvDuration("READINESS_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	drainDelay, err := getEnvDuration("SHUTDOWN_DRAIN_DELAY")
+	if err != nil {
+		return nil, err
+	}
+	readHeaderTimeout, err := getEnvDuration("SERVER_READ_HEADER_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	readTimeout, err := getEnvDuration("SERVER_READ_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	writeTimeout, err := getEnvDuration("SERVER_WRITE_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := getEnvDuration("SERVER_IDLE_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	maxBodyBytes, err := getEnvInt("SERVER_MAX_BODY_BYTES")
+	if err != nil {
+		return nil, err
+	}
+	hstsMaxAge, err := getEnvDuration("HSTS_MAX_AGE")
+	if err != nil {
+		return nil, err
+	}
+	corsMaxAge, err := getEnvDuration("CORS_MAX_AGE")
+	if err != nil {
+		return nil, err
+	}
+	accessTokenTTL, err := getEnvDuration("ACCESS_TOKEN_TTL")
+	if err != nil {
+		return nil, err
+	}
+	refreshTokenTTL, err := getEnvDuration("REFRESH_TOKEN_TTL")
+	if err != nil {
+		return nil, err
+	}
+	keysReloadInterval, err := getEnvDuration("KEYS_RELOAD_INTERVAL")
+	if err != nil {
+		return nil, err
+	}
+	passwordResetTokenTTL, err := getEnvDuration("PASSWORD_RESET_TOKEN_TTL")
+	if err != nil {
+		return nil, err
+	}
+	emailVerificationTokenTTL, err := getEnvDuration("EMAIL_VERIFICATION_TOKEN_TTL")
+	if err != nil {
+		return nil, err
+	}
+	mfaChallengeTTL, err := getEnvDuration("MFA_CHALLENGE_TTL")
+	if err != nil {
+		return nil, err
+	}
+	loginFailureWindow, err := getEnvDuration("LOGIN_FAILURE_WINDOW")
+	if err != nil {
+		return nil, err
+	}
+	loginDelayAfter, err := getEnvInt("LOGIN_DELAY_AFTER")
+	if err != nil {
+		return nil, err
+	}
+	loginBaseDelay, err := getEnvDuration("LOGIN_BASE_DELAY")
+	if err != nil {
+		return nil, err
+	}
+	loginMaxDelay, err := getEnvDuration("LOGIN_MAX_DELAY")
+	if err != nil {
+		return nil, err
+	}
+	loginLockoutAfter, err := getEnvInt("LOGIN_LOCKOUT_AFTER")
+	if err != nil {
+		return nil, err
+	}
+	loginIPLockoutAfter, err := getEnvInt("LOGIN_IP_LOCKOUT_AFTER")
+	if err != nil {
+		return nil, err
+	}
+	loginLockoutDuration, err := getEnvDuration("LOGIN_LOCKOUT_DURATION")
+	if err != nil {
+		return nil, err
+	}
+	authRateLimit, err := getEnvLimit("RATE_LIMIT_AUTH")
+	if err != nil {
+		return nil, err
+	}
+	registerRateLimit, err := getEnvLimit("RATE_LIMIT_REGISTER")
+	if err != nil {
+		return nil, err
+	}
+
 	conf := &Config{
+		Log: LogConfig{
+			Level:  os.Getenv("LOG_LEVEL"),
+			Format: os.Getenv("LOG_FORMAT"),
+		},
+		Tracing: TracingConfig{
+			Exporter:    os.Getenv("TRACING_EXPORTER"),
+			FilePath:    os.Getenv("TRACING_FILE_PATH"),
+			OTLPURL:     os.Getenv("TRACING_OTLP_URL"),
+			SampleRatio: traceSampleRatio,
+		},
 		Server: ServerConfig{
-			Port: os.Getenv("PORT"),
+			Port:              os.Getenv("PORT"),
+			ReadinessTimeout:  readinessTimeout,
+			DrainDelay:        drainDelay,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+			MaxBodyBytes:      int64(maxBodyBytes),
+			HSTSMaxAge:        hstsMaxAge,
+			TrustedProxies:    getEnvList("TRUSTED_PROXIES"),
+			CORS: CORSConfig{
+				AllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS"),
+				MaxAge:         corsMaxAge,
+			},
 		},
 		Postgres: PostgresConfig{
 			Url: os.Getenv("POSTGRES_URL"),
@@ -89,8 +459,44 @@ func Load(envPath ...string) (*Config, error) {
 			Url: os.Getenv("REDIS_URL"),
 		},
 		Auth: AuthConfig{
-			SecretKeysPath: os.Getenv("SECRET_KEYS_PATH"),
-			PostfixKeyAuth: os.Getenv("POSTFIX_KEY_AUTH"),
+			SecretKeysPath:            os.Getenv("SECRET_KEYS_PATH"),
+			PostfixKeyAuth:            os.Getenv("POSTFIX_KEY_AUTH"),
+			Issuer:                    os.Getenv("JWT_ISSUER"),
+			Audience:                  os.Getenv("JWT_AUDIENCE"),
+			AccessTokenTTL:            accessTokenTTL,
+			RefreshTokenTTL:           refreshTokenTTL,
+			KeysReloadInterval:        keysReloadInterval,
+			PasswordResetTokenTTL:     passwordResetTokenTTL,
+			EmailVerificationTokenTTL: emailVerificationTokenTTL,
+			MFAIssuer:                 os.Getenv("MFA_ISSUER"),
+			MFAChallengeTTL:           mfaChallengeTTL,
+			ServiceToken:              os.Getenv("SERVICE_TOKEN"),
+		},
+		LoginThrottle: LoginThrottleConfig{
+			Window:            loginFailureWindow,
+			DelayAfter:        loginDelayAfter,
+			BaseDelay:         loginBaseDelay,
+			MaxDelay:          loginMaxDelay,
+			LoginLockoutAfter: loginLockoutAfter,
+			IPLockoutAfter:    loginIPLockoutAfter,
+			LockoutDuration:   loginLockoutDuration,
+		},
+		RateLimit: RateLimitConfig{
+			Driver:   os.Getenv("RATE_LIMIT_DRIVER"),
+			Auth:     authRateLimit,
+			Register: registerRateLimit,
+		},
+		Mail: MailConfig{
+			Driver:  os.Getenv("MAIL_DRIVER"),
+			From:    os.Getenv("MAIL_FROM"),
+			AppURL:  os.Getenv("APP_URL"),
+			LogPath: os.Getenv("MAIL_LOG_PATH"),
+			SMTP: SMTPConfig{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     os.Getenv("SMTP_PORT"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			},
 		},
 	}
 
@@ -100,3 +506,71 @@ func Load(envPath ...string) (*Config, error) {
 
 	return conf, nil
 }
+
+func getEnvDuration(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func getEnvInt(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+// getEnvList splits a comma-separated value, skipping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func getEnvFloat(key string) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+func getEnvLimit(key string) (ratelimit.Limit, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return ratelimit.Limit{}, nil
+	}
+
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("error parsing %s: %w", key, err)
+	}
+
+	return limit, nil
+}
@@ -5,7 +5,9 @@ import "context"
 type contextKey string
 
 const (
-	contextUserIDKey contextKey = "ContextUserIDKey"
+	contextUserIDKey    contextKey = "ContextUserIDKey"
+	contextSessionIDKey contextKey = "ContextSessionIDKey"
+	contextClientIPKey  contextKey = "ContextClientIPKey"
 )
 
 func SetContextUserID(ctx context.Context, userId string) context.Context {
@@ -15,3 +17,28 @@ func SetContextUserID(ctx context.Context, userId string) context.Context {
 func GetContextUserID(ctx context.Context) string {
 	return ctx.Value(contextUserIDKey).(string)
 }
+
+// LookupContextUserID returns the user ID of authenticated requests and false
+// for anonymous ones.
+func LookupContextUserID(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(contextUserIDKey).(string)
+	return userID, ok
+}
+
+func SetContextSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, contextSessionIDKey, sessionID)
+}
+
+func GetContextSessionID(ctx context.Context) string {
+	return ctx.Value(contextSessionIDKey).(string)
+}
+
+func SetContextClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextClientIPKey, ip)
+}
+
+// LookupContextClientIP returns the client IP resolved by middleware.ClientIP.
+func LookupContextClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(contextClientIPKey).(string)
+	return ip, ok
+}
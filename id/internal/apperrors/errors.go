@@ -2,6 +2,7 @@ package apperrors
 
 import (
 	"net/http"
+	"time"
 )
 
 type Error struct {
@@ -24,15 +25,53 @@ var (
 	ErrBadRequest         = NewError(http.StatusBadRequest, "bad request")
 	ErrInvalidRequestBody = NewError(http.StatusBadRequest, "invalid request body")
 	ErrValidationFailed   = NewError(http.StatusBadRequest, "validation failed")
+	ErrInvalidResetToken  = NewError(http.StatusBadRequest, "invalid or expired reset token")
+	ErrInvalidVerifyToken = NewError(http.StatusBadRequest, "invalid or expired verification token")
+	ErrMFANotEnrolled     = NewError(http.StatusBadRequest, "mfa enrollment not started")
+	ErrMFANotEnabled      = NewError(http.StatusBadRequest, "mfa is not enabled")
 
-	ErrUnauthorized       = NewError(http.StatusUnauthorized, "unauthorized")
-	ErrInvalidCredentials = NewError(http.StatusUnauthorized, "invalid credentials")
+	ErrUnauthorized        = NewError(http.StatusUnauthorized, "unauthorized")
+	ErrInvalidCredentials  = NewError(http.StatusUnauthorized, "invalid credentials")
+	ErrInvalidRefreshToken = NewError(http.StatusUnauthorized, "invalid refresh token")
+	ErrInvalidMFAToken     = NewError(http.StatusUnauthorized, "invalid or expired mfa token")
+	ErrInvalidMFACode      = NewError(http.StatusUnauthorized, "invalid mfa code")
 
-	ErrForbidden = NewError(http.StatusForbidden, "forbidden")
+	ErrForbidden        = NewError(http.StatusForbidden, "forbidden")
+	ErrEmailNotVerified = NewError(http.StatusForbidden, "email is not verified")
+	ErrAccountSuspended = NewError(http.StatusForbidden, "account is suspended")
 
 	ErrNotFound = NewError(http.StatusNotFound, "not found")
 
-	ErrUserExists = NewError(http.StatusConflict, "user already exists")
+	ErrUserExists        = NewError(http.StatusConflict, "user already exists")
+	ErrEmailExists       = NewError(http.StatusConflict, "email already in use")
+	ErrMFAAlreadyEnabled = NewError(http.StatusConflict, "mfa is already enabled")
+
+	ErrRequestBodyTooLarge = NewError(http.StatusRequestEntityTooLarge, "request body too large")
+
+	ErrTooManyRequests      = NewError(http.StatusTooManyRequests, "too many requests")
+	ErrTooManyLoginAttempts = NewError(http.StatusTooManyRequests, "too many login attempts")
 
 	ErrInternalServerError = NewError(http.StatusInternalServerError, "internal server error")
 )
+
+// RetryError is an Error the client may retry after RetryAfter, which is sent
+// in the Retry-After header.
+type RetryError struct {
+	Err        *Error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func WithRetryAfter(err *Error, retryAfter time.Duration) *RetryError {
+	return &RetryError{
+		Err:        err,
+		RetryAfter: retryAfter,
+	}
+}
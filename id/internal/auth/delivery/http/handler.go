@@ -1,35 +1,63 @@
 package http
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/maximegorov13/chat-app/id/configs"
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
 	"github.com/maximegorov13/chat-app/id/internal/req"
 	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	"github.com/maximegorov13/chat-app/id/pkg/jwk"
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
 )
 
+// jwksMaxAge lets verifiers and proxies cache the published keys.
+const jwksMaxAge = 5 * time.Minute
+
 type AuthHandlerDeps struct {
 	Conf        *configs.Config
 	AuthService auth.AuthService
+	KeyRing     *jwt.KeyRing
+	RateLimiter ratelimit.Limiter
 }
 
 type AuthHandler struct {
 	conf        *configs.Config
 	authService auth.AuthService
+	keyRing     *jwt.KeyRing
 }
 
 func NewAuthHandler(router *http.ServeMux, deps AuthHandlerDeps) {
 	handler := &AuthHandler{
 		conf:        deps.Conf,
 		authService: deps.AuthService,
+		keyRing:     deps.KeyRing,
+	}
+
+	authLimit := middleware.RateLimitDeps{
+		Limiter: deps.RateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "auth",
+			Limit: deps.Conf.RateLimit.Auth,
+		},
 	}
 
-	router.HandleFunc("POST /api/auth/login", handler.Login())
+	router.Handle("POST /api/auth/login", middleware.RateLimit(handler.Login(), authLimit))
+	router.Handle("POST /api/auth/login/mfa", middleware.RateLimit(handler.LoginMFA(), authLimit))
+	router.Handle("POST /api/auth/refresh", middleware.RateLimit(handler.Refresh(), authLimit))
 	router.HandleFunc("POST /api/auth/logout", handler.Logout())
 	router.HandleFunc("GET /api/auth/is-token-invalid", handler.IsTokenInvalid())
+	router.Handle("POST /api/auth/password-reset/request", middleware.RateLimit(handler.RequestPasswordReset(), authLimit))
+	router.Handle("POST /api/auth/password-reset/confirm", middleware.RateLimit(handler.ConfirmPasswordReset(), authLimit))
+	router.HandleFunc("GET /.well-known/jwks.json", handler.JWKS())
 }
 
 func (h *AuthHandler) Login() http.HandlerFunc {
@@ -40,14 +68,68 @@ func (h *AuthHandler) Login() http.HandlerFunc {
 			return
 		}
 
-		token, err := h.authService.Login(r.Context(), &body.Data)
+		device := session.Device{
+			UserAgent: r.UserAgent(),
+			IP:        req.ClientIP(r),
+		}
+
+		result, err := h.authService.Login(r.Context(), &body.Data, device)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := auth.LoginResponse{
+			Token:        result.AccessToken,
+			RefreshToken: result.RefreshToken,
+			MFARequired:  result.MFAToken != "",
+			MFAToken:     result.MFAToken,
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *AuthHandler) LoginMFA() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[auth.LoginMFARequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		tokens, err := h.authService.LoginMFA(r.Context(), &body.Data)
 		if err != nil {
 			res.Error(w, err)
 			return
 		}
 
 		data := auth.LoginResponse{
-			Token: token,
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *AuthHandler) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[auth.RefreshRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		tokens, err := h.authService.Refresh(r.Context(), &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := auth.RefreshResponse{
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
 		}
 
 		res.JSON(w, http.StatusOK, data, nil)
@@ -83,6 +165,40 @@ func (h *AuthHandler) Logout() http.HandlerFunc {
 	}
 }
 
+func (h *AuthHandler) RequestPasswordReset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[auth.PasswordResetRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		if err = h.authService.RequestPasswordReset(r.Context(), &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
+
+func (h *AuthHandler) ConfirmPasswordReset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[auth.PasswordResetConfirmRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		if err = h.authService.ConfirmPasswordReset(r.Context(), &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
+
 func (h *AuthHandler) IsTokenInvalid() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
@@ -105,3 +221,29 @@ func (h *AuthHandler) IsTokenInvalid() http.HandlerFunc {
 		res.JSON(w, http.StatusOK, data, nil)
 	}
 }
+
+// JWKS publishes the public keys of the key ring for local token
+// verification. The key set is served as is, not wrapped into the API
+// response envelope, as JWKS clients expect.
+func (h *AuthHandler) JWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := h.keyRing.PublicKeys()
+
+		set := jwk.Set{
+			Keys: make([]jwk.Key, 0, len(keys)),
+		}
+		for _, key := range keys {
+			k, err := jwk.NewKey(key.ID, key.Key)
+			if err != nil {
+				res.Error(w, err)
+				return
+			}
+			set.Keys = append(set.Keys, k)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(set)
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "github.com/go-ozzo/ozzo-validation"
+import (
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
 
 type LoginRequest struct {
 	Login    string `json:"login"`
@@ -14,10 +17,64 @@ func (r LoginRequest) Validate() error {
 	)
 }
 
+// LoginResponse carries either the tokens or, when MFARequired is set, the
+// challenge token to pass to the MFA login endpoint.
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"` // TOTP or recovery code
+}
+
+func (r LoginMFARequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MFAToken, validation.Required),
+		validation.Field(&r.Code, validation.Required),
+	)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r RefreshRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.RefreshToken, validation.Required),
+	)
+}
+
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type IsTokenInvalidResponse struct {
 	Invalid bool `json:"invalid"`
 }
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func (r PasswordResetRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.Required, is.Email),
+	)
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (r PasswordResetConfirmRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Token, validation.Required),
+		validation.Field(&r.NewPassword, validation.Required, validation.Length(8, 30)),
+	)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import "time"
+
+// RefreshToken is an opaque, server-side stored token used to obtain new
+// access tokens. All tokens issued by rotating the same login share a FamilyID.
+type RefreshToken struct {
+	Token     string    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordResetToken is a single-use token mailed to the user to set a new
+// password without knowing the current one.
+type PasswordResetToken struct {
+	Token     string    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EmailVerificationToken is a single-use token mailed to the user to confirm
+// they own the email. It is only valid for the email it was sent to.
+type EmailVerificationToken struct {
+	Token     string    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MFAChallenge is issued after a correct password for accounts with MFA. It is
+// exchanged for tokens together with a valid code.
+type MFAChallenge struct {
+	Token     string    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	Login     string    `json:"login"` // Login the password was entered for
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	Attempts  int       `json:"attempts"` // Wrong codes entered so far
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// LoginResult holds the tokens of a successful login or, if the account has
+// MFA enabled, only the MFA challenge token.
+type LoginResult struct {
+	TokenPair
+	MFAToken string
+}
+
+// LoginFailures describes the failed logins of a scope, such as a login or a
+// client IP, within the sliding window.
+type LoginFailures struct {
+	Count int64
+	Last  time.Time
+}
@@ -8,4 +8,27 @@ import (
 type TokenRepository interface {
 	InvalidateToken(ctx context.Context, token string, expiration time.Duration) error
 	IsTokenInvalid(ctx context.Context, token string) (bool, error)
+	SaveRefreshToken(ctx context.Context, token *RefreshToken) error
+	FindRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, token string, expiration time.Duration) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, expiration time.Duration) error
+	IsRefreshTokenFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+	SavePasswordResetToken(ctx context.Context, token *PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, token string) (*PasswordResetToken, error)
+	SaveEmailVerificationToken(ctx context.Context, token *EmailVerificationToken) error
+	ConsumeEmailVerificationToken(ctx context.Context, token string) (*EmailVerificationToken, error)
+	SaveMFAChallenge(ctx context.Context, challenge *MFAChallenge) error
+	FindMFAChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	UpdateMFAChallenge(ctx context.Context, challenge *MFAChallenge) error
+	DeleteMFAChallenge(ctx context.Context, token string) (bool, error)
+}
+
+// LoginAttemptRepository keeps sliding-window counters of failed logins and
+// temporary lockouts per scope.
+type LoginAttemptRepository interface {
+	RecordFailure(ctx context.Context, scope string, at time.Time, window time.Duration) (*LoginFailures, error)
+	FindFailures(ctx context.Context, scope string, now time.Time, window time.Duration) (*LoginFailures, error)
+	Lock(ctx context.Context, scope string, duration time.Duration) error
+	LockedFor(ctx context.Context, scope string) (time.Duration, error)
+	Reset(ctx context.Context, scopes ...string) error
 }
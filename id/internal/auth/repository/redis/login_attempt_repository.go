@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	"github.com/maximegorov13/chat-app/id/internal/rediskeys"
+	storageredis "github.com/maximegorov13/chat-app/id/internal/storage/redis"
+)
+
+// LoginAttemptRepository stores failed logins of a scope in a sorted set
+// scored by their time in milliseconds, so the window slides by dropping the
+// members older than it.
+type LoginAttemptRepository struct {
+	redis *storageredis.Redis
+}
+
+func NewLoginAttemptRepository(redis *storageredis.Redis) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		redis: redis,
+	}
+}
+
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, scope string, at time.Time, window time.Duration) (*auth.LoginFailures, error) {
+	defer metrics.Storage.Observe("redis", "login_attempt", "RecordFailure", time.Now())
+
+	key := rediskeys.LoginFailuresKey(scope)
+
+	if err := r.redis.ZAdd(ctx, key, float64(at.UnixMilli()), uuid.NewString()); err != nil {
+		return nil, err
+	}
+	if err := r.redis.Expire(ctx, key, window); err != nil {
+		return nil, err
+	}
+
+	return r.FindFailures(ctx, scope, at, window)
+}
+
+func (r *LoginAttemptRepository) FindFailures(ctx context.Context, scope string, now time.Time, window time.Duration) (*auth.LoginFailures, error) {
+	defer metrics.Storage.Observe("redis", "login_attempt", "FindFailures", time.Now())
+
+	key := rediskeys.LoginFailuresKey(scope)
+
+	windowStart := strconv.FormatInt(now.Add(-window).UnixMilli(), 10)
+	if err := r.redis.ZRemRangeByScore(ctx, key, "-inf", "("+windowStart); err != nil {
+		return nil, err
+	}
+
+	count, err := r.redis.ZCard(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	failures := &auth.LoginFailures{
+		Count: count,
+	}
+
+	last, ok, err := r.redis.ZMaxScore(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		failures.Last = time.UnixMilli(int64(last))
+	}
+
+	return failures, nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, scope string, duration time.Duration) error {
+	defer metrics.Storage.Observe("redis", "login_attempt", "Lock", time.Now())
+
+	return r.redis.Set(ctx, rediskeys.LoginLockoutKey(scope), "1", duration)
+}
+
+// LockedFor returns how long the scope stays locked, or zero if it is not.
+func (r *LoginAttemptRepository) LockedFor(ctx context.Context, scope string) (time.Duration, error) {
+	defer metrics.Storage.Observe("redis", "login_attempt", "LockedFor", time.Now())
+
+	return r.redis.PTTL(ctx, rediskeys.LoginLockoutKey(scope))
+}
+
+// Reset forgets the failures of the scopes. Active lockouts are kept.
+func (r *LoginAttemptRepository) Reset(ctx context.Context, scopes ...string) error {
+	defer metrics.Storage.Observe("redis", "login_attempt", "Reset", time.Now())
+
+	keys := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		keys = append(keys, rediskeys.LoginFailuresKey(scope))
+	}
+
+	return r.redis.Del(ctx, keys...)
+}
@@ -2,11 +2,14 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
 	"github.com/maximegorov13/chat-app/id/internal/rediskeys"
 	storageredis "github.com/maximegorov13/chat-app/id/internal/storage/redis"
 )
@@ -22,10 +25,14 @@ func NewTokenRepository(redis *storageredis.Redis) *TokenRepository {
 }
 
 func (r *TokenRepository) InvalidateToken(ctx context.Context, token string, expiration time.Duration) error {
+	defer metrics.Storage.Observe("redis", "token", "InvalidateToken", time.Now())
+
 	return r.redis.Set(ctx, rediskeys.InvalidTokenKey(token), "1", expiration)
 }
 
 func (r *TokenRepository) IsTokenInvalid(ctx context.Context, token string) (bool, error) {
+	defer metrics.Storage.Observe("redis", "token", "IsTokenInvalid", time.Now())
+
 	_, err := r.redis.Get(ctx, rediskeys.InvalidTokenKey(token))
 	if errors.Is(err, redis.Nil) {
 		return false, nil
@@ -36,3 +43,184 @@ func (r *TokenRepository) IsTokenInvalid(ctx context.Context, token string) (boo
 
 	return true, nil
 }
+
+func (r *TokenRepository) SaveRefreshToken(ctx context.Context, token *auth.RefreshToken) error {
+	defer metrics.Storage.Observe("redis", "token", "SaveRefreshToken", time.Now())
+
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.Set(ctx, rediskeys.RefreshTokenKey(token.Token), value, time.Until(token.ExpiresAt))
+}
+
+func (r *TokenRepository) FindRefreshToken(ctx context.Context, token string) (*auth.RefreshToken, error) {
+	defer metrics.Storage.Observe("redis", "token", "FindRefreshToken", time.Now())
+
+	value, err := r.redis.Get(ctx, rediskeys.RefreshTokenKey(token))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t auth.RefreshToken
+	if err = json.Unmarshal([]byte(value), &t); err != nil {
+		return nil, err
+	}
+	t.Token = token
+
+	return &t, nil
+}
+
+// MarkRefreshTokenUsed atomically flags the token as rotated. It returns false
+// if the token had already been used, which means it is being replayed.
+func (r *TokenRepository) MarkRefreshTokenUsed(ctx context.Context, token string, expiration time.Duration) (bool, error) {
+	defer metrics.Storage.Observe("redis", "token", "MarkRefreshTokenUsed", time.Now())
+
+	return r.redis.SetNX(ctx, rediskeys.UsedRefreshTokenKey(token), "1", expiration)
+}
+
+func (r *TokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	defer metrics.Storage.Observe("redis", "token", "RevokeRefreshTokenFamily", time.Now())
+
+	return r.redis.Set(ctx, rediskeys.RevokedRefreshTokenFamilyKey(familyID), "1", expiration)
+}
+
+func (r *TokenRepository) IsRefreshTokenFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	defer metrics.Storage.Observe("redis", "token", "IsRefreshTokenFamilyRevoked", time.Now())
+
+	_, err := r.redis.Get(ctx, rediskeys.RevokedRefreshTokenFamilyKey(familyID))
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *TokenRepository) SavePasswordResetToken(ctx context.Context, token *auth.PasswordResetToken) error {
+	defer metrics.Storage.Observe("redis", "token", "SavePasswordResetToken", time.Now())
+
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.Set(ctx, rediskeys.PasswordResetTokenKey(token.Token), value, time.Until(token.ExpiresAt))
+}
+
+// ConsumePasswordResetToken atomically fetches and deletes the token, so it
+// can be used only once. It returns nil if the token is unknown or expired.
+func (r *TokenRepository) ConsumePasswordResetToken(ctx context.Context, token string) (*auth.PasswordResetToken, error) {
+	defer metrics.Storage.Observe("redis", "token", "ConsumePasswordResetToken", time.Now())
+
+	value, err := r.redis.GetDel(ctx, rediskeys.PasswordResetTokenKey(token))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t auth.PasswordResetToken
+	if err = json.Unmarshal([]byte(value), &t); err != nil {
+		return nil, err
+	}
+	t.Token = token
+
+	return &t, nil
+}
+
+func (r *TokenRepository) SaveEmailVerificationToken(ctx context.Context, token *auth.EmailVerificationToken) error {
+	defer metrics.Storage.Observe("redis", "token", "SaveEmailVerificationToken", time.Now())
+
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.Set(ctx, rediskeys.EmailVerificationTokenKey(token.Token), value, time.Until(token.ExpiresAt))
+}
+
+// ConsumeEmailVerificationToken atomically fetches and deletes the token. It
+// returns nil if the token is unknown or expired.
+func (r *TokenRepository) ConsumeEmailVerificationToken(ctx context.Context, token string) (*auth.EmailVerificationToken, error) {
+	defer metrics.Storage.Observe("redis", "token", "ConsumeEmailVerificationToken", time.Now())
+
+	value, err := r.redis.GetDel(ctx, rediskeys.EmailVerificationTokenKey(token))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t auth.EmailVerificationToken
+	if err = json.Unmarshal([]byte(value), &t); err != nil {
+		return nil, err
+	}
+	t.Token = token
+
+	return &t, nil
+}
+
+func (r *TokenRepository) SaveMFAChallenge(ctx context.Context, challenge *auth.MFAChallenge) error {
+	defer metrics.Storage.Observe("redis", "token", "SaveMFAChallenge", time.Now())
+
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.Set(ctx, rediskeys.MFAChallengeKey(challenge.Token), value, time.Until(challenge.ExpiresAt))
+}
+
+func (r *TokenRepository) FindMFAChallenge(ctx context.Context, token string) (*auth.MFAChallenge, error) {
+	defer metrics.Storage.Observe("redis", "token", "FindMFAChallenge", time.Now())
+
+	value, err := r.redis.Get(ctx, rediskeys.MFAChallengeKey(token))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c auth.MFAChallenge
+	if err = json.Unmarshal([]byte(value), &c); err != nil {
+		return nil, err
+	}
+	c.Token = token
+
+	return &c, nil
+}
+
+// UpdateMFAChallenge saves the challenge without extending its lifetime.
+func (r *TokenRepository) UpdateMFAChallenge(ctx context.Context, challenge *auth.MFAChallenge) error {
+	defer metrics.Storage.Observe("redis", "token", "UpdateMFAChallenge", time.Now())
+
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.SetKeepTTL(ctx, rediskeys.MFAChallengeKey(challenge.Token), value)
+}
+
+// DeleteMFAChallenge returns false if the challenge was already gone, which
+// means a concurrent request has used it.
+func (r *TokenRepository) DeleteMFAChallenge(ctx context.Context, token string) (bool, error) {
+	defer metrics.Storage.Observe("redis", "token", "DeleteMFAChallenge", time.Now())
+
+	deleted, err := r.redis.DelCount(ctx, rediskeys.MFAChallengeKey(token))
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
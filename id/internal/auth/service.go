@@ -2,10 +2,16 @@ package auth
 
 import (
 	"context"
+
+	"github.com/maximegorov13/chat-app/id/internal/session"
 )
 
 type AuthService interface {
-	Login(ctx context.Context, req *LoginRequest) (string, error)
+	Login(ctx context.Context, req *LoginRequest, device session.Device) (*LoginResult, error)
+	LoginMFA(ctx context.Context, req *LoginMFARequest) (*TokenPair, error)
+	Refresh(ctx context.Context, req *RefreshRequest) (*TokenPair, error)
 	Logout(ctx context.Context, token string) error
 	IsTokenInvalid(ctx context.Context, token string) (bool, error)
+	RequestPasswordReset(ctx context.Context, req *PasswordResetRequest) error
+	ConfirmPasswordReset(ctx context.Context, req *PasswordResetConfirmRequest) error
 }
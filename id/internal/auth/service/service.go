@@ -2,63 +2,453 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/maximegorov13/chat-app/id/pkg/jwt"
 
+	"github.com/maximegorov13/chat-app/id/configs"
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	"github.com/maximegorov13/chat-app/id/internal/session"
 	"github.com/maximegorov13/chat-app/id/internal/user"
 )
 
+// maxMFAAttempts is how many wrong codes are accepted per MFA challenge.
+const maxMFAAttempts = 5
+
 type AuthServiceDeps struct {
-	UserRepo  user.UserRepository
-	TokenRepo auth.TokenRepository
-	JWT       *jwt.JWT
+	Conf             *configs.Config
+	UserRepo         user.UserRepository
+	TokenRepo        auth.TokenRepository
+	LoginAttemptRepo auth.LoginAttemptRepository
+	SessionService   session.SessionService
+	MFAService       mfa.MFAService
+	Mailer           mailer.Mailer
+	JWT              *jwt.JWT
 }
 
 type AuthService struct {
-	userRepo  user.UserRepository
-	tokenRepo auth.TokenRepository
-	jwt       *jwt.JWT
+	conf             *configs.Config
+	userRepo         user.UserRepository
+	tokenRepo        auth.TokenRepository
+	loginAttemptRepo auth.LoginAttemptRepository
+	sessionService   session.SessionService
+	mfaService       mfa.MFAService
+	mailer           mailer.Mailer
+	jwt              *jwt.JWT
 }
 
 func NewAuthService(deps AuthServiceDeps) *AuthService {
 	return &AuthService{
-		userRepo:  deps.UserRepo,
-		tokenRepo: deps.TokenRepo,
-		jwt:       deps.JWT,
+		conf:             deps.Conf,
+		userRepo:         deps.UserRepo,
+		tokenRepo:        deps.TokenRepo,
+		loginAttemptRepo: deps.LoginAttemptRepo,
+		sessionService:   deps.SessionService,
+		mfaService:       deps.MFAService,
+		mailer:           deps.Mailer,
+		jwt:              deps.JWT,
 	}
 }
 
-func (s *AuthService) Login(ctx context.Context, req *auth.LoginRequest) (string, error) {
+// Login checks the credentials and issues tokens for a new session. For
+// accounts with MFA enabled it only returns a challenge token, which LoginMFA
+// exchanges for tokens along with a valid code.
+//
+// Failed attempts are counted per login and per client IP, see
+// configs.LoginThrottleConfig. Throttled attempts fail with a RetryError
+// before the password is checked.
+func (s *AuthService) Login(ctx context.Context, req *auth.LoginRequest, device session.Device) (*auth.LoginResult, error) {
+	now := time.Now()
+	scopes := s.throttleScopes(req.Login, device.IP)
+	if err := s.checkThrottle(ctx, scopes, now); err != nil {
+		if errors.Is(err, apperrors.ErrTooManyLoginAttempts) {
+			metrics.Logins.WithLabelValues(metrics.LoginThrottled).Inc()
+		}
+		return nil, err
+	}
+
 	existedUser, err := s.userRepo.FindByLogin(ctx, req.Login)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if existedUser == nil {
-		return "", apperrors.ErrInvalidCredentials
+		metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		if err = s.recordFailure(ctx, scopes, now); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.ErrInvalidCredentials
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(existedUser.Password), []byte(req.Password))
 	if err != nil {
-		return "", apperrors.ErrInvalidCredentials
+		metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		if err = s.recordFailure(ctx, scopes, now); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.ErrInvalidCredentials
+	}
+	if err = checkStatus(existedUser); err != nil {
+		return nil, err
+	}
+
+	mfaEnabled, err := s.mfaService.IsEnabled(ctx, existedUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	// With MFA the failures are only reset once the second factor succeeds
+	if mfaEnabled {
+		token, err := auth.GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.tokenRepo.SaveMFAChallenge(ctx, &auth.MFAChallenge{
+			Token:     token,
+			UserID:    existedUser.ID,
+			Login:     req.Login,
+			UserAgent: device.UserAgent,
+			IP:        device.IP,
+			ExpiresAt: time.Now().Add(s.conf.Auth.MFAChallengeTTL),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &auth.LoginResult{
+			MFAToken: token,
+		}, nil
+	}
+
+	if err = s.resetThrottle(ctx, scopes); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.login(ctx, existedUser, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.LoginResult{
+		TokenPair: *tokens,
+	}, nil
+}
+
+// LoginMFA completes a login of an account with MFA. The challenge is dropped
+// after maxMFAAttempts wrong codes, and wrong codes count as failed logins of
+// the account, so codes cannot be brute-forced with new challenges either.
+func (s *AuthService) LoginMFA(ctx context.Context, req *auth.LoginMFARequest) (*auth.TokenPair, error) {
+	challenge, err := s.tokenRepo.FindMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, apperrors.ErrInvalidMFAToken
+	}
+
+	now := time.Now()
+	scopes := s.mfaThrottleScopes(challenge.Login)
+	if err = s.checkThrottle(ctx, scopes, now); err != nil {
+		if errors.Is(err, apperrors.ErrTooManyLoginAttempts) {
+			metrics.Logins.WithLabelValues(metrics.LoginThrottled).Inc()
+		}
+		return nil, err
+	}
+
+	ok, err := s.mfaService.VerifyCode(ctx, challenge.UserID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		if err = s.recordFailure(ctx, scopes, now); err != nil {
+			return nil, err
+		}
+
+		challenge.Attempts++
+		if challenge.Attempts >= maxMFAAttempts {
+			_, err = s.tokenRepo.DeleteMFAChallenge(ctx, challenge.Token)
+		} else {
+			err = s.tokenRepo.UpdateMFAChallenge(ctx, challenge)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, apperrors.ErrInvalidMFACode
+	}
+
+	deleted, err := s.tokenRepo.DeleteMFAChallenge(ctx, challenge.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, apperrors.ErrInvalidMFAToken
+	}
+
+	existedUser, err := s.userRepo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if existedUser == nil {
+		return nil, apperrors.ErrInvalidMFAToken
+	}
+	if err = checkStatus(existedUser); err != nil {
+		return nil, err
+	}
+	if err = s.resetThrottle(ctx, scopes); err != nil {
+		return nil, err
+	}
+
+	return s.login(ctx, existedUser, session.Device{
+		UserAgent: challenge.UserAgent,
+		IP:        challenge.IP,
+	})
+}
+
+// login creates a session for the authenticated user and issues its tokens.
+func (s *AuthService) login(ctx context.Context, u *user.User, device session.Device) (*auth.TokenPair, error) {
+	sess, err := s.sessionService.CreateSession(ctx, u.ID, device)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(ctx, u, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Logins.WithLabelValues(metrics.LoginSucceeded).Inc()
+
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used only once: presenting an already rotated token is treated as
+// theft and revokes the whole token family together with its session.
+func (s *AuthService) Refresh(ctx context.Context, req *auth.RefreshRequest) (*auth.TokenPair, error) {
+	refreshToken, err := s.tokenRepo.FindRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken == nil {
+		return nil, apperrors.ErrInvalidRefreshToken
+	}
+
+	revoked, err := s.tokenRepo.IsRefreshTokenFamilyRevoked(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, apperrors.ErrInvalidRefreshToken
+	}
+
+	firstUse, err := s.tokenRepo.MarkRefreshTokenUsed(ctx, refreshToken.Token, s.conf.Auth.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !firstUse {
+		err = s.sessionService.RevokeSession(ctx, refreshToken.UserID, refreshToken.FamilyID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			err = s.tokenRepo.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID, s.conf.Auth.RefreshTokenTTL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, apperrors.ErrInvalidRefreshToken
+	}
+
+	sess, err := s.sessionService.RefreshSession(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, apperrors.ErrInvalidRefreshToken
+	}
+
+	existedUser, err := s.userRepo.FindByID(ctx, refreshToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if existedUser == nil || existedUser.Status != user.StatusActive {
+		return nil, apperrors.ErrInvalidRefreshToken
 	}
 
-	token, err := s.jwt.GenerateToken(existedUser.ID, existedUser.Login, existedUser.Name, time.Hour)
+	tokens, err := s.issueTokens(ctx, existedUser, refreshToken.FamilyID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	metrics.TokensRefreshed.Inc()
 
-	return token, nil
+	return tokens, nil
 }
 
 func (s *AuthService) Logout(ctx context.Context, token string) error {
-	return s.tokenRepo.InvalidateToken(ctx, token, time.Hour)
+	if err := s.tokenRepo.InvalidateToken(ctx, token, s.conf.Auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	metrics.Logouts.Inc()
+
+	claims, err := s.jwt.ValidateToken(token)
+	if err != nil || claims.SessionID == "" {
+		return nil
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	err = s.sessionService.RevokeSession(ctx, userID, claims.SessionID)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		return err
+	}
+
+	return nil
 }
 
+// IsTokenInvalid reports whether the token was logged out or its session has
+// been revoked.
 func (s *AuthService) IsTokenInvalid(ctx context.Context, token string) (bool, error) {
-	return s.tokenRepo.IsTokenInvalid(ctx, token)
+	invalid, err := s.tokenRepo.IsTokenInvalid(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	if invalid {
+		return true, nil
+	}
+
+	claims, err := s.jwt.ValidateToken(token)
+	if err != nil {
+		return true, nil
+	}
+
+	sess, err := s.sessionService.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		return false, err
+	}
+
+	return sess == nil, nil
+}
+
+// RequestPasswordReset mails a password reset link to the owner of the email.
+// Unknown and unverified emails are silently ignored, so the endpoint cannot
+// be used to find out which emails are registered, nor to take over an
+// account through an email nobody proved to own.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, req *auth.PasswordResetRequest) error {
+	existedUser, err := s.userRepo.FindByEmail(ctx, user.NormalizeEmail(req.Email))
+	if err != nil {
+		return err
+	}
+	if existedUser == nil || existedUser.Status == user.StatusDeleted || existedUser.EmailVerifiedAt == nil {
+		return nil
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepo.SavePasswordResetToken(ctx, &auth.PasswordResetToken{
+		Token:     token,
+		UserID:    existedUser.ID,
+		ExpiresAt: time.Now().Add(s.conf.Auth.PasswordResetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/password-reset?token=%s", strings.TrimSuffix(s.conf.Mail.AppURL, "/"), url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      existedUser.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hi %s,\n\nFollow the link to set a new password:\n%s\n\n"+
+			"The link expires in %s. If you did not request a password reset, ignore this email.",
+			existedUser.Name, link, s.conf.Auth.PasswordResetTokenTTL),
+	})
+}
+
+// ConfirmPasswordReset sets a new password using a reset token. The token is
+// consumed even if the reset fails, and every session of the user is revoked.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, req *auth.PasswordResetConfirmRequest) error {
+	resetToken, err := s.tokenRepo.ConsumePasswordResetToken(ctx, req.Token)
+	if err != nil {
+		return err
+	}
+	if resetToken == nil {
+		return apperrors.ErrInvalidResetToken
+	}
+
+	existedUser, err := s.userRepo.FindByID(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+	if existedUser == nil || existedUser.Status == user.StatusDeleted {
+		return apperrors.ErrInvalidResetToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	existedUser.Password = string(hashedPassword)
+
+	if err = s.userRepo.Update(ctx, existedUser); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeOtherSessions(ctx, existedUser.ID, "")
+}
+
+// checkStatus refuses logins to accounts that are not active, telling
+// unverified and suspended accounts apart so clients can explain why.
+func checkStatus(u *user.User) error {
+	switch u.Status {
+	case user.StatusActive:
+		return nil
+	case user.StatusPending:
+		return apperrors.ErrEmailNotVerified
+	case user.StatusSuspended:
+		return apperrors.ErrAccountSuspended
+	default:
+		return apperrors.ErrInvalidCredentials
+	}
+}
+
+// issueTokens creates an access token bound to the session and a refresh token
+// in the session's token family. The session ID doubles as the family ID.
+func (s *AuthService) issueTokens(ctx context.Context, u *user.User, familyID string) (*auth.TokenPair, error) {
+	accessToken, err := s.jwt.GenerateToken(u.ID, familyID, u.Login, u.Name, s.conf.Auth.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tokenRepo.SaveRefreshToken(ctx, &auth.RefreshToken{
+		Token:     refreshToken,
+		UserID:    u.ID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.conf.Auth.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
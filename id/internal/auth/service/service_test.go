@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maximegorov13/chat-app/id/pkg/jwt"
@@ -18,7 +23,14 @@ import (
 	authredis "github.com/maximegorov13/chat-app/id/internal/auth/repository/redis"
 	authservice "github.com/maximegorov13/chat-app/id/internal/auth/service"
 	"github.com/maximegorov13/chat-app/id/internal/keyreader"
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	mfapg "github.com/maximegorov13/chat-app/id/internal/mfa/repository/pg"
+	mfaservice "github.com/maximegorov13/chat-app/id/internal/mfa/service"
 	"github.com/maximegorov13/chat-app/id/internal/rediskeys"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	sessionredis "github.com/maximegorov13/chat-app/id/internal/session/repository/redis"
+	sessionservice "github.com/maximegorov13/chat-app/id/internal/session/service"
 	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
 	"github.com/maximegorov13/chat-app/id/internal/storage/redis"
 	"github.com/maximegorov13/chat-app/id/internal/user"
@@ -27,17 +39,63 @@ import (
 )
 
 type testDependencies struct {
+	conf                *configs.Config
 	authService         auth.AuthService
 	userService         user.UserService
+	sessionService      session.SessionService
+	mfaService          mfa.MFAService
 	tokenRepo           auth.TokenRepository
+	loginAttemptRepo    auth.LoginAttemptRepository
+	mailer              *mailerSpy
 	cleanupUser         func(userID int64)
+	setUserStatus       func(t *testing.T, userID int64, status user.Status)
+	setEmailVerified    func(t *testing.T, userID int64)
 	cleanupInvalidToken func(token string)
+	cleanupRefreshToken func(token string)
+	cleanupLoginScope   func(scope string)
+}
+
+// mailerSpy records sent emails instead of delivering them.
+type mailerSpy struct {
+	mu       sync.Mutex
+	messages []*mailer.Message
+}
+
+func (m *mailerSpy) Send(_ context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// lastTo returns the last email sent to the address.
+func (m *mailerSpy) lastTo(to string) *mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i]
+		}
+	}
+
+	return nil
+}
+
+var testDevice = session.Device{
+	UserAgent: "test-agent",
+	IP:        "127.0.0.1",
 }
 
 func getUniqueLogin() string {
 	return fmt.Sprintf("user-%s", uuid.New())
 }
 
+func getUniqueEmail() string {
+	return fmt.Sprintf("user-%s@example.com", uuid.New())
+}
+
 func setupTest(t testing.TB) *testDependencies {
 	t.Helper()
 
@@ -62,10 +120,20 @@ func setupTest(t testing.TB) *testDependencies {
 		log.Fatal(err)
 	}
 
-	jwtMaker := jwt.NewJWT(privateKey, publicKey)
+	jwtMaker, err := jwt.NewJWT(privateKey, publicKey)
+	require.NoError(t, err)
 
 	userRepo := userpg.NewUserRepository(pgClient)
 	tokenRepo := authredis.NewTokenRepository(redisClient)
+	sessionRepo := sessionredis.NewSessionRepository(redisClient)
+	mfaRepo := mfapg.NewMFARepository(pgClient)
+	loginAttemptRepo := authredis.NewLoginAttemptRepository(redisClient)
+
+	sessionService := sessionservice.NewSessionService(sessionservice.SessionServiceDeps{
+		Conf:        conf,
+		SessionRepo: sessionRepo,
+		TokenRepo:   tokenRepo,
+	})
 
 	cleanupUser := func(userID int64) {
 		query, args, err := pgClient.Sb.
@@ -84,6 +152,38 @@ func setupTest(t testing.TB) *testDependencies {
 		}
 	}
 
+	setUserStatus := func(t *testing.T, userID int64, status user.Status) {
+		t.Helper()
+
+		query, args, err := pgClient.Sb.
+			Update("users").
+			Set("status", status).
+			Where(squirrel.Eq{
+				"id": userID,
+			}).
+			ToSql()
+		require.NoError(t, err)
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		require.NoError(t, err)
+	}
+
+	setEmailVerified := func(t *testing.T, userID int64) {
+		t.Helper()
+
+		query, args, err := pgClient.Sb.
+			Update("users").
+			Set("email_verified_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+			Where(squirrel.Eq{
+				"id": userID,
+			}).
+			ToSql()
+		require.NoError(t, err)
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		require.NoError(t, err)
+	}
+
 	cleanupInvalidToken := func(token string) {
 		err := redisClient.Del(ctx, rediskeys.InvalidTokenKey(token))
 		if err != nil {
@@ -91,18 +191,62 @@ func setupTest(t testing.TB) *testDependencies {
 		}
 	}
 
+	cleanupRefreshToken := func(token string) {
+		err := redisClient.Del(ctx, rediskeys.RefreshTokenKey(token), rediskeys.UsedRefreshTokenKey(token))
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	cleanupLoginScope := func(scope string) {
+		err := redisClient.Del(ctx, rediskeys.LoginFailuresKey(scope), rediskeys.LoginLockoutKey(scope))
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	// Failed logins of all tests are counted for the same client IP.
+	t.Cleanup(func() {
+		cleanupLoginScope(auth.IPScope(testDevice.IP))
+	})
+
+	mfaService := mfaservice.NewMFAService(mfaservice.MFAServiceDeps{
+		Conf:     conf,
+		MFARepo:  mfaRepo,
+		UserRepo: userRepo,
+	})
+
+	mailSpy := &mailerSpy{}
+
 	return &testDependencies{
+		conf: conf,
 		authService: authservice.NewAuthService(authservice.AuthServiceDeps{
-			UserRepo:  userRepo,
-			TokenRepo: tokenRepo,
-			JWT:       jwtMaker,
+			Conf:             conf,
+			UserRepo:         userRepo,
+			TokenRepo:        tokenRepo,
+			LoginAttemptRepo: loginAttemptRepo,
+			SessionService:   sessionService,
+			MFAService:       mfaService,
+			Mailer:           mailSpy,
+			JWT:              jwtMaker,
 		}),
 		userService: userservice.NewUserService(userservice.UserServiceDeps{
-			UserRepo: userRepo,
+			Conf:      conf,
+			UserRepo:  userRepo,
+			TokenRepo: tokenRepo,
+			Mailer:    mailSpy,
 		}),
+		sessionService:      sessionService,
+		mfaService:          mfaService,
 		tokenRepo:           tokenRepo,
+		loginAttemptRepo:    loginAttemptRepo,
+		mailer:              mailSpy,
 		cleanupUser:         cleanupUser,
+		setUserStatus:       setUserStatus,
+		setEmailVerified:    setEmailVerified,
 		cleanupInvalidToken: cleanupInvalidToken,
+		cleanupRefreshToken: cleanupRefreshToken,
+		cleanupLoginScope:   cleanupLoginScope,
 	}
 }
 
@@ -124,15 +268,20 @@ func TestAuthService_Login(t *testing.T) {
 			deps.cleanupUser(registeredUser.ID)
 		})
 		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
 
 		loginReq := &auth.LoginRequest{
 			Login:    registerReq.Login,
 			Password: registerReq.Password,
 		}
 
-		token, err := deps.authService.Login(ctx, loginReq)
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
 		require.NoError(t, err)
-		require.NotEmpty(t, token)
+		require.NotEmpty(t, tokens.AccessToken)
+		require.NotEmpty(t, tokens.RefreshToken)
 	})
 
 	t.Run("invalid credentials wrong password", func(t *testing.T) {
@@ -148,13 +297,14 @@ func TestAuthService_Login(t *testing.T) {
 			deps.cleanupUser(registeredUser.ID)
 		})
 		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
 
 		loginReq := &auth.LoginRequest{
 			Login:    registerReq.Login,
 			Password: "wrongpassword",
 		}
 
-		_, err = deps.authService.Login(ctx, loginReq)
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
 		require.Error(t, err)
 		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
 	})
@@ -166,10 +316,511 @@ func TestAuthService_Login(t *testing.T) {
 			Password: "12345678",
 		}
 
-		_, err := deps.authService.Login(ctx, loginReq)
+		_, err := deps.authService.Login(ctx, loginReq, testDevice)
 		require.Error(t, err)
 		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
 	})
+
+	t.Run("inactive accounts", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.StatusPending, registeredUser.Status)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}
+
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrEmailNotVerified)
+
+		deps.setUserStatus(t, registeredUser.ID, user.StatusSuspended)
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrAccountSuspended)
+
+		deps.setUserStatus(t, registeredUser.ID, user.StatusDeleted)
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		loginReq.Password = "wrongpassword"
+		deps.setUserStatus(t, registeredUser.ID, user.StatusSuspended)
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	})
+}
+
+func TestAuthService_LoginMFA(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	registerReq := &user.RegisterRequest{
+		Login:    getUniqueLogin(),
+		Name:     "Test User",
+		Password: "12345678",
+	}
+
+	registeredUser, err := deps.userService.Register(ctx, registerReq)
+	t.Cleanup(func() {
+		deps.cleanupUser(registeredUser.ID)
+		deps.cleanupLoginScope(auth.LoginScope(registerReq.Login))
+	})
+	require.NoError(t, err)
+	deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+	enrollment, err := deps.mfaService.Enroll(ctx, registeredUser.ID)
+	require.NoError(t, err)
+
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+
+	recoveryCodes, err := deps.mfaService.Confirm(ctx, registeredUser.ID, &mfa.ConfirmRequest{
+		Code: code,
+	})
+	require.NoError(t, err)
+
+	loginReq := &auth.LoginRequest{
+		Login:    registerReq.Login,
+		Password: registerReq.Password,
+	}
+
+	t.Run("successful login with second factor", func(t *testing.T) {
+		result, err := deps.authService.Login(ctx, loginReq, testDevice)
+		require.NoError(t, err)
+		require.Empty(t, result.AccessToken)
+		require.Empty(t, result.RefreshToken)
+		require.NotEmpty(t, result.MFAToken)
+
+		tokens, err := deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     recoveryCodes[0],
+		})
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, tokens.AccessToken)
+		require.NotEmpty(t, tokens.RefreshToken)
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     recoveryCodes[1],
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFAToken)
+	})
+
+	t.Run("used recovery code", func(t *testing.T) {
+		result, err := deps.authService.Login(ctx, loginReq, testDevice)
+		require.NoError(t, err)
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     recoveryCodes[0],
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFACode)
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		result, err := deps.authService.Login(ctx, loginReq, testDevice)
+		require.NoError(t, err)
+
+		for range 5 {
+			_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+				MFAToken: result.MFAToken,
+				Code:     "000000",
+			})
+			require.ErrorIs(t, err, apperrors.ErrInvalidMFACode)
+		}
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     recoveryCodes[2],
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFAToken)
+	})
+
+	t.Run("invalid mfa token", func(t *testing.T) {
+		_, err := deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: "invalid",
+			Code:     recoveryCodes[3],
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFAToken)
+	})
+
+	t.Run("wrong codes lock the login", func(t *testing.T) {
+		scope := auth.LoginScope(registerReq.Login)
+		throttleConf := deps.conf.LoginThrottle
+		deps.cleanupLoginScope(scope)
+
+		result, err := deps.authService.Login(ctx, loginReq, testDevice)
+		require.NoError(t, err)
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     "000000",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFACode)
+
+		// The password alone does not reset the failures of the second factor
+		result, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.NoError(t, err)
+
+		failures, err := deps.loginAttemptRepo.FindFailures(ctx, scope, time.Now(), throttleConf.Window)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), failures.Count)
+
+		// Failures long enough ago that no delay applies anymore.
+		past := time.Now().Add(-throttleConf.MaxDelay)
+		for range throttleConf.LoginLockoutAfter - 2 {
+			_, err = deps.loginAttemptRepo.RecordFailure(ctx, scope, past, throttleConf.Window)
+			require.NoError(t, err)
+		}
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     "000000",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFACode)
+
+		_, err = deps.authService.LoginMFA(ctx, &auth.LoginMFARequest{
+			MFAToken: result.MFAToken,
+			Code:     recoveryCodes[3],
+		})
+		require.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+
+		_, err = deps.authService.Login(ctx, loginReq, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+	})
+}
+
+func TestAuthService_LoginThrottle(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+	throttleConf := deps.conf.LoginThrottle
+
+	register := func(t *testing.T) *user.RegisterRequest {
+		t.Helper()
+
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+			deps.cleanupLoginScope(auth.LoginScope(registerReq.Login))
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		return registerReq
+	}
+
+	newDevice := func(t *testing.T) session.Device {
+		device := session.Device{
+			UserAgent: "test-agent",
+			IP:        uuid.NewString(),
+		}
+		t.Cleanup(func() {
+			deps.cleanupLoginScope(auth.IPScope(device.IP))
+		})
+
+		return device
+	}
+
+	t.Run("progressive delay", func(t *testing.T) {
+		registerReq := register(t)
+		device := newDevice(t)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: "wrongpassword",
+		}
+
+		for range throttleConf.DelayAfter {
+			_, err := deps.authService.Login(ctx, loginReq, device)
+			require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+		}
+
+		loginReq.Password = registerReq.Password
+		_, err := deps.authService.Login(ctx, loginReq, device)
+		require.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+
+		var retryErr *apperrors.RetryError
+		require.ErrorAs(t, err, &retryErr)
+		require.Greater(t, retryErr.RetryAfter, time.Duration(0))
+		require.LessOrEqual(t, retryErr.RetryAfter, throttleConf.BaseDelay)
+
+		time.Sleep(retryErr.RetryAfter)
+
+		result, err := deps.authService.Login(ctx, loginReq, device)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(result.RefreshToken)
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, result.AccessToken)
+
+		// A successful login resets the counters.
+		loginReq.Password = "wrongpassword"
+		_, err = deps.authService.Login(ctx, loginReq, device)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	})
+
+	t.Run("login lockout", func(t *testing.T) {
+		registerReq := register(t)
+		device := newDevice(t)
+
+		// Failures long enough ago that no delay applies anymore.
+		past := time.Now().Add(-throttleConf.MaxDelay)
+		for range throttleConf.LoginLockoutAfter - 1 {
+			_, err := deps.loginAttemptRepo.RecordFailure(ctx, auth.LoginScope(registerReq.Login), past, throttleConf.Window)
+			require.NoError(t, err)
+		}
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: "wrongpassword",
+		}
+
+		_, err := deps.authService.Login(ctx, loginReq, device)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		loginReq.Password = registerReq.Password
+		_, err = deps.authService.Login(ctx, loginReq, newDevice(t))
+		require.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+
+		var retryErr *apperrors.RetryError
+		require.ErrorAs(t, err, &retryErr)
+		require.Greater(t, retryErr.RetryAfter, throttleConf.LockoutDuration-time.Minute)
+	})
+
+	t.Run("ip lockout", func(t *testing.T) {
+		registerReq := register(t)
+		device := newDevice(t)
+
+		err := deps.loginAttemptRepo.Lock(ctx, auth.IPScope(device.IP), time.Minute)
+		require.NoError(t, err)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}
+
+		_, err = deps.authService.Login(ctx, loginReq, device)
+		require.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+
+		result, err := deps.authService.Login(ctx, loginReq, newDevice(t))
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(result.RefreshToken)
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("success keeps ip failures", func(t *testing.T) {
+		registerReq := register(t)
+		device := newDevice(t)
+
+		_, err := deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    getUniqueLogin(),
+			Password: "wrongpassword",
+		}, device)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		result, err := deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}, device)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(result.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		failures, err := deps.loginAttemptRepo.FindFailures(ctx, auth.IPScope(device.IP), time.Now(), throttleConf.Window)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), failures.Count)
+	})
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful refresh rotates token", func(t *testing.T) {
+		uniqueLogin := getUniqueLogin()
+		registerReq := &user.RegisterRequest{
+			Login:    uniqueLogin,
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}
+
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		refreshed, err := deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: tokens.RefreshToken,
+		})
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(refreshed.RefreshToken)
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, refreshed.AccessToken)
+		require.NotEmpty(t, refreshed.RefreshToken)
+		require.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+	})
+
+	t.Run("reused token revokes family", func(t *testing.T) {
+		uniqueLogin := getUniqueLogin()
+		registerReq := &user.RegisterRequest{
+			Login:    uniqueLogin,
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}
+
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		refreshed, err := deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: tokens.RefreshToken,
+		})
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(refreshed.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: tokens.RefreshToken,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+
+		_, err = deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: refreshed.RefreshToken,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		uniqueLogin := getUniqueLogin()
+		registerReq := &user.RegisterRequest{
+			Login:    uniqueLogin,
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		loginReq := &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}
+
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		sessions, err := deps.sessionService.ListSessions(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+
+		err = deps.sessionService.RevokeSession(ctx, registeredUser.ID, sessions[0].ID)
+		require.NoError(t, err)
+
+		_, err = deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: tokens.RefreshToken,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+
+		invalid, err := deps.authService.IsTokenInvalid(ctx, tokens.AccessToken)
+		require.NoError(t, err)
+		require.True(t, invalid)
+	})
+
+	t.Run("suspended account", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		tokens, err := deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		deps.setUserStatus(t, registeredUser.ID, user.StatusSuspended)
+
+		_, err = deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: tokens.RefreshToken,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := deps.authService.Refresh(ctx, &auth.RefreshRequest{
+			RefreshToken: "unknown",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+	})
 }
 
 func TestAuthService_Logout(t *testing.T) {
@@ -190,23 +841,25 @@ func TestAuthService_Logout(t *testing.T) {
 			deps.cleanupUser(registeredUser.ID)
 		})
 		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
 
 		loginReq := &auth.LoginRequest{
 			Login:    registerReq.Login,
 			Password: registerReq.Password,
 		}
 
-		token, err := deps.authService.Login(ctx, loginReq)
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
 		t.Cleanup(func() {
-			deps.cleanupInvalidToken(token)
+			deps.cleanupInvalidToken(tokens.AccessToken)
+			deps.cleanupRefreshToken(tokens.RefreshToken)
 		})
 		require.NoError(t, err)
-		require.NotEmpty(t, token)
+		require.NotEmpty(t, tokens.AccessToken)
 
-		err = deps.authService.Logout(ctx, token)
+		err = deps.authService.Logout(ctx, tokens.AccessToken)
 		require.NoError(t, err)
 
-		invalid, err := deps.tokenRepo.IsTokenInvalid(ctx, token)
+		invalid, err := deps.tokenRepo.IsTokenInvalid(ctx, tokens.AccessToken)
 		require.NoError(t, err)
 		require.True(t, invalid)
 	})
@@ -230,22 +883,24 @@ func TestAuthService_IsTokenInvalid(t *testing.T) {
 			deps.cleanupUser(registeredUser.ID)
 		})
 		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
 
 		loginReq := &auth.LoginRequest{
 			Login:    registerReq.Login,
 			Password: registerReq.Password,
 		}
 
-		token, err := deps.authService.Login(ctx, loginReq)
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
 		t.Cleanup(func() {
-			deps.cleanupInvalidToken(token)
+			deps.cleanupInvalidToken(tokens.AccessToken)
+			deps.cleanupRefreshToken(tokens.RefreshToken)
 		})
 		require.NoError(t, err)
 
-		err = deps.authService.Logout(ctx, token)
+		err = deps.authService.Logout(ctx, tokens.AccessToken)
 		require.NoError(t, err)
 
-		invalid, err := deps.authService.IsTokenInvalid(ctx, token)
+		invalid, err := deps.authService.IsTokenInvalid(ctx, tokens.AccessToken)
 		require.NoError(t, err)
 		require.True(t, invalid)
 	})
@@ -263,20 +918,170 @@ func TestAuthService_IsTokenInvalid(t *testing.T) {
 			deps.cleanupUser(registeredUser.ID)
 		})
 		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
 
 		loginReq := &auth.LoginRequest{
 			Login:    registerReq.Login,
 			Password: registerReq.Password,
 		}
 
-		token, err := deps.authService.Login(ctx, loginReq)
+		tokens, err := deps.authService.Login(ctx, loginReq, testDevice)
 		t.Cleanup(func() {
-			deps.cleanupInvalidToken(token)
+			deps.cleanupInvalidToken(tokens.AccessToken)
+			deps.cleanupRefreshToken(tokens.RefreshToken)
 		})
 		require.NoError(t, err)
 
-		invalid, err := deps.authService.IsTokenInvalid(ctx, token)
+		invalid, err := deps.authService.IsTokenInvalid(ctx, tokens.AccessToken)
 		require.NoError(t, err)
 		require.False(t, invalid)
 	})
 }
+
+var resetTokenRegexp = regexp.MustCompile(`token=(\S+)`)
+
+func TestAuthService_PasswordReset(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	requestReset := func(t *testing.T, email string) string {
+		t.Helper()
+
+		err := deps.authService.RequestPasswordReset(ctx, &auth.PasswordResetRequest{
+			Email: email,
+		})
+		require.NoError(t, err)
+
+		msg := deps.mailer.lastTo(email)
+		require.NotNil(t, msg)
+
+		match := resetTokenRegexp.FindStringSubmatch(msg.Body)
+		require.Len(t, match, 2)
+
+		return match[1]
+	}
+
+	t.Run("successful reset", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    getUniqueEmail(),
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+		deps.setEmailVerified(t, registeredUser.ID)
+
+		tokens, err := deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(tokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		resetToken := requestReset(t, registerReq.Email)
+
+		err = deps.authService.ConfirmPasswordReset(ctx, &auth.PasswordResetConfirmRequest{
+			Token:       resetToken,
+			NewPassword: "newpassword123",
+		})
+		require.NoError(t, err)
+
+		_, err = deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: registerReq.Password,
+		}, testDevice)
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		newTokens, err := deps.authService.Login(ctx, &auth.LoginRequest{
+			Login:    registerReq.Login,
+			Password: "newpassword123",
+		}, testDevice)
+		t.Cleanup(func() {
+			deps.cleanupRefreshToken(newTokens.RefreshToken)
+		})
+		require.NoError(t, err)
+
+		invalid, err := deps.authService.IsTokenInvalid(ctx, tokens.AccessToken)
+		require.NoError(t, err)
+		require.True(t, invalid)
+
+		err = deps.authService.ConfirmPasswordReset(ctx, &auth.PasswordResetConfirmRequest{
+			Token:       resetToken,
+			NewPassword: "anotherpassword",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidResetToken)
+	})
+
+	t.Run("email is case insensitive", func(t *testing.T) {
+		email := getUniqueEmail()
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    email,
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+		deps.setEmailVerified(t, registeredUser.ID)
+
+		err = deps.authService.RequestPasswordReset(ctx, &auth.PasswordResetRequest{
+			Email: strings.ToUpper(email),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, deps.mailer.lastTo(email))
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		email := getUniqueEmail()
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    email,
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		deps.setUserStatus(t, registeredUser.ID, user.StatusActive)
+
+		err = deps.authService.RequestPasswordReset(ctx, &auth.PasswordResetRequest{
+			Email: email,
+		})
+		require.NoError(t, err)
+
+		msg := deps.mailer.lastTo(email)
+		require.NotNil(t, msg)
+		require.NotContains(t, msg.Body, "password-reset")
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		email := getUniqueEmail()
+
+		err := deps.authService.RequestPasswordReset(ctx, &auth.PasswordResetRequest{
+			Email: email,
+		})
+		require.NoError(t, err)
+		require.Nil(t, deps.mailer.lastTo(email))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		err := deps.authService.ConfirmPasswordReset(ctx, &auth.PasswordResetConfirmRequest{
+			Token:       "invalid-token",
+			NewPassword: "newpassword123",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidResetToken)
+	})
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+)
+
+// throttleScope is a scope failed logins are counted in. Progressive delays
+// only apply per login, since many users may share a client IP. Only login
+// scopes are reset by a successful login.
+type throttleScope struct {
+	name         string
+	delays       bool
+	resets       bool
+	lockoutAfter int
+}
+
+func (s *AuthService) throttleScopes(login, ip string) []throttleScope {
+	scopes := []throttleScope{
+		{
+			name:         auth.LoginScope(login),
+			delays:       true,
+			resets:       true,
+			lockoutAfter: s.conf.LoginThrottle.LoginLockoutAfter,
+		},
+	}
+	if ip != "" {
+		scopes = append(scopes, throttleScope{
+			name:         auth.IPScope(ip),
+			lockoutAfter: s.conf.LoginThrottle.IPLockoutAfter,
+		})
+	}
+
+	return scopes
+}
+
+// mfaThrottleScopes returns the scope wrong second factor codes are counted
+// in. They count against the login like wrong passwords, so new challenges do
+// not give new guesses, and lock it at the same threshold. Delays are left to
+// the password step.
+func (s *AuthService) mfaThrottleScopes(login string) []throttleScope {
+	return []throttleScope{
+		{
+			name:         auth.LoginScope(login),
+			resets:       true,
+			lockoutAfter: s.conf.LoginThrottle.LoginLockoutAfter,
+		},
+	}
+}
+
+// checkThrottle rejects the attempt if a scope is locked or has to wait
+// before the next attempt.
+func (s *AuthService) checkThrottle(ctx context.Context, scopes []throttleScope, now time.Time) error {
+	for _, scope := range scopes {
+		lockedFor, err := s.loginAttemptRepo.LockedFor(ctx, scope.name)
+		if err != nil {
+			return err
+		}
+		if lockedFor > 0 {
+			return apperrors.WithRetryAfter(apperrors.ErrTooManyLoginAttempts, lockedFor)
+		}
+
+		if !scope.delays {
+			continue
+		}
+
+		failures, err := s.loginAttemptRepo.FindFailures(ctx, scope.name, now, s.conf.LoginThrottle.Window)
+		if err != nil {
+			return err
+		}
+		if wait := failures.Last.Add(s.loginDelay(failures.Count)).Sub(now); wait > 0 {
+			return apperrors.WithRetryAfter(apperrors.ErrTooManyLoginAttempts, wait)
+		}
+	}
+
+	return nil
+}
+
+// loginDelay returns how long to wait after the last of failures failed
+// logins. It starts at BaseDelay and doubles with every failure up to
+// MaxDelay.
+func (s *AuthService) loginDelay(failures int64) time.Duration {
+	conf := s.conf.LoginThrottle
+
+	if failures < int64(conf.DelayAfter) {
+		return 0
+	}
+
+	delay := conf.BaseDelay
+	for i := int64(conf.DelayAfter); i < failures && delay < conf.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, conf.MaxDelay)
+}
+
+// recordFailure counts a failed login in every scope and locks the scopes
+// that reached their threshold.
+func (s *AuthService) recordFailure(ctx context.Context, scopes []throttleScope, now time.Time) error {
+	for _, scope := range scopes {
+		failures, err := s.loginAttemptRepo.RecordFailure(ctx, scope.name, now, s.conf.LoginThrottle.Window)
+		if err != nil {
+			return err
+		}
+
+		if failures.Count >= int64(scope.lockoutAfter) {
+			err = s.loginAttemptRepo.Lock(ctx, scope.name, s.conf.LoginThrottle.LockoutDuration)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resetThrottle clears the failures of the login scopes after a successful
+// login. Client IP scopes are left to expire, otherwise logins to an account
+// of their own would let an attacker keep clearing the counter of their IP
+// while guessing passwords of other accounts.
+func (s *AuthService) resetThrottle(ctx context.Context, scopes []throttleScope) error {
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope.resets {
+			names = append(names, scope.name)
+		}
+	}
+
+	return s.loginAttemptRepo.Reset(ctx, names...)
+}
@@ -0,0 +1,11 @@
+package auth
+
+// LoginScope is the scope failed logins to an account are counted in.
+func LoginScope(login string) string {
+	return "login:" + login
+}
+
+// IPScope is the scope failed logins from a client IP are counted in.
+func IPScope(ip string) string {
+	return "ip:" + ip
+}
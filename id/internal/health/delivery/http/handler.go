@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/maximegorov13/chat-app/id/internal/health"
+	"github.com/maximegorov13/chat-app/id/internal/res"
+)
+
+type HealthHandlerDeps struct {
+	Readiness *health.Readiness
+}
+
+type HealthHandler struct {
+	readiness *health.Readiness
+}
+
+func NewHealthHandler(router *http.ServeMux, deps HealthHandlerDeps) {
+	handler := &HealthHandler{
+		readiness: deps.Readiness,
+	}
+
+	router.Handle("GET /healthz", handler.Liveness())
+	router.Handle("GET /readyz", handler.Readiness())
+}
+
+// Liveness reports that the process serves requests. It does not check
+// dependencies, so an outage of Postgres or Redis does not restart the
+// service.
+func (h *HealthHandler) Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res.JSON(w, http.StatusOK, health.LivenessResponse{
+			Status: health.StatusOK,
+		}, nil)
+	}
+}
+
+func (h *HealthHandler) Readiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, ready := h.readiness.Check(r.Context())
+		if !ready {
+			res.JSON(w, http.StatusServiceUnavailable, resp, nil)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, resp, nil)
+	}
+}
@@ -0,0 +1,14 @@
+package health
+
+type LivenessResponse struct {
+	Status Status `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status Status                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+type DependencyStatus struct {
+	Status Status `json:"status"`
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK          Status = "ok"
+	StatusUnavailable Status = "unavailable"
+	StatusDraining    Status = "draining"
+)
+
+// Check reports whether a dependency the service needs to serve requests is
+// reachable.
+type Check struct {
+	Name string
+	Ping func(ctx context.Context) error
+}
+
+// Readiness runs the dependency checks for the readiness probe. Once Drain is
+// called it reports the service as not ready regardless of the checks, so
+// load balancers stop routing to it before the server shuts down.
+type Readiness struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewReadiness creates a Readiness running every check under timeout.
+func NewReadiness(timeout time.Duration, checks ...Check) *Readiness {
+	return &Readiness{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Check runs the checks concurrently and reports whether the service is
+// ready. Failed checks are logged, the response only carries their status.
+func (r *Readiness) Check(ctx context.Context) (*ReadinessResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	statuses := make([]Status, len(r.checks))
+	var wg sync.WaitGroup
+	for i, check := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			statuses[i] = StatusOK
+			if err := check.Ping(ctx); err != nil {
+				slog.WarnContext(ctx, "Readiness check failed", "dependency", check.Name, "error", err)
+				statuses[i] = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := &ReadinessResponse{
+		Status: StatusOK,
+		Checks: make(map[string]DependencyStatus, len(r.checks)),
+	}
+	for i, check := range r.checks {
+		resp.Checks[check.Name] = DependencyStatus{
+			Status: statuses[i],
+		}
+		if statuses[i] != StatusOK {
+			resp.Status = StatusUnavailable
+		}
+	}
+
+	if r.draining.Load() {
+		resp.Status = StatusDraining
+	}
+
+	return resp, resp.Status == StatusOK
+}
@@ -0,0 +1,62 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/health"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := health.Check{
+		Name: "postgres",
+		Ping: func(context.Context) error { return nil },
+	}
+	failing := health.Check{
+		Name: "redis",
+		Ping: func(context.Context) error { return errors.New("connection refused") },
+	}
+	hanging := health.Check{
+		Name: "redis",
+		Ping: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		resp, ready := health.NewReadiness(time.Second, ok).Check(context.Background())
+		require.True(t, ready)
+		require.Equal(t, health.StatusOK, resp.Status)
+		require.Equal(t, health.StatusOK, resp.Checks["postgres"].Status)
+	})
+
+	t.Run("dependency unavailable", func(t *testing.T) {
+		resp, ready := health.NewReadiness(time.Second, ok, failing).Check(context.Background())
+		require.False(t, ready)
+		require.Equal(t, health.StatusUnavailable, resp.Status)
+		require.Equal(t, health.StatusOK, resp.Checks["postgres"].Status)
+		require.Equal(t, health.StatusUnavailable, resp.Checks["redis"].Status)
+	})
+
+	t.Run("check timeout", func(t *testing.T) {
+		start := time.Now()
+		resp, ready := health.NewReadiness(50*time.Millisecond, ok, hanging).Check(context.Background())
+		require.False(t, ready)
+		require.Equal(t, health.StatusUnavailable, resp.Checks["redis"].Status)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		readiness := health.NewReadiness(time.Second, ok)
+		readiness.Drain()
+
+		resp, ready := readiness.Check(context.Background())
+		require.False(t, ready)
+		require.Equal(t, health.StatusDraining, resp.Status)
+	})
+}
@@ -1,9 +1,27 @@
 package keyreader
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+)
+
+const (
+	privateKeyFormat = "private_%s.pem"
+	publicKeyPrefix  = "public_"
+	publicKeySuffix  = ".pem"
+	// signingKeyFile optionally names the key used for signing, so the
+	// signing key can be switched without a restart.
+	signingKeyFile = "signing_key"
 )
 
 type KeyReader struct {
@@ -17,9 +35,119 @@ func NewKeyReader(basePath string) *KeyReader {
 }
 
 func (r *KeyReader) ReadPrivateKey(keyID string) ([]byte, error) {
-	return os.ReadFile(filepath.Join(r.BasePath, fmt.Sprintf("private_%s.pem", keyID)))
+	return os.ReadFile(filepath.Join(r.BasePath, fmt.Sprintf(privateKeyFormat, keyID)))
 }
 
 func (r *KeyReader) ReadPublicKey(keyID string) ([]byte, error) {
-	return os.ReadFile(filepath.Join(r.BasePath, fmt.Sprintf("public_%s.pem", keyID)))
+	return os.ReadFile(filepath.Join(r.BasePath, publicKeyPrefix+keyID+publicKeySuffix))
+}
+
+// ReadKeys reads every public_<kid>.pem in the base path together with its
+// private_<kid>.pem, if there is one.
+func (r *KeyReader) ReadKeys() ([]jwt.Key, error) {
+	paths, err := filepath.Glob(filepath.Join(r.BasePath, publicKeyPrefix+"*"+publicKeySuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwt.Key, 0, len(paths))
+	for _, path := range paths {
+		keyID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), publicKeyPrefix), publicKeySuffix)
+
+		publicKey, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, err := r.ReadPrivateKey(keyID)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		keys = append(keys, jwt.Key{
+			ID:         keyID,
+			PrivateKey: privateKey,
+			PublicKey:  publicKey,
+		})
+	}
+
+	return keys, nil
+}
+
+// ReadSigningKeyID returns the key named in the signing_key file, or
+// defaultKeyID if the file does not exist.
+func (r *KeyReader) ReadSigningKeyID(defaultKeyID string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.BasePath, signingKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultKeyID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ReadKeyRing reads all keys into a new key ring.
+func (r *KeyReader) ReadKeyRing(defaultSigningKeyID string) (*jwt.KeyRing, error) {
+	signingKeyID, keys, err := r.read(defaultSigningKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.NewKeyRing(signingKeyID, keys...)
+}
+
+// Reload replaces the keys of the ring with the keys currently on disk.
+func (r *KeyReader) Reload(ring *jwt.KeyRing, defaultSigningKeyID string) error {
+	signingKeyID, keys, err := r.read(defaultSigningKeyID)
+	if err != nil {
+		return err
+	}
+
+	return ring.Replace(signingKeyID, keys...)
+}
+
+// Watch reloads the ring on SIGHUP and, if interval is positive, on every
+// tick, until ctx is done. A failed reload keeps the current keys.
+func (r *KeyReader) Watch(ctx context.Context, ring *jwt.KeyRing, defaultSigningKeyID string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+		}
+
+		if err := r.Reload(ring, defaultSigningKeyID); err != nil {
+			slog.ErrorContext(ctx, "Error reloading keys", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Keys reloaded", "signing_key_id", ring.SigningKey().ID)
+	}
+}
+
+func (r *KeyReader) read(defaultSigningKeyID string) (string, []jwt.Key, error) {
+	signingKeyID, err := r.ReadSigningKeyID(defaultSigningKeyID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keys, err := r.ReadKeys()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return signingKeyID, keys, nil
 }
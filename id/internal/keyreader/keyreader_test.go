@@ -0,0 +1,59 @@
+package keyreader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/keyreader"
+)
+
+func writeFile(t testing.TB, dir, name, data string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+}
+
+func TestKeyReader_ReadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "private_a.pem", "private a")
+	writeFile(t, dir, "public_a.pem", "public a")
+	writeFile(t, dir, "public_b.pem", "public b")
+
+	r := keyreader.NewKeyReader(dir)
+
+	t.Run("reads all keys", func(t *testing.T) {
+		ring, err := r.ReadKeyRing("a")
+		require.NoError(t, err)
+
+		keys := ring.Keys()
+		require.Len(t, keys, 2)
+		require.Equal(t, "a", keys[0].ID)
+		require.Equal(t, []byte("private a"), keys[0].PrivateKey)
+		require.Equal(t, []byte("public a"), keys[0].PublicKey)
+		require.Equal(t, "b", keys[1].ID)
+		require.Empty(t, keys[1].PrivateKey)
+	})
+
+	t.Run("key without private key cannot sign", func(t *testing.T) {
+		_, err := r.ReadKeyRing("b")
+		require.Error(t, err)
+	})
+
+	t.Run("reload switches signing key", func(t *testing.T) {
+		ring, err := r.ReadKeyRing("a")
+		require.NoError(t, err)
+
+		writeFile(t, dir, "private_b.pem", "private b")
+		writeFile(t, dir, "signing_key", "b\n")
+		t.Cleanup(func() {
+			os.Remove(filepath.Join(dir, "private_b.pem"))
+			os.Remove(filepath.Join(dir, "signing_key"))
+		})
+
+		require.NoError(t, r.Reload(ring, "a"))
+		require.Equal(t, "b", ring.SigningKey().ID)
+	})
+}
@@ -0,0 +1,35 @@
+// Package log provides a Mailer that writes emails to a file or stdout instead
+// of sending them. It is meant for local development and tests.
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+)
+
+type Mailer struct {
+	mu   sync.Mutex
+	from string
+	w    io.Writer
+}
+
+func NewMailer(from string, w io.Writer) *Mailer {
+	return &Mailer{
+		from: from,
+		w:    w,
+	}
+}
+
+func (m *Mailer) Send(_ context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "From: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n\n",
+		m.from, msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+	return err
+}
@@ -0,0 +1,30 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	logmailer "github.com/maximegorov13/chat-app/id/internal/mailer/log"
+)
+
+func TestMailer_Send(t *testing.T) {
+	var buf bytes.Buffer
+	m := logmailer.NewMailer("no-reply@example.com", &buf)
+
+	err := m.Send(context.Background(), &mailer.Message{
+		To:      "user@example.com",
+		Subject: "Password reset",
+		Body:    "Follow the link",
+	})
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "From: no-reply@example.com\n")
+	require.Contains(t, out, "To: user@example.com\n")
+	require.Contains(t, out, "Subject: Password reset\n")
+	require.Contains(t, out, "\n\nFollow the link\n")
+}
@@ -0,0 +1,30 @@
+package smtp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+)
+
+func TestMailer_build(t *testing.T) {
+	m := NewMailer(Config{
+		From: "no-reply@example.com",
+	})
+
+	data := string(m.build(&mailer.Message{
+		To:      "user@example.com",
+		Subject: "Сброс пароля",
+		Body:    "line 1\nline 2",
+	}))
+
+	headers, body, ok := strings.Cut(data, "\r\n\r\n")
+	require.True(t, ok)
+	require.Contains(t, headers, "From: no-reply@example.com\r\n")
+	require.Contains(t, headers, "To: user@example.com\r\n")
+	require.Contains(t, headers, "Subject: =?utf-8?q?")
+	require.Contains(t, headers, "Content-Type: text/plain; charset=utf-8")
+	require.Equal(t, "line 1\r\nline 2\r\n", body)
+}
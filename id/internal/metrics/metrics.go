@@ -0,0 +1,45 @@
+// Package metrics holds the Prometheus collectors of the ID service. They are
+// registered with the default registry, which is served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	pkgmetrics "github.com/maximegorov13/chat-app/id/pkg/metrics"
+)
+
+const namespace = "id"
+
+// Login results.
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+	LoginThrottled = "throttled"
+)
+
+var (
+	HTTP    = pkgmetrics.NewHTTPMetrics(prometheus.DefaultRegisterer, namespace)
+	Storage = pkgmetrics.NewStorageMetrics(prometheus.DefaultRegisterer, namespace)
+
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result. Logins with MFA succeed once the code is accepted.",
+	}, []string{"result"})
+	Registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Registered users.",
+	})
+	Logouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logouts_total",
+		Help:      "Logouts.",
+	})
+	TokensRefreshed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_refreshed_total",
+		Help:      "Refresh token rotations.",
+	})
+)
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
+	"github.com/maximegorov13/chat-app/id/internal/req"
+	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+)
+
+type MFAHandlerDeps struct {
+	Conf        *configs.Config
+	MFAService  mfa.MFAService
+	TokenRepo   auth.TokenRepository
+	SessionRepo session.SessionRepository
+	JWT         *jwt.JWT
+	RateLimiter ratelimit.Limiter
+}
+
+type MFAHandler struct {
+	conf       *configs.Config
+	mfaService mfa.MFAService
+}
+
+func NewMFAHandler(router *http.ServeMux, deps MFAHandlerDeps) {
+	handler := &MFAHandler{
+		conf:       deps.Conf,
+		mfaService: deps.MFAService,
+	}
+
+	authDeps := middleware.AuthDeps{
+		Conf:        deps.Conf,
+		TokenRepo:   deps.TokenRepo,
+		SessionRepo: deps.SessionRepo,
+		JWT:         deps.JWT,
+	}
+
+	authLimit := middleware.RateLimitDeps{
+		Limiter: deps.RateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "auth",
+			Limit: deps.Conf.RateLimit.Auth,
+		},
+	}
+
+	router.Handle("POST /api/users/{id}/mfa/enroll", middleware.Auth(middleware.CheckUserAccessByID(handler.Enroll()), authDeps))
+	router.Handle("POST /api/users/{id}/mfa/confirm", middleware.Auth(middleware.CheckUserAccessByID(middleware.RateLimit(handler.Confirm(), authLimit)), authDeps))
+	router.Handle("POST /api/users/{id}/mfa/disable", middleware.Auth(middleware.CheckUserAccessByID(middleware.RateLimit(handler.Disable(), authLimit)), authDeps))
+}
+
+func (h *MFAHandler) Enroll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		enrollment, err := h.mfaService.Enroll(r.Context(), userID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := mfa.EnrollResponse{
+			Secret: enrollment.Secret,
+			URI:    enrollment.URI,
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *MFAHandler) Confirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[mfa.ConfirmRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		codes, err := h.mfaService.Confirm(r.Context(), userID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := mfa.ConfirmResponse{
+			RecoveryCodes: codes,
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *MFAHandler) Disable() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[mfa.DisableRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		if err = h.mfaService.Disable(r.Context(), userID, &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
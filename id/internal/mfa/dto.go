@@ -0,0 +1,34 @@
+package mfa
+
+import "github.com/go-ozzo/ozzo-validation"
+
+type EnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type ConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+func (r ConfirmRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.Required),
+	)
+}
+
+type ConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // TOTP or recovery code
+}
+
+func (r DisableRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Password, validation.Required),
+		validation.Field(&r.Code, validation.Required),
+	)
+}
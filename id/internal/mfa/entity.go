@@ -0,0 +1,25 @@
+package mfa
+
+import "time"
+
+// MFA is the TOTP second factor of a user. It is pending until the user
+// confirms enrollment with a valid code.
+type MFA struct {
+	UserID    int64      `db:"user_id"`
+	Secret    string     `db:"secret"`
+	EnabledAt *time.Time `db:"enabled_at"`
+	// LastUsedStep is the TOTP time step of the last accepted code, so a code
+	// cannot be used twice.
+	LastUsedStep int64     `db:"last_used_step"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (m *MFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// Enrollment is a freshly generated, not yet confirmed TOTP secret.
+type Enrollment struct {
+	Secret string
+	URI    string // otpauth:// URI for authenticator apps
+}
@@ -0,0 +1,12 @@
+package mfa
+
+import "context"
+
+type MFARepository interface {
+	Save(ctx context.Context, mfa *MFA) error
+	FindByUserID(ctx context.Context, userID int64) (*MFA, error)
+	Delete(ctx context.Context, userID int64) error
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+}
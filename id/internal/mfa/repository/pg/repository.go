@@ -0,0 +1,209 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
+)
+
+type MFARepository struct {
+	db *pg.Postgres
+}
+
+func NewMFARepository(db *pg.Postgres) *MFARepository {
+	return &MFARepository{
+		db: db,
+	}
+}
+
+// Save creates or replaces the second factor of the user.
+func (r *MFARepository) Save(ctx context.Context, m *mfa.MFA) error {
+	defer metrics.Storage.Observe("postgres", "mfa", "Save", time.Now())
+
+	query, args, err := r.db.Sb.
+		Insert("user_mfa").
+		Columns("user_id", "secret", "enabled_at", "last_used_step").
+		Values(m.UserID, m.Secret, m.EnabledAt, m.LastUsedStep).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			enabled_at = EXCLUDED.enabled_at,
+			last_used_step = EXCLUDED.last_used_step
+		RETURNING created_at`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	return r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&m.CreatedAt)
+}
+
+func (r *MFARepository) FindByUserID(ctx context.Context, userID int64) (*mfa.MFA, error) {
+	defer metrics.Storage.Observe("postgres", "mfa", "FindByUserID", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("user_mfa").
+		Where(squirrel.Eq{
+			"user_id": userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var m mfa.MFA
+	if err = r.db.Sqlx.GetContext(ctx, &m, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Delete removes the second factor of the user together with its recovery
+// codes.
+func (r *MFARepository) Delete(ctx context.Context, userID int64) error {
+	defer metrics.Storage.Observe("postgres", "mfa", "Delete", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, table := range []string{"mfa_recovery_codes", "user_mfa"} {
+		query, args, err := r.db.Sb.
+			Delete(table).
+			Where(squirrel.Eq{
+				"user_id": userID,
+			}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseStep atomically records the TOTP time step of an accepted code. It
+// returns false if the step, or a later one, has already been used.
+func (r *MFARepository) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "mfa", "UseStep", time.Now())
+
+	query, args, err := r.db.Sb.
+		Update("user_mfa").
+		Set("last_used_step", step).
+		Where(squirrel.Eq{
+			"user_id": userID,
+		}).
+		Where(squirrel.Lt{
+			"last_used_step": step,
+		}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	return r.execAffected(ctx, query, args)
+}
+
+// ReplaceRecoveryCodes discards the existing recovery codes of the user and
+// stores the new ones.
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	defer metrics.Storage.Observe("postgres", "mfa", "ReplaceRecoveryCodes", time.Now())
+
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query, args, err := r.db.Sb.
+		Delete("mfa_recovery_codes").
+		Where(squirrel.Eq{
+			"user_id": userID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	if len(codeHashes) > 0 {
+		insert := r.db.Sb.
+			Insert("mfa_recovery_codes").
+			Columns("user_id", "code_hash")
+		for _, codeHash := range codeHashes {
+			insert = insert.Values(userID, codeHash)
+		}
+
+		query, args, err = insert.ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode atomically marks an unused recovery code as used. It
+// returns false if there is no such unused code.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "mfa", "UseRecoveryCode", time.Now())
+
+	query, args, err := r.db.Sb.
+		Update("mfa_recovery_codes").
+		Set("used_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Where(squirrel.Eq{
+			"user_id":   userID,
+			"code_hash": codeHash,
+			"used_at":   nil,
+		}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	return r.execAffected(ctx, query, args)
+}
+
+func (r *MFARepository) execAffected(ctx context.Context, query string, args []any) (bool, error) {
+	defer metrics.Storage.Observe("postgres", "mfa", "execAffected", time.Now())
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,11 @@
+package mfa
+
+import "context"
+
+type MFAService interface {
+	Enroll(ctx context.Context, userID int64) (*Enrollment, error)
+	Confirm(ctx context.Context, userID int64, req *ConfirmRequest) ([]string, error)
+	Disable(ctx context.Context, userID int64, req *DisableRequest) error
+	IsEnabled(ctx context.Context, userID int64) (bool, error)
+	VerifyCode(ctx context.Context, userID int64, code string) (bool, error)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	"github.com/maximegorov13/chat-app/id/internal/user"
+)
+
+const (
+	// totpPeriod is the lifetime of a TOTP code in seconds.
+	totpPeriod = 30
+	// totpSkew is how many periods before and after the current one are
+	// accepted to tolerate clock drift.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	// recoveryCodeBytes gives 16 base32 characters, 80 bits of entropy.
+	recoveryCodeBytes = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAServiceDeps struct {
+	Conf     *configs.Config
+	MFARepo  mfa.MFARepository
+	UserRepo user.UserRepository
+}
+
+type MFAService struct {
+	conf     *configs.Config
+	mfaRepo  mfa.MFARepository
+	userRepo user.UserRepository
+}
+
+func NewMFAService(deps MFAServiceDeps) *MFAService {
+	return &MFAService{
+		conf:     deps.Conf,
+		mfaRepo:  deps.MFARepo,
+		userRepo: deps.UserRepo,
+	}
+}
+
+// Enroll generates a new TOTP secret for the user. It replaces an earlier,
+// unconfirmed enrollment and takes effect once confirmed.
+func (s *MFAService) Enroll(ctx context.Context, userID int64) (*mfa.Enrollment, error) {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	existed, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existed != nil && existed.Enabled() {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.conf.Auth.MFAIssuer,
+		AccountName: u.Login,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfaRepo.Save(ctx, &mfa.MFA{
+		UserID: userID,
+		Secret: key.Secret(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mfa.Enrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+	}, nil
+}
+
+// Confirm enables the enrolled second factor after checking a code from the
+// authenticator app. It returns recovery codes, which are only shown once.
+func (s *MFAService) Confirm(ctx context.Context, userID int64, req *mfa.ConfirmRequest) ([]string, error) {
+	m, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, apperrors.ErrMFANotEnrolled
+	}
+	if m.Enabled() {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := s.matchTOTP(m.Secret, req.Code)
+	if !ok {
+		return nil, apperrors.ErrInvalidMFACode
+	}
+
+	now := time.Now()
+	m.EnabledAt = &now
+	m.LastUsedStep = step
+	if err = s.mfaRepo.Save(ctx, m); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns the second factor off. Both the password and a TOTP or
+// recovery code are required, so a stolen session alone is not enough.
+func (s *MFAService) Disable(ctx context.Context, userID int64, req *mfa.DisableRequest) error {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return apperrors.ErrNotFound
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password))
+	if err != nil {
+		return apperrors.ErrInvalidCredentials
+	}
+
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return apperrors.ErrMFANotEnabled
+	}
+
+	ok, err := s.VerifyCode(ctx, userID, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperrors.ErrInvalidMFACode
+	}
+
+	return s.mfaRepo.Delete(ctx, userID)
+}
+
+func (s *MFAService) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	m, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return m != nil && m.Enabled(), nil
+}
+
+// VerifyCode checks a TOTP code or a recovery code of a user with MFA
+// enabled. Every code is accepted only once.
+func (s *MFAService) VerifyCode(ctx context.Context, userID int64, code string) (bool, error) {
+	m, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if m == nil || !m.Enabled() {
+		return false, nil
+	}
+
+	if step, ok := s.matchTOTP(m.Secret, code); ok {
+		return s.mfaRepo.UseStep(ctx, userID, step)
+	}
+
+	return s.mfaRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+}
+
+// matchTOTP returns the time step the code is valid for.
+func (s *MFAService) matchTOTP(secret, code string) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, false
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// generateRecoveryCodes returns the codes to show to the user and their
+// hashes to store. Codes are random enough for a plain SHA-256 hash.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		code = code[:len(code)/2] + "-" + code[len(code)/2:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, so codes can be typed
+// loosely.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,189 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/mfa"
+	mfapg "github.com/maximegorov13/chat-app/id/internal/mfa/repository/pg"
+	mfaservice "github.com/maximegorov13/chat-app/id/internal/mfa/service"
+	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/id/internal/user"
+	userpg "github.com/maximegorov13/chat-app/id/internal/user/repository/pg"
+	userservice "github.com/maximegorov13/chat-app/id/internal/user/service"
+)
+
+type testDependencies struct {
+	mfaService  mfa.MFAService
+	userService user.UserService
+	cleanupUser func(userID int64)
+}
+
+func getUniqueLogin() string {
+	return fmt.Sprintf("user-%s", uuid.New())
+}
+
+func setupTest(t testing.TB) *testDependencies {
+	t.Helper()
+
+	conf, err := configs.Load("../../../.env")
+	require.NoError(t, err)
+
+	pgClient, err := pg.NewPostgres(conf)
+	require.NoError(t, err)
+
+	userRepo := userpg.NewUserRepository(pgClient)
+	mfaRepo := mfapg.NewMFARepository(pgClient)
+
+	cleanupUser := func(userID int64) {
+		query, args, err := pgClient.Sb.
+			Delete("users").
+			Where(squirrel.Eq{
+				"id": userID,
+			}).
+			ToSql()
+		if err != nil {
+			t.Logf("cleanup query build error: %v", err)
+		}
+
+		_, err = pgClient.Sqlx.ExecContext(context.Background(), query, args...)
+		if err != nil {
+			t.Logf("cleanup exec error: %v", err)
+		}
+	}
+
+	return &testDependencies{
+		mfaService: mfaservice.NewMFAService(mfaservice.MFAServiceDeps{
+			Conf:     conf,
+			MFARepo:  mfaRepo,
+			UserRepo: userRepo,
+		}),
+		userService: userservice.NewUserService(userservice.UserServiceDeps{
+			UserRepo: userRepo,
+		}),
+		cleanupUser: cleanupUser,
+	}
+}
+
+func TestMFAService(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("enroll, verify and disable", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		enrollment, err := deps.mfaService.Enroll(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, enrollment.Secret)
+		require.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+		require.Contains(t, enrollment.URI, registerReq.Login)
+
+		enabled, err := deps.mfaService.IsEnabled(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.False(t, enabled)
+
+		_, err = deps.mfaService.Confirm(ctx, registeredUser.ID, &mfa.ConfirmRequest{
+			Code: "000000",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidMFACode)
+
+		code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+		require.NoError(t, err)
+
+		recoveryCodes, err := deps.mfaService.Confirm(ctx, registeredUser.ID, &mfa.ConfirmRequest{
+			Code: code,
+		})
+		require.NoError(t, err)
+		require.Len(t, recoveryCodes, 10)
+
+		enabled, err = deps.mfaService.IsEnabled(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.True(t, enabled)
+
+		_, err = deps.mfaService.Enroll(ctx, registeredUser.ID)
+		require.ErrorIs(t, err, apperrors.ErrMFAAlreadyEnabled)
+
+		// A code is accepted only once.
+		ok, err := deps.mfaService.VerifyCode(ctx, registeredUser.ID, code)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		nextCode, err := totp.GenerateCode(enrollment.Secret, time.Now().Add(30*time.Second))
+		require.NoError(t, err)
+		ok, err = deps.mfaService.VerifyCode(ctx, registeredUser.ID, nextCode)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = deps.mfaService.VerifyCode(ctx, registeredUser.ID, strings.ToUpper(recoveryCodes[0]))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = deps.mfaService.VerifyCode(ctx, registeredUser.ID, recoveryCodes[0])
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		err = deps.mfaService.Disable(ctx, registeredUser.ID, &mfa.DisableRequest{
+			Password: "wrongpassword",
+			Code:     recoveryCodes[1],
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		err = deps.mfaService.Disable(ctx, registeredUser.ID, &mfa.DisableRequest{
+			Password: registerReq.Password,
+			Code:     recoveryCodes[1],
+		})
+		require.NoError(t, err)
+
+		enabled, err = deps.mfaService.IsEnabled(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.False(t, enabled)
+
+		ok, err = deps.mfaService.VerifyCode(ctx, registeredUser.ID, recoveryCodes[2])
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("confirm without enrollment", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.mfaService.Confirm(ctx, registeredUser.ID, &mfa.ConfirmRequest{
+			Code: "123456",
+		})
+		require.ErrorIs(t, err, apperrors.ErrMFANotEnrolled)
+
+		err = deps.mfaService.Disable(ctx, registeredUser.ID, &mfa.DisableRequest{
+			Password: "12345678",
+			Code:     "123456",
+		})
+		require.ErrorIs(t, err, apperrors.ErrMFANotEnabled)
+	})
+}
@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/maximegorov13/chat-app/id/pkg/jwt"
 
@@ -11,12 +13,18 @@ import (
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 	"github.com/maximegorov13/chat-app/id/internal/auth"
 	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/session"
 )
 
+// sessionTouchInterval limits how often an authenticated request refreshes the
+// session last-seen timestamp.
+const sessionTouchInterval = time.Minute
+
 type AuthDeps struct {
-	Conf      *configs.Config
-	TokenRepo auth.TokenRepository
-	JWT       *jwt.JWT
+	Conf        *configs.Config
+	TokenRepo   auth.TokenRepository
+	SessionRepo session.SessionRepository
+	JWT         *jwt.JWT
 }
 
 func Auth(next http.Handler, deps AuthDeps) http.Handler {
@@ -44,18 +52,32 @@ func Auth(next http.Handler, deps AuthDeps) http.Handler {
 			return
 		}
 
-		valid, claims := deps.JWT.ValidateToken(token)
-		if !valid {
+		claims, err := deps.JWT.ValidateToken(token)
+		if err != nil {
 			res.Error(w, apperrors.ErrUnauthorized)
 			return
 		}
 
-		if deps.JWT.IsTokenExpired(token) {
+		sess, err := deps.SessionRepo.FindByID(r.Context(), claims.SessionID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+		if sess == nil || strconv.FormatInt(sess.UserID, 10) != claims.Subject {
 			res.Error(w, apperrors.ErrUnauthorized)
 			return
 		}
 
+		if time.Since(sess.LastSeenAt) > sessionTouchInterval {
+			sess.LastSeenAt = time.Now()
+			if err = deps.SessionRepo.Update(r.Context(), sess); err != nil {
+				res.Error(w, err)
+				return
+			}
+		}
+
 		ctx := appcontext.SetContextUserID(r.Context(), claims.Subject)
+		ctx = appcontext.SetContextSessionID(ctx, claims.SessionID)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/maximegorov13/chat-app/id/internal/appcontext"
+	"github.com/maximegorov13/chat-app/id/internal/req"
+)
+
+type ClientIPDeps struct {
+	// TrustedProxies lists the addresses of the proxies in front of the
+	// service as IPs or CIDR ranges, e.g. "10.0.0.0/8".
+	TrustedProxies []string
+}
+
+// ClientIP resolves the client IP returned by req.ClientIP. X-Forwarded-For is
+// only read for requests from trusted proxies, otherwise clients could pick
+// their own IP and get around the rate limits. The header is read from the
+// right and the first address that is not a trusted proxy is the client, the
+// entries to the left of it are set by the client.
+func ClientIP(next http.Handler, deps ClientIPDeps) http.Handler {
+	trusted := parsePrefixes(deps.TrustedProxies)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := req.RemoteIP(r)
+		if isTrusted(ip, trusted) {
+			ip = forwardedIP(ip, r.Header.Values("X-Forwarded-For"), trusted)
+		}
+
+		r = r.WithContext(appcontext.SetContextClientIP(r.Context(), ip))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP walks the X-Forwarded-For entries back from the proxy at
+// remoteIP. It stops at a malformed entry, which cannot be trusted further.
+func forwardedIP(remoteIP string, header []string, trusted []netip.Prefix) string {
+	var entries []string
+	for _, value := range header {
+		entries = append(entries, strings.Split(value, ",")...)
+	}
+
+	ip := remoteIP
+	for i := len(entries) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(entries[i]))
+		if err != nil {
+			break
+		}
+
+		ip = addr.Unmap().String()
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parsePrefixes skips invalid items, the config validates them.
+func parsePrefixes(items []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range items {
+		if prefix, err := parseProxy(item); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// parseProxy parses a trusted proxy given as an IP or a CIDR range.
+func parseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
+	"github.com/maximegorov13/chat-app/id/internal/req"
+)
+
+func TestClientIP(t *testing.T) {
+	var clientIP string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP = req.ClientIP(r)
+	})
+	handler := middleware.ClientIP(next, middleware.ClientIPDeps{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		wantIP       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer sets the header", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "192.168.1.1:5000", []string{"198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"spoofed entries left of the client", "10.0.0.2:5000", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"malformed entry", "10.0.0.2:5000", []string{"198.51.100.1, unknown"}, "10.0.0.2"},
+		{"trusted proxy without the header", "10.0.0.2:5000", nil, "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			require.Equal(t, tt.wantIP, clientIP)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/internal/res"
+)
+
+var (
+	corsAllowedMethods = strings.Join([]string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}, ", ")
+	corsAllowedHeaders = strings.Join([]string{
+		"Authorization",
+		"Content-Type",
+		res.RequestIDHeader,
+	}, ", ")
+	corsExposedHeaders = strings.Join([]string{
+		res.RequestIDHeader,
+		"Retry-After",
+		"RateLimit-Policy",
+		"RateLimit-Limit",
+		"RateLimit-Remaining",
+		"RateLimit-Reset",
+	}, ", ")
+)
+
+type CORSDeps struct {
+	// AllowedOrigins lists the origins allowed to call the API, e.g.
+	// "https://chat.example.com". An origin may use a wildcard for one
+	// subdomain level, e.g. "https://*.example.com", and "*" allows any.
+	AllowedOrigins []string
+	MaxAge         time.Duration // How long browsers cache preflight responses
+}
+
+// CORS lets the allowed browser origins call the API. Preflight requests are
+// answered here and do not reach next. Requests from other origins are
+// served without CORS headers, so browsers block the response.
+func CORS(next http.Handler, deps CORSDeps) http.Handler {
+	maxAge := strconv.FormatInt(int64(deps.MaxAge.Seconds()), 10)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowed := originAllowed(origin, deps.AllowedOrigins)
+
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if allowed {
+				h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				h.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func originAllowed(origin string, allowedOrigins []string) bool {
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		// "https://*.example.com" matches "https://chat.example.com"
+		prefix, suffix, ok := strings.Cut(allowed, "*.")
+		if !ok {
+			continue
+		}
+		if sub, ok := strings.CutPrefix(origin, prefix); ok {
+			if host, ok := strings.CutSuffix(sub, "."+suffix); ok && host != "" && !strings.ContainsAny(host, "./:") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.CORS(next, middleware.CORSDeps{
+		AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/users", nil)
+		r.Header.Set("Origin", origin)
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://localhost:3000", true},
+		{"https://chat.example.com", true},
+		{"https://example.com", false},
+		{"https://a.b.example.com", false},
+		{"https://evil.com/.example.com", false},
+		{"http://chat.example.com", false},
+		{"http://localhost:3001", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			w := serve(http.MethodGet, tt.origin, false)
+			require.Equal(t, http.StatusOK, w.Code)
+			if tt.allowed {
+				require.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		w := serve(http.MethodOptions, "http://localhost:3000", true)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("preflight from unknown origin", func(t *testing.T) {
+		w := serve(http.MethodOptions, "https://evil.com", true)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+}
@@ -0,0 +1,12 @@
+package middleware
+
+import "net/http"
+
+// MaxBytes limits request bodies to limit bytes. Reading past the limit
+// fails with *http.MaxBytesError, which req.HandleBody reports as 413.
+func MaxBytes(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
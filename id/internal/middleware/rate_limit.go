@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+
+	"github.com/maximegorov13/chat-app/id/internal/appcontext"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/req"
+	"github.com/maximegorov13/chat-app/id/internal/res"
+)
+
+type RateLimitDeps struct {
+	Limiter ratelimit.Limiter
+	Policy  ratelimit.Policy
+}
+
+// RateLimit limits requests per authenticated user, or per client IP for
+// anonymous requests, so it has to be wrapped by Auth to limit users.
+func RateLimit(next http.Handler, deps RateLimitDeps) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := "ip:" + req.ClientIP(r)
+		if userID, ok := appcontext.LookupContextUserID(r.Context()); ok {
+			subject = "user:" + userID
+		}
+
+		result, err := deps.Limiter.Allow(r.Context(), deps.Policy.Key(subject), deps.Policy.Limit)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		ratelimit.SetHeaders(w.Header(), deps.Policy, result)
+		if !result.Allowed {
+			res.Error(w, apperrors.WithRetryAfter(apperrors.ErrTooManyRequests, result.RetryAfter))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,11 +1,76 @@
 package rediskeys
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
 
 const (
-	invalidTokenFormat = "invalid_token:%s"
+	invalidTokenFormat              = "invalid_token:%s"
+	refreshTokenFormat              = "refresh_token:%s"
+	usedRefreshTokenFormat          = "used_refresh_token:%s"
+	revokedRefreshTokenFamilyFormat = "revoked_refresh_token_family:%s"
+	sessionFormat                   = "session:%s"
+	userSessionsFormat              = "user_sessions:%d"
+	passwordResetTokenFormat        = "password_reset_token:%s"
+	emailVerificationTokenFormat    = "email_verification_token:%s"
+	mfaChallengeFormat              = "mfa_challenge:%s"
+	loginFailuresFormat             = "login_failures:%s"
+	loginLockoutFormat              = "login_lockout:%s"
 )
 
 func InvalidTokenKey(token string) string {
 	return fmt.Sprintf(invalidTokenFormat, token)
 }
+
+// RefreshTokenKey stores refresh tokens by their SHA-256 hash, so a leaked
+// Redis dump does not leak usable tokens.
+func RefreshTokenKey(token string) string {
+	return fmt.Sprintf(refreshTokenFormat, hashToken(token))
+}
+
+func UsedRefreshTokenKey(token string) string {
+	return fmt.Sprintf(usedRefreshTokenFormat, hashToken(token))
+}
+
+func RevokedRefreshTokenFamilyKey(familyID string) string {
+	return fmt.Sprintf(revokedRefreshTokenFamilyFormat, familyID)
+}
+
+func SessionKey(sessionID string) string {
+	return fmt.Sprintf(sessionFormat, sessionID)
+}
+
+func UserSessionsKey(userID int64) string {
+	return fmt.Sprintf(userSessionsFormat, userID)
+}
+
+// PasswordResetTokenKey stores password reset tokens by their SHA-256 hash,
+// like refresh tokens.
+func PasswordResetTokenKey(token string) string {
+	return fmt.Sprintf(passwordResetTokenFormat, hashToken(token))
+}
+
+func EmailVerificationTokenKey(token string) string {
+	return fmt.Sprintf(emailVerificationTokenFormat, hashToken(token))
+}
+
+func MFAChallengeKey(token string) string {
+	return fmt.Sprintf(mfaChallengeFormat, hashToken(token))
+}
+
+// LoginFailuresKey holds the recent failed logins of a scope, such as a login
+// or a client IP, as a sorted set scored by time.
+func LoginFailuresKey(scope string) string {
+	return fmt.Sprintf(loginFailuresFormat, scope)
+}
+
+func LoginLockoutKey(scope string) string {
+	return fmt.Sprintf(loginLockoutFormat, scope)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+package req
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/maximegorov13/chat-app/id/internal/appcontext"
+)
+
+// ClientIP returns the IP address of the client that sent the request. It is
+// the address resolved by middleware.ClientIP, or the remote address of the
+// connection for requests that did not pass through it.
+func ClientIP(r *http.Request) string {
+	if ip, ok := appcontext.LookupContextClientIP(r.Context()); ok {
+		return ip
+	}
+
+	return RemoteIP(r)
+}
+
+// RemoteIP returns the IP address of the peer of the connection, which is a
+// proxy when the service runs behind one.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -2,14 +2,25 @@ package req
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 )
 
+var errTrailingData = errors.New("json: unexpected data after the request body")
+
+// Decode reads a single JSON value from body. Fields the request type does
+// not declare are rejected, so typos in field names do not pass unnoticed.
 func Decode[T Body](body io.ReadCloser) (Request[T], error) {
 	var payload Request[T]
-	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&payload); err != nil {
 		return payload, err
 	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return payload, errTrailingData
+	}
 
 	return payload, nil
 }
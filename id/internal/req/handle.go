@@ -1,7 +1,11 @@
 package req
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 )
@@ -9,7 +13,7 @@ import (
 func HandleBody[T Body](r *http.Request) (*Request[T], error) {
 	body, err := Decode[T](r.Body)
 	if err != nil {
-		return nil, apperrors.ErrInvalidRequestBody
+		return nil, decodeError(err)
 	}
 
 	if err = body.Data.Validate(); err != nil {
@@ -18,3 +22,26 @@ func HandleBody[T Body](r *http.Request) (*Request[T], error) {
 
 	return &body, nil
 }
+
+// decodeError converts a Decode error into an error for the client, naming
+// the offending field where the JSON decoder reports it.
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperrors.ErrRequestBodyTooLarge
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return apperrors.NewError(http.StatusBadRequest,
+			fmt.Sprintf("%s: %s must be of type %s", apperrors.ErrInvalidRequestBody.Message, typeErr.Field, typeErr.Type))
+	}
+
+	// encoding/json has no error type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return apperrors.NewError(http.StatusBadRequest,
+			fmt.Sprintf("%s: unknown field %s", apperrors.ErrInvalidRequestBody.Message, field))
+	}
+
+	return apperrors.ErrInvalidRequestBody
+}
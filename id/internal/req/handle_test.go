@@ -0,0 +1,66 @@
+package req_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/req"
+)
+
+type loginRequest struct {
+	Login string `json:"login"`
+	Count int    `json:"count"`
+}
+
+func (l loginRequest) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.Login, validation.Required),
+	)
+}
+
+func TestHandleBody(t *testing.T) {
+	handle := func(body string, maxBytes int64) (*req.Request[loginRequest], error) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		return req.HandleBody[loginRequest](r)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		body, err := handle(`{"data":{"login":"alice","count":1}}`, 1024)
+		require.NoError(t, err)
+		require.Equal(t, "alice", body.Data.Login)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := handle(`{"data":{"login":"alice","logn":"bob"}}`, 1024)
+		var appErr *apperrors.Error
+		require.True(t, errors.As(err, &appErr))
+		require.Equal(t, http.StatusBadRequest, appErr.Code)
+		require.Contains(t, appErr.Message, `unknown field "logn"`)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		_, err := handle(`{"data":{"login":"alice","count":"one"}}`, 1024)
+		var appErr *apperrors.Error
+		require.True(t, errors.As(err, &appErr))
+		require.Contains(t, appErr.Message, "data.count")
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		_, err := handle(`{"data":{"login":"alice"}}{}`, 1024)
+		require.ErrorIs(t, err, apperrors.ErrInvalidRequestBody)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := handle(`{"data":{"login":"`+strings.Repeat("a", 100)+`"}}`, 32)
+		require.ErrorIs(t, err, apperrors.ErrRequestBodyTooLarge)
+	})
+}
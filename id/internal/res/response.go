@@ -3,14 +3,21 @@ package res
 import (
 	"encoding/json"
 	"errors"
+	"log/slog"
+	"math"
 	"net/http"
 	"reflect"
+	"strconv"
 
 	"github.com/go-ozzo/ozzo-validation"
 
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 )
 
+// RequestIDHeader carries the request ID. It is set on the response by the
+// request ID middleware and echoed in ResponseMeta.
+const RequestIDHeader = "X-Request-ID"
+
 func JSON[T any](w http.ResponseWriter, statusCode int, data T, meta *ResponseMeta) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -18,6 +25,7 @@ func JSON[T any](w http.ResponseWriter, statusCode int, data T, meta *ResponseMe
 	if meta == nil {
 		meta = &ResponseMeta{}
 	}
+	meta.RequestID = w.Header().Get(RequestIDHeader)
 
 	if !reflect.ValueOf(data).IsValid() {
 		json.NewEncoder(w).Encode(Response[map[string]any]{
@@ -36,6 +44,13 @@ func JSON[T any](w http.ResponseWriter, statusCode int, data T, meta *ResponseMe
 func Error(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
 
+	var retryErr *apperrors.RetryError
+	if errors.As(err, &retryErr) {
+		// Retry-After is in whole seconds, round up so clients do not retry early.
+		seconds := int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
 	var appErr *apperrors.Error
 	if errors.As(err, &appErr) {
 		sendAppError(w, appErr)
@@ -48,13 +63,13 @@ func Error(w http.ResponseWriter, err error) {
 		return
 	}
 
-	sendDefaultError(w)
+	sendDefaultError(w, err)
 }
 
 func sendAppError(w http.ResponseWriter, err *apperrors.Error) {
 	w.WriteHeader(err.Code)
 	json.NewEncoder(w).Encode(Response[map[string]any]{
-		Meta: &ResponseMeta{},
+		Meta: newMeta(w),
 		Data: map[string]any{},
 		Error: &ErrorResponse{
 			Code:    err.Code,
@@ -75,7 +90,7 @@ func sendValidateError(w http.ResponseWriter, errs validation.Errors) {
 	}
 
 	json.NewEncoder(w).Encode(Response[map[string]any]{
-		Meta: &ResponseMeta{},
+		Meta: newMeta(w),
 		Data: map[string]any{},
 		Error: &ErrorResponse{
 			Code:    apperrors.ErrValidationFailed.Code,
@@ -85,10 +100,14 @@ func sendValidateError(w http.ResponseWriter, errs validation.Errors) {
 	})
 }
 
-func sendDefaultError(w http.ResponseWriter) {
+// sendDefaultError hides unexpected errors from clients, they are logged with
+// the request ID to find them by the ID in the response.
+func sendDefaultError(w http.ResponseWriter, err error) {
+	slog.Error("Internal server error", "request_id", w.Header().Get(RequestIDHeader), "error", err)
+
 	w.WriteHeader(apperrors.ErrInternalServerError.Code)
 	json.NewEncoder(w).Encode(Response[map[string]any]{
-		Meta: &ResponseMeta{},
+		Meta: newMeta(w),
 		Data: map[string]any{},
 		Error: &ErrorResponse{
 			Code:    apperrors.ErrInternalServerError.Code,
@@ -96,3 +115,9 @@ func sendDefaultError(w http.ResponseWriter) {
 		},
 	})
 }
+
+func newMeta(w http.ResponseWriter) *ResponseMeta {
+	return &ResponseMeta{
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+}
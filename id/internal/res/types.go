@@ -6,7 +6,9 @@ type Response[T any] struct {
 	Error *ErrorResponse `json:"error,omitempty"`
 }
 
-type ResponseMeta struct{}
+type ResponseMeta struct {
+	RequestID string `json:"request_id,omitempty"`
+}
 
 type ErrorResponse struct {
 	Code    int           `json:"code"`
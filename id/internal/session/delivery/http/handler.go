@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/appcontext"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/middleware"
+	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+)
+
+type SessionHandlerDeps struct {
+	Conf           *configs.Config
+	SessionService session.SessionService
+	SessionRepo    session.SessionRepository
+	TokenRepo      auth.TokenRepository
+	JWT            *jwt.JWT
+}
+
+type SessionHandler struct {
+	conf           *configs.Config
+	sessionService session.SessionService
+}
+
+func NewSessionHandler(router *http.ServeMux, deps SessionHandlerDeps) {
+	handler := &SessionHandler{
+		conf:           deps.Conf,
+		sessionService: deps.SessionService,
+	}
+
+	authDeps := middleware.AuthDeps{
+		Conf:        deps.Conf,
+		TokenRepo:   deps.TokenRepo,
+		SessionRepo: deps.SessionRepo,
+		JWT:         deps.JWT,
+	}
+
+	router.Handle("GET /api/users/{id}/sessions", middleware.Auth(middleware.CheckUserAccessByID(handler.ListSessions()), authDeps))
+	router.Handle("DELETE /api/users/{id}/sessions/others", middleware.Auth(middleware.CheckUserAccessByID(handler.RevokeOtherSessions()), authDeps))
+	router.Handle("DELETE /api/users/{id}/sessions/{sid}", middleware.Auth(middleware.CheckUserAccessByID(handler.RevokeSession()), authDeps))
+}
+
+func (h *SessionHandler) ListSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		sessions, err := h.sessionService.ListSessions(r.Context(), userID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		currentSessionID := appcontext.GetContextSessionID(r.Context())
+
+		data := make([]session.SessionResponse, 0, len(sessions))
+		for _, s := range sessions {
+			data = append(data, session.SessionResponse{
+				ID:         s.ID,
+				UserAgent:  s.UserAgent,
+				IP:         s.IP,
+				CreatedAt:  s.CreatedAt,
+				LastSeenAt: s.LastSeenAt,
+				Current:    s.ID == currentSessionID,
+			})
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *SessionHandler) RevokeSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		sessionID := r.PathValue("sid")
+		if sessionID == "" {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		if err = h.sessionService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
+
+func (h *SessionHandler) RevokeOtherSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		currentSessionID := appcontext.GetContextSessionID(r.Context())
+
+		if err = h.sessionService.RevokeOtherSessions(r.Context(), userID, currentSessionID); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
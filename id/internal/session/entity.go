@@ -0,0 +1,20 @@
+package session
+
+import "time"
+
+// Session is created on every login and lives as long as its refresh token
+// family: the session ID is the family ID and is embedded into access tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// Device describes the client a session is created for.
+type Device struct {
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,14 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+type SessionRepository interface {
+	Save(ctx context.Context, session *Session, expiration time.Duration) error
+	Update(ctx context.Context, session *Session) error
+	FindByID(ctx context.Context, id string) (*Session, error)
+	FindByUserID(ctx context.Context, userID int64) ([]*Session, error)
+	Delete(ctx context.Context, session *Session) error
+}
@@ -0,0 +1,119 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	"github.com/maximegorov13/chat-app/id/internal/rediskeys"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	storageredis "github.com/maximegorov13/chat-app/id/internal/storage/redis"
+)
+
+type SessionRepository struct {
+	redis *storageredis.Redis
+}
+
+func NewSessionRepository(redis *storageredis.Redis) *SessionRepository {
+	return &SessionRepository{
+		redis: redis,
+	}
+}
+
+// Save stores the session and (re)sets its expiration. The per-user index
+// always lives as long as the most recently saved session.
+func (r *SessionRepository) Save(ctx context.Context, session *session.Session, expiration time.Duration) error {
+	defer metrics.Storage.Observe("redis", "session", "Save", time.Now())
+
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if err = r.redis.Set(ctx, rediskeys.SessionKey(session.ID), value, expiration); err != nil {
+		return err
+	}
+
+	userSessionsKey := rediskeys.UserSessionsKey(session.UserID)
+	if err = r.redis.SAdd(ctx, userSessionsKey, session.ID); err != nil {
+		return err
+	}
+
+	return r.redis.Expire(ctx, userSessionsKey, expiration)
+}
+
+// Update overwrites the session data but keeps its current expiration.
+func (r *SessionRepository) Update(ctx context.Context, session *session.Session) error {
+	defer metrics.Storage.Observe("redis", "session", "Update", time.Now())
+
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return r.redis.SetKeepTTL(ctx, rediskeys.SessionKey(session.ID), value)
+}
+
+func (r *SessionRepository) FindByID(ctx context.Context, id string) (*session.Session, error) {
+	defer metrics.Storage.Observe("redis", "session", "FindByID", time.Now())
+
+	value, err := r.redis.Get(ctx, rediskeys.SessionKey(id))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s session.Session
+	if err = json.Unmarshal([]byte(value), &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// FindByUserID returns all live sessions of the user, pruning index entries
+// whose sessions have already expired.
+func (r *SessionRepository) FindByUserID(ctx context.Context, userID int64) ([]*session.Session, error) {
+	defer metrics.Storage.Observe("redis", "session", "FindByUserID", time.Now())
+
+	userSessionsKey := rediskeys.UserSessionsKey(userID)
+
+	ids, err := r.redis.SMembers(ctx, userSessionsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*session.Session, 0, len(ids))
+	for _, id := range ids {
+		s, err := r.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			if err = r.redis.SRem(ctx, userSessionsKey, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
+
+func (r *SessionRepository) Delete(ctx context.Context, session *session.Session) error {
+	defer metrics.Storage.Observe("redis", "session", "Delete", time.Now())
+
+	if err := r.redis.Del(ctx, rediskeys.SessionKey(session.ID)); err != nil {
+		return err
+	}
+
+	return r.redis.SRem(ctx, rediskeys.UserSessionsKey(session.UserID), session.ID)
+}
@@ -0,0 +1,12 @@
+package session
+
+import "context"
+
+type SessionService interface {
+	CreateSession(ctx context.Context, userID int64, device Device) (*Session, error)
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	RefreshSession(ctx context.Context, sessionID string) (*Session, error)
+	ListSessions(ctx context.Context, userID int64) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+)
+
+type SessionServiceDeps struct {
+	Conf        *configs.Config
+	SessionRepo session.SessionRepository
+	TokenRepo   auth.TokenRepository
+}
+
+type SessionService struct {
+	conf        *configs.Config
+	sessionRepo session.SessionRepository
+	tokenRepo   auth.TokenRepository
+}
+
+func NewSessionService(deps SessionServiceDeps) *SessionService {
+	return &SessionService{
+		conf:        deps.Conf,
+		sessionRepo: deps.SessionRepo,
+		tokenRepo:   deps.TokenRepo,
+	}
+}
+
+func (s *SessionService) CreateSession(ctx context.Context, userID int64, device session.Device) (*session.Session, error) {
+	now := time.Now()
+	sess := &session.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	if err := s.sessionRepo.Save(ctx, sess, s.conf.Auth.RefreshTokenTTL); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*session.Session, error) {
+	return s.sessionRepo.FindByID(ctx, sessionID)
+}
+
+// RefreshSession marks the session as seen and extends its lifetime to match
+// a freshly issued refresh token. It returns nil if the session is gone.
+func (s *SessionService) RefreshSession(ctx context.Context, sessionID string) (*session.Session, error) {
+	sess, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, nil
+	}
+
+	sess.LastSeenAt = time.Now()
+	if err = s.sessionRepo.Save(ctx, sess, s.conf.Auth.RefreshTokenTTL); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, userID int64) ([]*session.Session, error) {
+	sessions, err := s.sessionRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	sess, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil || sess.UserID != userID {
+		return apperrors.ErrNotFound
+	}
+
+	return s.revoke(ctx, sess)
+}
+
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error {
+	sessions, err := s.sessionRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		if sess.ID == currentSessionID {
+			continue
+		}
+
+		if err = s.revoke(ctx, sess); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revoke deletes the session and revokes its refresh token family, so neither
+// outstanding access tokens nor refresh tokens of the session can be used.
+func (s *SessionService) revoke(ctx context.Context, sess *session.Session) error {
+	if err := s.sessionRepo.Delete(ctx, sess); err != nil {
+		return err
+	}
+
+	return s.tokenRepo.RevokeRefreshTokenFamily(ctx, sess.ID, s.conf.Auth.RefreshTokenTTL)
+}
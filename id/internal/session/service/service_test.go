@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	authredis "github.com/maximegorov13/chat-app/id/internal/auth/repository/redis"
+	"github.com/maximegorov13/chat-app/id/internal/rediskeys"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	sessionredis "github.com/maximegorov13/chat-app/id/internal/session/repository/redis"
+	sessionservice "github.com/maximegorov13/chat-app/id/internal/session/service"
+	"github.com/maximegorov13/chat-app/id/internal/storage/redis"
+)
+
+type testDependencies struct {
+	sessionService session.SessionService
+	cleanupUser    func(userID int64)
+}
+
+var testDevice = session.Device{
+	UserAgent: "test-agent",
+	IP:        "127.0.0.1",
+}
+
+func getUniqueUserID() int64 {
+	return rand.Int64N(1<<62) + 1<<62
+}
+
+func setupTest(t testing.TB) *testDependencies {
+	t.Helper()
+
+	conf, err := configs.Load("../../../.env")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	redisClient, err := redis.NewRedis(ctx, conf)
+	require.NoError(t, err)
+
+	sessionRepo := sessionredis.NewSessionRepository(redisClient)
+	tokenRepo := authredis.NewTokenRepository(redisClient)
+
+	cleanupUser := func(userID int64) {
+		sessions, err := sessionRepo.FindByUserID(ctx, userID)
+		if err != nil {
+			t.Logf("cleanup query error: %v", err)
+		}
+
+		for _, s := range sessions {
+			if err = sessionRepo.Delete(ctx, s); err != nil {
+				t.Logf("cleanup exec error: %v", err)
+			}
+			if err = redisClient.Del(ctx, rediskeys.RevokedRefreshTokenFamilyKey(s.ID)); err != nil {
+				t.Logf("cleanup exec error: %v", err)
+			}
+		}
+	}
+
+	return &testDependencies{
+		sessionService: sessionservice.NewSessionService(sessionservice.SessionServiceDeps{
+			Conf:        conf,
+			SessionRepo: sessionRepo,
+			TokenRepo:   tokenRepo,
+		}),
+		cleanupUser: cleanupUser,
+	}
+}
+
+func TestSessionService_CreateSession(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful create session", func(t *testing.T) {
+		userID := getUniqueUserID()
+		t.Cleanup(func() {
+			deps.cleanupUser(userID)
+		})
+
+		s, err := deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+		require.NotEmpty(t, s.ID)
+		require.Equal(t, userID, s.UserID)
+		require.Equal(t, testDevice.UserAgent, s.UserAgent)
+		require.Equal(t, testDevice.IP, s.IP)
+		require.False(t, s.CreatedAt.IsZero())
+		require.False(t, s.LastSeenAt.IsZero())
+
+		found, err := deps.sessionService.GetSession(ctx, s.ID)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		require.Equal(t, s.ID, found.ID)
+	})
+}
+
+func TestSessionService_RevokeSession(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful revoke session", func(t *testing.T) {
+		userID := getUniqueUserID()
+		t.Cleanup(func() {
+			deps.cleanupUser(userID)
+		})
+
+		s, err := deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+
+		err = deps.sessionService.RevokeSession(ctx, userID, s.ID)
+		require.NoError(t, err)
+
+		found, err := deps.sessionService.GetSession(ctx, s.ID)
+		require.NoError(t, err)
+		require.Nil(t, found)
+
+		sessions, err := deps.sessionService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		require.Empty(t, sessions)
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		userID := getUniqueUserID()
+		t.Cleanup(func() {
+			deps.cleanupUser(userID)
+		})
+
+		s, err := deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+
+		err = deps.sessionService.RevokeSession(ctx, userID+1, s.ID)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestSessionService_RevokeOtherSessions(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("keeps current session", func(t *testing.T) {
+		userID := getUniqueUserID()
+		t.Cleanup(func() {
+			deps.cleanupUser(userID)
+		})
+
+		current, err := deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+		_, err = deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+		_, err = deps.sessionService.CreateSession(ctx, userID, testDevice)
+		require.NoError(t, err)
+
+		err = deps.sessionService.RevokeOtherSessions(ctx, userID, current.ID)
+		require.NoError(t, err)
+
+		sessions, err := deps.sessionService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.Equal(t, current.ID, sessions[0].ID)
+	})
+}
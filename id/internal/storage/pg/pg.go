@@ -1,26 +1,35 @@
 package pg
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
 
 	"github.com/maximegorov13/chat-app/id/configs"
 )
 
+// uniqueViolation is the SQLSTATE code of unique constraint violations.
+const uniqueViolation = "23505"
+
 type Postgres struct {
 	Sqlx *sqlx.DB
 	Sb   squirrel.StatementBuilderType
 }
 
 func NewPostgres(conf *configs.Config) (*Postgres, error) {
-	db, err := sqlx.Connect("postgres", conf.Postgres.Url)
+	sqlDB, err := tracing.OpenPostgres(conf.Postgres.Url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
+
+	db := sqlx.NewDb(sqlDB, "postgres")
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
 
@@ -31,3 +40,10 @@ func NewPostgres(conf *configs.Config) (*Postgres, error) {
 		Sb:   builder,
 	}, nil
 }
+
+// IsUniqueViolation reports whether the error is caused by a unique
+// constraint violation.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -2,11 +2,14 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+
 	"github.com/maximegorov13/chat-app/id/configs"
 )
 
@@ -21,6 +24,7 @@ func NewRedis(ctx context.Context, conf *configs.Config) (*Redis, error) {
 	}
 
 	rdb := redis.NewClient(opts)
+	rdb.AddHook(tracing.RedisHook{})
 	if err = rdb.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
@@ -34,14 +38,112 @@ func (r *Redis) Set(ctx context.Context, key string, value any, expiration time.
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
+func (r *Redis) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// SetKeepTTL overwrites the value of an existing key without touching its
+// expiration. Missing keys are not created.
+func (r *Redis) SetKeepTTL(ctx context.Context, key string, value any) error {
+	err := r.client.SetArgs(ctx, key, value, redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Err()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	return err
+}
+
 func (r *Redis) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+// GetDel gets the value of the key and deletes the key atomically.
+func (r *Redis) GetDel(ctx context.Context, key string) (string, error) {
+	return r.client.GetDel(ctx, key).Result()
+}
+
 func (r *Redis) Del(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
 
+// DelCount deletes the keys and returns how many of them existed.
+func (r *Redis) DelCount(ctx context.Context, keys ...string) (int64, error) {
+	return r.client.Del(ctx, keys...).Result()
+}
+
+func (r *Redis) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.client.Expire(ctx, key, expiration).Err()
+}
+
+// PTTL returns the remaining lifetime of the key, or zero if the key does not
+// exist or has no expiration.
+func (r *Redis) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (r *Redis) SAdd(ctx context.Context, key string, members ...any) error {
+	return r.client.SAdd(ctx, key, members...).Err()
+}
+
+func (r *Redis) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.client.SMembers(ctx, key).Result()
+}
+
+func (r *Redis) SRem(ctx context.Context, key string, members ...any) error {
+	return r.client.SRem(ctx, key, members...).Err()
+}
+
+func (r *Redis) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return r.client.ZAdd(ctx, key, redis.Z{
+		Score:  score,
+		Member: member,
+	}).Err()
+}
+
+// ZRemRangeByScore removes the members with a score between min and max,
+// which use the Redis syntax, e.g. "-inf" or "(100".
+func (r *Redis) ZRemRangeByScore(ctx context.Context, key, min, max string) error {
+	return r.client.ZRemRangeByScore(ctx, key, min, max).Err()
+}
+
+func (r *Redis) ZCard(ctx context.Context, key string) (int64, error) {
+	return r.client.ZCard(ctx, key).Result()
+}
+
+// ZMaxScore returns the highest score in the sorted set and false if it is
+// empty.
+func (r *Redis) ZMaxScore(ctx context.Context, key string) (float64, bool, error) {
+	members, err := r.client.ZRevRangeWithScores(ctx, key, 0, 0).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(members) == 0 {
+		return 0, false, nil
+	}
+
+	return members[0].Score, true, nil
+}
+
+// Eval runs a Lua script and returns its reply.
+func (r *Redis) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return r.client.Eval(ctx, script, keys, args...).Result()
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *Redis) Close() error {
 	return r.client.Close()
 }
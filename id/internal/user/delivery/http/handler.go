@@ -5,13 +5,16 @@ import (
 	"strconv"
 
 	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
 
 	"github.com/maximegorov13/chat-app/id/configs"
+	"github.com/maximegorov13/chat-app/id/internal/appcontext"
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
 	"github.com/maximegorov13/chat-app/id/internal/auth"
 	"github.com/maximegorov13/chat-app/id/internal/middleware"
 	"github.com/maximegorov13/chat-app/id/internal/req"
 	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/session"
 	"github.com/maximegorov13/chat-app/id/internal/user"
 )
 
@@ -19,7 +22,9 @@ type UserHandlerDeps struct {
 	Conf        *configs.Config
 	UserService user.UserService
 	TokenRepo   auth.TokenRepository
+	SessionRepo session.SessionRepository
 	JWT         *jwt.JWT
+	RateLimiter ratelimit.Limiter
 }
 
 type UserHandler struct {
@@ -33,12 +38,37 @@ func NewUserHandler(router *http.ServeMux, deps UserHandlerDeps) {
 		userService: deps.UserService,
 	}
 
-	router.HandleFunc("POST /api/users", handler.Register())
-	router.Handle("PUT /api/users/{id}", middleware.Auth(middleware.CheckUserAccessByID(handler.UpdateUser()), middleware.AuthDeps{
-		Conf:      deps.Conf,
-		TokenRepo: deps.TokenRepo,
-		JWT:       deps.JWT,
-	}))
+	authDeps := middleware.AuthDeps{
+		Conf:        deps.Conf,
+		TokenRepo:   deps.TokenRepo,
+		SessionRepo: deps.SessionRepo,
+		JWT:         deps.JWT,
+	}
+
+	registerLimit := middleware.RateLimitDeps{
+		Limiter: deps.RateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "register",
+			Limit: deps.Conf.RateLimit.Register,
+		},
+	}
+	authLimit := middleware.RateLimitDeps{
+		Limiter: deps.RateLimiter,
+		Policy: ratelimit.Policy{
+			Name:  "auth",
+			Limit: deps.Conf.RateLimit.Auth,
+		},
+	}
+
+	router.Handle("POST /api/users", middleware.RateLimit(handler.Register(), registerLimit))
+	router.Handle("POST /api/users/verify", middleware.RateLimit(handler.VerifyEmail(), authLimit))
+	router.Handle("POST /api/users/verify/resend", middleware.RateLimit(handler.ResendVerification(), authLimit))
+	router.Handle("GET /api/users/me", middleware.Auth(handler.GetMe(), authDeps))
+	router.Handle("GET /api/users/{id}", middleware.Auth(handler.GetUser(), authDeps))
+	router.Handle("PATCH /api/users/{id}", middleware.Auth(middleware.CheckUserAccessByID(handler.UpdateUser()), authDeps))
+	router.Handle("POST /api/users/{id}/password", middleware.Auth(middleware.CheckUserAccessByID(middleware.RateLimit(handler.ChangePassword(), authLimit)), authDeps))
+	router.Handle("PATCH /api/users/{id}/profile", middleware.Auth(middleware.CheckUserAccessByID(handler.UpdateProfile()), authDeps))
+	router.Handle("PUT /internal/users/{id}/last-seen", middleware.ServiceAuth(handler.RecordLastSeen(), deps.Conf.Auth.ServiceToken))
 }
 
 func (h *UserHandler) Register() http.HandlerFunc {
@@ -56,15 +86,58 @@ func (h *UserHandler) Register() http.HandlerFunc {
 		}
 
 		data := user.RegisterResponse{
-			ID:    u.ID,
-			Login: u.Login,
-			Name:  u.Name,
+			ID:     u.ID,
+			Login:  u.Login,
+			Name:   u.Name,
+			Email:  u.Email,
+			Status: u.Status,
 		}
 
 		res.JSON(w, http.StatusCreated, data, nil)
 	}
 }
 
+func (h *UserHandler) VerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[user.VerifyEmailRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		u, err := h.userService.VerifyEmail(r.Context(), &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		data := user.VerifyEmailResponse{
+			ID:     u.ID,
+			Email:  u.Email,
+			Status: u.Status,
+		}
+
+		res.JSON(w, http.StatusOK, data, nil)
+	}
+}
+
+func (h *UserHandler) ResendVerification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[user.ResendVerificationRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		if err = h.userService.ResendVerification(r.Context(), &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
+
 func (h *UserHandler) UpdateUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := req.HandleBody[user.UpdateUserRequest](r)
@@ -87,11 +160,125 @@ func (h *UserHandler) UpdateUser() http.HandlerFunc {
 		}
 
 		data := user.UpdateUserResponse{
-			ID:    u.ID,
-			Login: u.Login,
-			Name:  u.Name,
+			ID:           u.ID,
+			Login:        u.Login,
+			Name:         u.Name,
+			Email:        u.Email,
+			PendingEmail: u.PendingEmail,
 		}
 
 		res.JSON(w, http.StatusOK, data, nil)
 	}
 }
+
+func (h *UserHandler) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[user.ChangePasswordRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		sessionID := appcontext.GetContextSessionID(r.Context())
+
+		if err = h.userService.ChangePassword(r.Context(), userID, sessionID, &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
+
+func (h *UserHandler) GetMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(appcontext.GetContextUserID(r.Context()), 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrUnauthorized)
+			return
+		}
+
+		u, err := h.userService.GetUser(r.Context(), userID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, user.NewProfileResponse(u), nil)
+	}
+}
+
+func (h *UserHandler) GetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		u, err := h.userService.GetUser(r.Context(), userID)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, user.NewProfileResponse(u), nil)
+	}
+}
+
+func (h *UserHandler) UpdateProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[user.UpdateProfileRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		u, err := h.userService.UpdateProfile(r.Context(), userID, &body.Data)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON(w, http.StatusOK, user.NewProfileResponse(u), nil)
+	}
+}
+
+func (h *UserHandler) RecordLastSeen() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[user.RecordLastSeenRequest](r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		idStr := r.PathValue("id")
+		userID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			res.Error(w, apperrors.ErrBadRequest)
+			return
+		}
+
+		if err = h.userService.RecordLastSeen(r.Context(), userID, &body.Data); err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.JSON[any](w, http.StatusOK, nil, nil)
+	}
+}
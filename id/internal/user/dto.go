@@ -1,10 +1,21 @@
 package user
 
-import "github.com/go-ozzo/ozzo-validation"
+import (
+	"errors"
+	"regexp"
+	"time"
+	// Time zones are validated against the embedded database, so validation
+	// does not depend on the zoneinfo files of the host.
+	_ "time/tzdata"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
 
 type RegisterRequest struct {
 	Login    string `json:"login"`
 	Name     string `json:"name"`
+	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
@@ -12,32 +23,170 @@ func (r RegisterRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Login, validation.Required, validation.Length(3, 30)),
 		validation.Field(&r.Name, validation.Required, validation.Length(2, 50)),
+		validation.Field(&r.Email, validation.Required, validation.Length(0, 254), is.Email),
 		validation.Field(&r.Password, validation.Required, validation.Length(8, 30)),
 	)
 }
 
 type RegisterResponse struct {
-	ID    int64  `json:"id"`
-	Login string `json:"login"`
-	Name  string `json:"name"`
+	ID     int64  `json:"id"`
+	Login  string `json:"login"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Status Status `json:"status"`
 }
 
+// UpdateUserRequest changes only the fields present in the request. Changing
+// the email requires the current password.
 type UpdateUserRequest struct {
-	Login    string `json:"login"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Login           *string `json:"login"`
+	Name            *string `json:"name"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
 }
 
 func (r UpdateUserRequest) Validate() error {
+	var currentPasswordRules []validation.Rule
+	if r.Email != nil {
+		currentPasswordRules = append(currentPasswordRules, validation.Required)
+	}
+
 	return validation.ValidateStruct(&r,
-		validation.Field(&r.Login, validation.Required, validation.Length(3, 30)),
-		validation.Field(&r.Name, validation.Required, validation.Length(2, 50)),
-		validation.Field(&r.Password, validation.Required, validation.Length(8, 30)),
+		validation.Field(&r.Login, validation.NilOrNotEmpty, validation.Length(3, 30)),
+		validation.Field(&r.Name, validation.NilOrNotEmpty, validation.Length(2, 50)),
+		validation.Field(&r.Email, validation.NilOrNotEmpty, validation.Length(0, 254), is.Email),
+		validation.Field(&r.CurrentPassword, currentPasswordRules...),
 	)
 }
 
 type UpdateUserResponse struct {
-	ID    int64  `json:"id"`
-	Login string `json:"login"`
-	Name  string `json:"name"`
+	ID           int64  `json:"id"`
+	Login        string `json:"login"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (r ChangePasswordRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.CurrentPassword, validation.Required),
+		validation.Field(&r.NewPassword, validation.Required, validation.Length(8, 30)),
+	)
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func (r VerifyEmailRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Token, validation.Required),
+	)
+}
+
+type VerifyEmailResponse struct {
+	ID     int64  `json:"id"`
+	Email  string `json:"email"`
+	Status Status `json:"status"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+func (r ResendVerificationRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.Required, is.Email),
+	)
+}
+
+// UpdateProfileRequest changes only the fields present in the request. An
+// empty string clears a field.
+type UpdateProfileRequest struct {
+	AvatarURL   *string `json:"avatar_url"`
+	Bio         *string `json:"bio"`
+	StatusText  *string `json:"status_text"`
+	StatusEmoji *string `json:"status_emoji"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+}
+
+func (r UpdateProfileRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.AvatarURL, validation.Length(0, 2048), is.URL),
+		validation.Field(&r.Bio, validation.RuneLength(0, 500)),
+		validation.Field(&r.StatusText, validation.RuneLength(0, 100)),
+		validation.Field(&r.StatusEmoji, validation.RuneLength(0, 16)),
+		validation.Field(&r.Locale, validation.Match(localeRegexp)),
+		validation.Field(&r.Timezone, validation.By(validateTimezone)),
+	)
+}
+
+// localeRegexp accepts language tags like "en" or "pt-BR".
+var localeRegexp = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// validateTimezone accepts IANA time zone names like "Europe/Moscow".
+func validateTimezone(value interface{}) error {
+	value, isNil := validation.Indirect(value)
+	if isNil || validation.IsEmpty(value) {
+		return nil
+	}
+
+	tz, err := validation.EnsureString(value)
+	if err != nil {
+		return err
+	}
+	if _, err = time.LoadLocation(tz); err != nil || tz == "Local" {
+		return errors.New("must be a valid IANA time zone")
+	}
+
+	return nil
+}
+
+// ProfileResponse is the public profile of a user.
+type ProfileResponse struct {
+	ID          int64      `json:"id"`
+	Login       string     `json:"login"`
+	Name        string     `json:"name"`
+	AvatarURL   string     `json:"avatar_url"`
+	Bio         string     `json:"bio"`
+	StatusText  string     `json:"status_text"`
+	StatusEmoji string     `json:"status_emoji"`
+	Locale      string     `json:"locale"`
+	Timezone    string     `json:"timezone"`
+	LastSeenAt  *time.Time `json:"last_seen_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func NewProfileResponse(u *User) ProfileResponse {
+	return ProfileResponse{
+		ID:          u.ID,
+		Login:       u.Login,
+		Name:        u.Name,
+		AvatarURL:   u.AvatarURL,
+		Bio:         u.Bio,
+		StatusText:  u.StatusText,
+		StatusEmoji: u.StatusEmoji,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		LastSeenAt:  u.LastSeenAt,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// RecordLastSeenRequest is sent by the chat service when a user was last
+// connected.
+type RecordLastSeenRequest struct {
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+func (r RecordLastSeenRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.LastSeenAt, validation.Required),
+	)
 }
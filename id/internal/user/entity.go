@@ -1,12 +1,42 @@
 package user
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// Status is the state of a user account. Only active accounts can log in.
+type Status string
+
+const (
+	StatusPending   Status = "pending" // Registered, email not verified yet
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+	StatusDeleted   Status = "deleted"
+)
 
 type User struct {
-	ID        int64     `db:"id"`
-	Login     string    `db:"login"`
-	Name      string    `db:"name"`
-	Password  string    `db:"password"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID              int64      `db:"id"`
+	Login           string     `db:"login"`
+	Name            string     `db:"name"`
+	Email           string     `db:"email"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+	PendingEmail    string     `db:"pending_email"` // Requested new email, until verified
+	Status          Status     `db:"status"`
+	Password        string     `db:"password"`
+	AvatarURL       string     `db:"avatar_url"`
+	Bio             string     `db:"bio"`
+	StatusText      string     `db:"status_text"`
+	StatusEmoji     string     `db:"status_emoji"`
+	Locale          string     `db:"locale"`
+	Timezone        string     `db:"timezone"`
+	LastSeenAt      *time.Time `db:"last_seen_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+}
+
+// NormalizeEmail brings an email address to the form it is stored and looked
+// up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -1,10 +1,16 @@
 package user
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type UserRepository interface {
 	Create(ctx context.Context, user *User) error
 	FindByLogin(ctx context.Context, login string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
 	FindByID(ctx context.Context, id int64) (*User, error)
 	Update(ctx context.Context, user *User) error
+	UpdateProfile(ctx context.Context, user *User) error
+	UpdateLastSeen(ctx context.Context, id int64, lastSeenAt time.Time) error
 }
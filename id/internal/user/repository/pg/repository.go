@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
 	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
 	"github.com/maximegorov13/chat-app/id/internal/user"
 )
@@ -22,10 +24,12 @@ func NewUserRepository(db *pg.Postgres) *UserRepository {
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *user.User) error {
+	defer metrics.Storage.Observe("postgres", "user", "Create", time.Now())
+
 	query, args, err := r.db.Sb.
 		Insert("users").
-		Columns("login", "name", "password").
-		Values(user.Login, user.Name, user.Password).
+		Columns("login", "name", "email", "status", "password").
+		Values(user.Login, user.Name, user.Email, user.Status, user.Password).
 		Suffix("RETURNING id, created_at, updated_at").
 		ToSql()
 	if err != nil {
@@ -36,6 +40,8 @@ func (r *UserRepository) Create(ctx context.Context, user *user.User) error {
 }
 
 func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*user.User, error) {
+	defer metrics.Storage.Observe("postgres", "user", "FindByLogin", time.Now())
+
 	query, args, err := r.db.Sb.
 		Select("*").
 		From("users").
@@ -58,7 +64,34 @@ func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*user.U
 	return &u, nil
 }
 
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*user.User, error) {
+	defer metrics.Storage.Observe("postgres", "user", "FindByEmail", time.Now())
+
+	query, args, err := r.db.Sb.
+		Select("*").
+		From("users").
+		Where(squirrel.Eq{
+			"email": email,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var u user.User
+	if err = r.db.Sqlx.GetContext(ctx, &u, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
 func (r *UserRepository) FindByID(ctx context.Context, id int64) (*user.User, error) {
+	defer metrics.Storage.Observe("postgres", "user", "FindByID", time.Now())
+
 	query, args, err := r.db.Sb.
 		Select("*").
 		From("users").
@@ -82,13 +115,19 @@ func (r *UserRepository) FindByID(ctx context.Context, id int64) (*user.User, er
 }
 
 func (r *UserRepository) Update(ctx context.Context, user *user.User) error {
+	defer metrics.Storage.Observe("postgres", "user", "Update", time.Now())
+
 	query, args, err := r.db.Sb.
 		Update("users").
 		SetMap(map[string]any{
-			"login":      user.Login,
-			"name":       user.Name,
-			"password":   user.Password,
-			"updated_at": squirrel.Expr("CURRENT_TIMESTAMP"),
+			"login":             user.Login,
+			"name":              user.Name,
+			"email":             user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+			"pending_email":     user.PendingEmail,
+			"status":            user.Status,
+			"password":          user.Password,
+			"updated_at":        squirrel.Expr("CURRENT_TIMESTAMP"),
 		}).
 		Where(squirrel.Eq{
 			"id": user.ID,
@@ -101,3 +140,49 @@ func (r *UserRepository) Update(ctx context.Context, user *user.User) error {
 
 	return r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 }
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *user.User) error {
+	defer metrics.Storage.Observe("postgres", "user", "UpdateProfile", time.Now())
+
+	query, args, err := r.db.Sb.
+		Update("users").
+		SetMap(map[string]any{
+			"avatar_url":   user.AvatarURL,
+			"bio":          user.Bio,
+			"status_text":  user.StatusText,
+			"status_emoji": user.StatusEmoji,
+			"locale":       user.Locale,
+			"timezone":     user.Timezone,
+			"updated_at":   squirrel.Expr("CURRENT_TIMESTAMP"),
+		}).
+		Where(squirrel.Eq{
+			"id": user.ID,
+		}).
+		Suffix("RETURNING updated_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	return r.db.Sqlx.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
+}
+
+// UpdateLastSeen never moves the last seen time back, since the reports of
+// several chat service instances can arrive out of order.
+func (r *UserRepository) UpdateLastSeen(ctx context.Context, id int64, lastSeenAt time.Time) error {
+	defer metrics.Storage.Observe("postgres", "user", "UpdateLastSeen", time.Now())
+
+	query, args, err := r.db.Sb.
+		Update("users").
+		Set("last_seen_at", squirrel.Expr("GREATEST(last_seen_at, ?)", lastSeenAt)).
+		Where(squirrel.Eq{
+			"id": id,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Sqlx.ExecContext(ctx, query, args...)
+	return err
+}
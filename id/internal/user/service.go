@@ -7,4 +7,10 @@ import (
 type UserService interface {
 	Register(ctx context.Context, req *RegisterRequest) (*User, error)
 	UpdateUser(ctx context.Context, userID int64, req *UpdateUserRequest) (*User, error)
+	VerifyEmail(ctx context.Context, req *VerifyEmailRequest) (*User, error)
+	ResendVerification(ctx context.Context, req *ResendVerificationRequest) error
+	ChangePassword(ctx context.Context, userID int64, currentSessionID string, req *ChangePasswordRequest) error
+	GetUser(ctx context.Context, userID int64) (*User, error)
+	UpdateProfile(ctx context.Context, userID int64, req *UpdateProfileRequest) (*User, error)
+	RecordLastSeen(ctx context.Context, userID int64, req *RecordLastSeenRequest) error
 }
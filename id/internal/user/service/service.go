@@ -2,24 +2,47 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/maximegorov13/chat-app/id/configs"
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/auth"
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	"github.com/maximegorov13/chat-app/id/internal/metrics"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
 	"github.com/maximegorov13/chat-app/id/internal/user"
 )
 
 type UserServiceDeps struct {
-	UserRepo user.UserRepository
+	Conf           *configs.Config
+	UserRepo       user.UserRepository
+	TokenRepo      auth.TokenRepository
+	SessionService session.SessionService
+	Mailer         mailer.Mailer
 }
 
 type UserService struct {
-	userRepo user.UserRepository
+	conf           *configs.Config
+	userRepo       user.UserRepository
+	tokenRepo      auth.TokenRepository
+	sessionService session.SessionService
+	mailer         mailer.Mailer
 }
 
 func NewUserService(deps UserServiceDeps) *UserService {
 	return &UserService{
-		userRepo: deps.UserRepo,
+		conf:           deps.Conf,
+		userRepo:       deps.UserRepo,
+		tokenRepo:      deps.TokenRepo,
+		sessionService: deps.SessionService,
+		mailer:         deps.Mailer,
 	}
 }
 
@@ -32,6 +55,11 @@ func (s *UserService) Register(ctx context.Context, req *user.RegisterRequest) (
 		return nil, apperrors.ErrUserExists
 	}
 
+	email := user.NormalizeEmail(req.Email)
+	if err = s.checkEmailAvailable(ctx, email); err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -40,40 +68,288 @@ func (s *UserService) Register(ctx context.Context, req *user.RegisterRequest) (
 	u := &user.User{
 		Login:    req.Login,
 		Name:     req.Name,
+		Email:    email,
+		Status:   user.StatusPending,
 		Password: string(hashedPassword),
 	}
 
 	if err = s.userRepo.Create(ctx, u); err != nil {
+		if pg.IsUniqueViolation(err) {
+			return nil, apperrors.ErrUserExists
+		}
 		return nil, err
 	}
 
+	metrics.Registrations.Inc()
+
+	// The account is created anyway, the email can be sent again on request.
+	if err = s.sendVerification(ctx, u, u.Email); err != nil {
+		slog.ErrorContext(ctx, "Error sending verification email", "user_id", u.ID, "error", err)
+	}
+
 	return u, nil
 }
 
 func (s *UserService) UpdateUser(ctx context.Context, userID int64, req *user.UpdateUserRequest) (*user.User, error) {
-	existedUser, err := s.userRepo.FindByID(ctx, userID)
+	u, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	if existedUser == nil {
+	if u == nil {
 		return nil, apperrors.ErrNotFound
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if req.Login != nil && *req.Login != u.Login {
+		existedUser, err := s.userRepo.FindByLogin(ctx, *req.Login)
+		if err != nil {
+			return nil, err
+		}
+		if existedUser != nil {
+			return nil, apperrors.ErrUserExists
+		}
+		u.Login = *req.Login
+	}
+	if req.Name != nil {
+		u.Name = *req.Name
+	}
+
+	// The new email replaces the current one only once it is verified, so a
+	// stolen access token cannot redirect password resets.
+	var pendingEmail string
+	if req.Email != nil {
+		err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.CurrentPassword))
+		if err != nil {
+			return nil, apperrors.ErrInvalidCredentials
+		}
+
+		email := user.NormalizeEmail(*req.Email)
+		if email != u.Email {
+			if err = s.checkEmailAvailable(ctx, email); err != nil {
+				return nil, err
+			}
+			pendingEmail = email
+		}
+		u.PendingEmail = pendingEmail
+	}
+
+	if err = s.userRepo.Update(ctx, u); err != nil {
+		if pg.IsUniqueViolation(err) {
+			return nil, apperrors.ErrUserExists
+		}
+		return nil, err
+	}
+
+	if pendingEmail != "" {
+		if err = s.sendVerification(ctx, u, pendingEmail); err != nil {
+			slog.ErrorContext(ctx, "Error sending verification email", "user_id", u.ID, "error", err)
+		}
+	}
+
+	return u, nil
+}
+
+// VerifyEmail confirms the email the token was sent to and activates pending
+// accounts. Suspended and deleted accounts stay as they are.
+func (s *UserService) VerifyEmail(ctx context.Context, req *user.VerifyEmailRequest) (*user.User, error) {
+	token, err := s.tokenRepo.ConsumeEmailVerificationToken(ctx, req.Token)
 	if err != nil {
 		return nil, err
 	}
+	if token == nil {
+		return nil, apperrors.ErrInvalidVerifyToken
+	}
 
-	u := &user.User{
-		ID:       userID,
-		Login:    req.Login,
-		Name:     req.Name,
-		Password: string(hashedPassword),
+	u, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, apperrors.ErrInvalidVerifyToken
+	}
+
+	switch {
+	case u.PendingEmail != "" && u.PendingEmail == token.Email:
+		// The email may have been taken since the change was requested
+		if err = s.checkEmailAvailable(ctx, u.PendingEmail); err != nil {
+			return nil, err
+		}
+		u.Email = u.PendingEmail
+		u.PendingEmail = ""
+	case u.Email != token.Email:
+		return nil, apperrors.ErrInvalidVerifyToken
+	}
+
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	if u.Status == user.StatusPending {
+		u.Status = user.StatusActive
 	}
 
 	if err = s.userRepo.Update(ctx, u); err != nil {
+		if pg.IsUniqueViolation(err) {
+			return nil, apperrors.ErrEmailExists
+		}
 		return nil, err
 	}
 
 	return u, nil
 }
+
+// ResendVerification mails a new verification link to the owner of the email
+// if it is not verified yet. Unknown emails are silently ignored.
+func (s *UserService) ResendVerification(ctx context.Context, req *user.ResendVerificationRequest) error {
+	u, err := s.userRepo.FindByEmail(ctx, user.NormalizeEmail(req.Email))
+	if err != nil {
+		return err
+	}
+	if u == nil || u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendVerification(ctx, u, u.Email)
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// session except the current one is revoked, so a leaked password or token
+// stops working on other devices.
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, currentSessionID string, req *user.ChangePasswordRequest) error {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return apperrors.ErrNotFound
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.CurrentPassword))
+	if err != nil {
+		return apperrors.ErrInvalidCredentials
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hashedPassword)
+
+	if err = s.userRepo.Update(ctx, u); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeOtherSessions(ctx, userID, currentSessionID)
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID int64) (*user.User, error) {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return u, nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID int64, req *user.UpdateProfileRequest) (*user.User, error) {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	if req.AvatarURL != nil {
+		u.AvatarURL = *req.AvatarURL
+	}
+	if req.Bio != nil {
+		u.Bio = *req.Bio
+	}
+	if req.StatusText != nil {
+		u.StatusText = *req.StatusText
+	}
+	if req.StatusEmoji != nil {
+		u.StatusEmoji = *req.StatusEmoji
+	}
+	if req.Locale != nil {
+		u.Locale = *req.Locale
+	}
+	if req.Timezone != nil {
+		u.Timezone = *req.Timezone
+	}
+
+	if err = s.userRepo.UpdateProfile(ctx, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// RecordLastSeen moves the last seen time of the user forward. Times in the
+// future, e.g. from a skewed clock, are capped at now.
+func (s *UserService) RecordLastSeen(ctx context.Context, userID int64, req *user.RecordLastSeenRequest) error {
+	u, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return apperrors.ErrNotFound
+	}
+
+	lastSeenAt := req.LastSeenAt
+	if now := time.Now(); lastSeenAt.After(now) {
+		lastSeenAt = now
+	}
+
+	return s.userRepo.UpdateLastSeen(ctx, userID, lastSeenAt)
+}
+
+func (s *UserService) checkEmailAvailable(ctx context.Context, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	existedUser, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existedUser != nil {
+		return apperrors.ErrEmailExists
+	}
+
+	return nil
+}
+
+// sendVerification mails a verification link for the email, the current or
+// the pending email of the user.
+func (s *UserService) sendVerification(ctx context.Context, u *user.User, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepo.SaveEmailVerificationToken(ctx, &auth.EmailVerificationToken{
+		Token:     token,
+		UserID:    u.ID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(s.conf.Auth.EmailVerificationTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimSuffix(s.conf.Mail.AppURL, "/"), url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hi %s,\n\nFollow the link to confirm your email:\n%s\n\n"+
+			"The link expires in %s.",
+			u.Name, link, s.conf.Auth.EmailVerificationTokenTTL),
+	})
+}
@@ -3,7 +3,10 @@ package service_test
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -12,22 +15,78 @@ import (
 
 	"github.com/maximegorov13/chat-app/id/configs"
 	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	authredis "github.com/maximegorov13/chat-app/id/internal/auth/repository/redis"
+	"github.com/maximegorov13/chat-app/id/internal/mailer"
+	"github.com/maximegorov13/chat-app/id/internal/session"
+	sessionredis "github.com/maximegorov13/chat-app/id/internal/session/repository/redis"
+	sessionservice "github.com/maximegorov13/chat-app/id/internal/session/service"
 	"github.com/maximegorov13/chat-app/id/internal/storage/pg"
+	"github.com/maximegorov13/chat-app/id/internal/storage/redis"
 	"github.com/maximegorov13/chat-app/id/internal/user"
 	userpg "github.com/maximegorov13/chat-app/id/internal/user/repository/pg"
 	userservice "github.com/maximegorov13/chat-app/id/internal/user/service"
 )
 
 type testDependencies struct {
-	userService user.UserService
-	userRepo    user.UserRepository
-	cleanupUser func(userID int64)
+	userService    user.UserService
+	userRepo       user.UserRepository
+	sessionService session.SessionService
+	mailer         *mailerSpy
+	cleanupUser    func(userID int64)
+}
+
+// mailerSpy records sent emails instead of delivering them.
+type mailerSpy struct {
+	mu       sync.Mutex
+	messages []*mailer.Message
+}
+
+func (m *mailerSpy) Send(_ context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// lastTo returns the last email sent to the address.
+func (m *mailerSpy) lastTo(to string) *mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i]
+		}
+	}
+
+	return nil
+}
+
+var verifyTokenRegexp = regexp.MustCompile(`token=(\S+)`)
+
+// verifyToken extracts the token from the last verification email sent to
+// the address.
+func (m *mailerSpy) verifyToken(t *testing.T, to string) string {
+	t.Helper()
+
+	msg := m.lastTo(to)
+	require.NotNil(t, msg)
+
+	match := verifyTokenRegexp.FindStringSubmatch(msg.Body)
+	require.Len(t, match, 2)
+
+	return match[1]
 }
 
 func getUniqueLogin() string {
 	return fmt.Sprintf("user-%s", uuid.New())
 }
 
+func getUniqueEmail() string {
+	return fmt.Sprintf("user-%s@example.com", uuid.New())
+}
+
 func setupTest(t testing.TB) *testDependencies {
 	t.Helper()
 
@@ -37,7 +96,18 @@ func setupTest(t testing.TB) *testDependencies {
 	pgClient, err := pg.NewPostgres(conf)
 	require.NoError(t, err)
 
+	redisClient, err := redis.NewRedis(context.Background(), conf)
+	require.NoError(t, err)
+
 	userRepo := userpg.NewUserRepository(pgClient)
+	tokenRepo := authredis.NewTokenRepository(redisClient)
+	sessionRepo := sessionredis.NewSessionRepository(redisClient)
+
+	sessionService := sessionservice.NewSessionService(sessionservice.SessionServiceDeps{
+		Conf:        conf,
+		SessionRepo: sessionRepo,
+		TokenRepo:   tokenRepo,
+	})
 
 	cleanupUser := func(userID int64) {
 		query, args, err := pgClient.Sb.
@@ -56,12 +126,20 @@ func setupTest(t testing.TB) *testDependencies {
 		}
 	}
 
+	mailSpy := &mailerSpy{}
+
 	return &testDependencies{
 		userService: userservice.NewUserService(userservice.UserServiceDeps{
-			UserRepo: userRepo,
+			Conf:           conf,
+			UserRepo:       userRepo,
+			TokenRepo:      tokenRepo,
+			SessionService: sessionService,
+			Mailer:         mailSpy,
 		}),
-		userRepo:    userRepo,
-		cleanupUser: cleanupUser,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		mailer:         mailSpy,
+		cleanupUser:    cleanupUser,
 	}
 }
 
@@ -120,11 +198,136 @@ func TestUserService_Register(t *testing.T) {
 	})
 }
 
+func TestUserService_VerifyEmail(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful verification", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    getUniqueEmail(),
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.StatusPending, registeredUser.Status)
+		require.Nil(t, registeredUser.EmailVerifiedAt)
+
+		token := deps.mailer.verifyToken(t, registerReq.Email)
+
+		u, err := deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: token,
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.StatusActive, u.Status)
+		require.NotNil(t, u.EmailVerifiedAt)
+
+		dbUser, err := deps.userRepo.FindByID(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Equal(t, user.StatusActive, dbUser.Status)
+		require.NotNil(t, dbUser.EmailVerifiedAt)
+
+		_, err = deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: token,
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidVerifyToken)
+	})
+
+	t.Run("resend verification", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    getUniqueEmail(),
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		firstToken := deps.mailer.verifyToken(t, registerReq.Email)
+
+		err = deps.userService.ResendVerification(ctx, &user.ResendVerificationRequest{
+			Email: registerReq.Email,
+		})
+		require.NoError(t, err)
+
+		secondToken := deps.mailer.verifyToken(t, registerReq.Email)
+		require.NotEqual(t, firstToken, secondToken)
+
+		u, err := deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: secondToken,
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.StatusActive, u.Status)
+	})
+
+	t.Run("token of a changed email", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    getUniqueEmail(),
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		oldToken := deps.mailer.verifyToken(t, registerReq.Email)
+
+		newEmail := getUniqueEmail()
+		_, err = deps.userService.UpdateUser(ctx, registeredUser.ID, &user.UpdateUserRequest{
+			Email:           &newEmail,
+			CurrentPassword: registerReq.Password,
+		})
+		require.NoError(t, err)
+		newToken := deps.mailer.verifyToken(t, newEmail)
+
+		// The current email stays in use until the new one is verified
+		u, err := deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: oldToken,
+		})
+		require.NoError(t, err)
+		require.Equal(t, registerReq.Email, u.Email)
+		require.Equal(t, newEmail, u.PendingEmail)
+
+		u, err = deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: newToken,
+		})
+		require.NoError(t, err)
+		require.Equal(t, newEmail, u.Email)
+		require.Empty(t, u.PendingEmail)
+		require.Equal(t, user.StatusActive, u.Status)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := deps.userService.VerifyEmail(ctx, &user.VerifyEmailRequest{
+			Token: "invalid-token",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidVerifyToken)
+	})
+}
+
 func TestUserService_UpdateUser(t *testing.T) {
 	deps := setupTest(t)
 
 	ctx := context.Background()
 
+	ptr := func(s string) *string {
+		return &s
+	}
+
 	t.Run("successful update user", func(t *testing.T) {
 		uniqueLogin := getUniqueLogin()
 		registerReq := &user.RegisterRequest{
@@ -140,32 +343,349 @@ func TestUserService_UpdateUser(t *testing.T) {
 		require.NoError(t, err)
 
 		updateReq := &user.UpdateUserRequest{
-			Login:    "updated_login",
-			Name:     "Updated Name",
-			Password: "newpassword123",
+			Login: ptr(getUniqueLogin()),
+			Name:  ptr("Updated Name"),
 		}
 		updatedUser, err := deps.userService.UpdateUser(ctx, registeredUser.ID, updateReq)
 		require.NoError(t, err)
 		require.NotNil(t, updatedUser)
-		require.Equal(t, updateReq.Login, updatedUser.Login)
-		require.Equal(t, updateReq.Name, updatedUser.Name)
+		require.Equal(t, *updateReq.Login, updatedUser.Login)
+		require.Equal(t, *updateReq.Name, updatedUser.Name)
 
-		err = bcrypt.CompareHashAndPassword([]byte(updatedUser.Password), []byte(updateReq.Password))
+		err = bcrypt.CompareHashAndPassword([]byte(updatedUser.Password), []byte(registerReq.Password))
 		require.NoError(t, err)
+	})
 
-		err = bcrypt.CompareHashAndPassword([]byte(updatedUser.Password), []byte(registerReq.Password))
-		require.Error(t, err)
+	t.Run("partial update", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Original Name",
+			Password: "originalpass",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		updatedUser, err := deps.userService.UpdateUser(ctx, registeredUser.ID, &user.UpdateUserRequest{
+			Name: ptr("Updated Name"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, registeredUser.Login, updatedUser.Login)
+		require.Equal(t, "Updated Name", updatedUser.Name)
+
+		dbUser, err := deps.userRepo.FindByID(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Equal(t, registeredUser.Login, dbUser.Login)
+		require.Equal(t, "Updated Name", dbUser.Name)
+		require.Equal(t, registeredUser.Password, dbUser.Password)
+	})
+
+	t.Run("login taken", func(t *testing.T) {
+		first, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "First User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(first.ID)
+		})
+		require.NoError(t, err)
+
+		second, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Second User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(second.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.userService.UpdateUser(ctx, second.ID, &user.UpdateUserRequest{
+			Login: ptr(first.Login),
+		})
+		require.ErrorIs(t, err, apperrors.ErrUserExists)
+	})
+
+	t.Run("email change waits for verification", func(t *testing.T) {
+		registerReq := &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Email:    getUniqueEmail(),
+			Password: "12345678",
+		}
+
+		registeredUser, err := deps.userService.Register(ctx, registerReq)
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		newEmail := getUniqueEmail()
+		_, err = deps.userService.UpdateUser(ctx, registeredUser.ID, &user.UpdateUserRequest{
+			Email:           &newEmail,
+			CurrentPassword: "wrongpassword",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+		require.Nil(t, deps.mailer.lastTo(newEmail))
+
+		updatedUser, err := deps.userService.UpdateUser(ctx, registeredUser.ID, &user.UpdateUserRequest{
+			Email:           &newEmail,
+			CurrentPassword: registerReq.Password,
+		})
+		require.NoError(t, err)
+		require.Equal(t, registerReq.Email, updatedUser.Email)
+		require.Equal(t, newEmail, updatedUser.PendingEmail)
+
+		dbUser, err := deps.userRepo.FindByEmail(ctx, registerReq.Email)
+		require.NoError(t, err)
+		require.NotNil(t, dbUser)
+		require.Equal(t, registeredUser.ID, dbUser.ID)
+
+		dbUser, err = deps.userRepo.FindByEmail(ctx, newEmail)
+		require.NoError(t, err)
+		require.Nil(t, dbUser)
+
+		// Setting the current email back cancels the change
+		updatedUser, err = deps.userService.UpdateUser(ctx, registeredUser.ID, &user.UpdateUserRequest{
+			Email:           &registerReq.Email,
+			CurrentPassword: registerReq.Password,
+		})
+		require.NoError(t, err)
+		require.Empty(t, updatedUser.PendingEmail)
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		uniqueLogin := getUniqueLogin()
 		updateReq := &user.UpdateUserRequest{
-			Login:    uniqueLogin,
-			Name:     "Updated Name",
-			Password: "newpassword123",
+			Login: ptr(getUniqueLogin()),
+			Name:  ptr("Updated Name"),
 		}
 		_, err := deps.userService.UpdateUser(ctx, 9999999999, updateReq)
 		require.Error(t, err)
 		require.ErrorIs(t, err, apperrors.ErrNotFound)
 	})
 }
+
+func TestUserService_ChangePassword(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful change password", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "originalpass",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		currentSession, err := deps.sessionService.CreateSession(ctx, registeredUser.ID, session.Device{})
+		require.NoError(t, err)
+		otherSession, err := deps.sessionService.CreateSession(ctx, registeredUser.ID, session.Device{})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = deps.sessionService.RevokeOtherSessions(ctx, registeredUser.ID, "")
+		})
+
+		err = deps.userService.ChangePassword(ctx, registeredUser.ID, currentSession.ID, &user.ChangePasswordRequest{
+			CurrentPassword: "originalpass",
+			NewPassword:     "newpassword123",
+		})
+		require.NoError(t, err)
+
+		dbUser, err := deps.userRepo.FindByID(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte("newpassword123"))
+		require.NoError(t, err)
+
+		sess, err := deps.sessionService.GetSession(ctx, currentSession.ID)
+		require.NoError(t, err)
+		require.NotNil(t, sess)
+
+		sess, err = deps.sessionService.GetSession(ctx, otherSession.ID)
+		require.NoError(t, err)
+		require.Nil(t, sess)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "originalpass",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		err = deps.userService.ChangePassword(ctx, registeredUser.ID, "", &user.ChangePasswordRequest{
+			CurrentPassword: "wrongpass",
+			NewPassword:     "newpassword123",
+		})
+		require.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+		dbUser, err := deps.userRepo.FindByID(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Equal(t, registeredUser.Password, dbUser.Password)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := deps.userService.ChangePassword(ctx, 9999999999, "", &user.ChangePasswordRequest{
+			CurrentPassword: "originalpass",
+			NewPassword:     "newpassword123",
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestUserService_GetUser(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("successful get user", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		u, err := deps.userService.GetUser(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Equal(t, registeredUser.Login, u.Login)
+		require.Equal(t, registeredUser.Name, u.Name)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := deps.userService.GetUser(ctx, 9999999999)
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestUserService_UpdateProfile(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	ptr := func(s string) *string {
+		return &s
+	}
+
+	t.Run("partial update", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		_, err = deps.userService.UpdateProfile(ctx, registeredUser.ID, &user.UpdateProfileRequest{
+			Bio:      ptr("Hello"),
+			Timezone: ptr("Europe/Moscow"),
+		})
+		require.NoError(t, err)
+
+		u, err := deps.userService.UpdateProfile(ctx, registeredUser.ID, &user.UpdateProfileRequest{
+			StatusText: ptr("Busy"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Hello", u.Bio)
+		require.Equal(t, "Europe/Moscow", u.Timezone)
+		require.Equal(t, "Busy", u.StatusText)
+
+		dbUser, err := deps.userRepo.FindByID(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Hello", dbUser.Bio)
+		require.Equal(t, "Europe/Moscow", dbUser.Timezone)
+		require.Equal(t, "Busy", dbUser.StatusText)
+		require.Equal(t, registeredUser.Password, dbUser.Password)
+
+		u, err = deps.userService.UpdateProfile(ctx, registeredUser.ID, &user.UpdateProfileRequest{
+			Bio: ptr(""),
+		})
+		require.NoError(t, err)
+		require.Empty(t, u.Bio)
+		require.Equal(t, "Busy", u.StatusText)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := deps.userService.UpdateProfile(ctx, 9999999999, &user.UpdateProfileRequest{
+			Bio: ptr("Hello"),
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
+
+func TestUserService_RecordLastSeen(t *testing.T) {
+	deps := setupTest(t)
+
+	ctx := context.Background()
+
+	t.Run("only moves forward", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		seenAt := time.Now().Add(-5 * time.Minute).Truncate(time.Microsecond)
+		err = deps.userService.RecordLastSeen(ctx, registeredUser.ID, &user.RecordLastSeenRequest{
+			LastSeenAt: seenAt,
+		})
+		require.NoError(t, err)
+
+		err = deps.userService.RecordLastSeen(ctx, registeredUser.ID, &user.RecordLastSeenRequest{
+			LastSeenAt: seenAt.Add(-time.Hour),
+		})
+		require.NoError(t, err)
+
+		u, err := deps.userService.GetUser(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.NotNil(t, u.LastSeenAt)
+		require.True(t, seenAt.Equal(*u.LastSeenAt))
+	})
+
+	t.Run("future time is capped", func(t *testing.T) {
+		registeredUser, err := deps.userService.Register(ctx, &user.RegisterRequest{
+			Login:    getUniqueLogin(),
+			Name:     "Test User",
+			Password: "12345678",
+		})
+		t.Cleanup(func() {
+			deps.cleanupUser(registeredUser.ID)
+		})
+		require.NoError(t, err)
+
+		err = deps.userService.RecordLastSeen(ctx, registeredUser.ID, &user.RecordLastSeenRequest{
+			LastSeenAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+
+		u, err := deps.userService.GetUser(ctx, registeredUser.ID)
+		require.NoError(t, err)
+		require.NotNil(t, u.LastSeenAt)
+		require.False(t, u.LastSeenAt.After(time.Now()))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := deps.userService.RecordLastSeen(ctx, 9999999999, &user.RecordLastSeenRequest{
+			LastSeenAt: time.Now(),
+		})
+		require.ErrorIs(t, err, apperrors.ErrNotFound)
+	})
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_email_key;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email
@@ -0,0 +1,8 @@
+-- Accounts created before email verification existed stay active.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('pending', 'active', 'suspended', 'deleted')),
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+ALTER TABLE users
+    ALTER COLUMN status SET DEFAULT 'pending'
@@ -0,0 +1,4 @@
+-- A changed email waits here until it is verified, the current email stays
+-- in use meanwhile.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS pending_email TEXT NOT NULL DEFAULT ''
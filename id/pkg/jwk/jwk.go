@@ -0,0 +1,212 @@
+/*
+Package jwk provides JSON Web Key (RFC 7517) encoding of the public keys used
+to sign tokens. RSA, ECDSA P-256 and Ed25519 keys are supported.
+
+The ID service publishes its keys as a JWKS, so other services can verify
+tokens without access to the key files.
+*/
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	KeyTypeRSA = "RSA"
+	KeyTypeEC  = "EC"
+	KeyTypeOKP = "OKP"
+
+	CurveP256    = "P-256"
+	CurveEd25519 = "Ed25519"
+
+	UseSig = "sig"
+
+	// p256CoordinateSize is the size of P-256 coordinates in bytes.
+	p256CoordinateSize = 32
+)
+
+// ErrUnsupportedKey is returned for keys of an unknown type or with missing
+// parameters.
+var ErrUnsupportedKey = errors.New("unsupported key")
+
+// Key is a public key in JWK format.
+type Key struct {
+	KeyType   string `json:"kty"`           // Key type, e.g. RSA
+	KeyID     string `json:"kid"`           // Key identifier matching the token 'kid' header
+	Use       string `json:"use,omitempty"` // Intended use, always 'sig'
+	Algorithm string `json:"alg,omitempty"` // Signing algorithm, e.g. RS256
+	N         string `json:"n,omitempty"`   // RSA modulus, base64url encoded
+	E         string `json:"e,omitempty"`   // RSA public exponent, base64url encoded
+	Curve     string `json:"crv,omitempty"` // EC or OKP curve, e.g. P-256
+	X         string `json:"x,omitempty"`   // EC x coordinate or OKP public key, base64url encoded
+	Y         string `json:"y,omitempty"`   // EC y coordinate, base64url encoded
+}
+
+// Set is a JSON Web Key Set.
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKey encodes an RSA, ECDSA P-256 or Ed25519 public key.
+func NewKey(keyID string, publicKey crypto.PublicKey) (Key, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return NewRSAKey(keyID, key), nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, key.Curve.Params().Name)
+		}
+		return Key{
+			KeyType:   KeyTypeEC,
+			KeyID:     keyID,
+			Use:       UseSig,
+			Algorithm: jwt.SigningMethodES256.Alg(),
+			Curve:     CurveP256,
+			X:         base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, p256CoordinateSize))),
+			Y:         base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, p256CoordinateSize))),
+		}, nil
+	case ed25519.PublicKey:
+		return Key{
+			KeyType:   KeyTypeOKP,
+			KeyID:     keyID,
+			Use:       UseSig,
+			Algorithm: jwt.SigningMethodEdDSA.Alg(),
+			Curve:     CurveEd25519,
+			X:         base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	default:
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, publicKey)
+	}
+}
+
+// Algorithm returns the signing algorithm used with the public key: RS256 for
+// RSA, ES256 for ECDSA P-256 and EdDSA for Ed25519 keys.
+func Algorithm(publicKey crypto.PublicKey) (string, error) {
+	key, err := NewKey("", publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return key.Algorithm, nil
+}
+
+// NewRSAKey encodes an RSA public key used for RS256 signatures.
+func NewRSAKey(keyID string, publicKey *rsa.PublicKey) Key {
+	return Key{
+		KeyType:   KeyTypeRSA,
+		KeyID:     keyID,
+		Use:       UseSig,
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// NewRSAKeyFromPEM encodes a PEM encoded RSA public key.
+func NewRSAKeyFromPEM(keyID string, publicKey []byte) (Key, error) {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(publicKey)
+	if err != nil {
+		return Key{}, fmt.Errorf("parse key %s: %w", keyID, err)
+	}
+
+	return NewRSAKey(keyID, key), nil
+}
+
+// PublicKey decodes the key into a form usable for signature verification.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case KeyTypeRSA:
+		return k.rsaPublicKey()
+	case KeyTypeEC:
+		return k.ecPublicKey()
+	case KeyTypeOKP:
+		return k.okpPublicKey()
+	default:
+		return nil, fmt.Errorf("%w: key type %q", ErrUnsupportedKey, k.KeyType)
+	}
+}
+
+func (k Key) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decode exponent: %w", err)
+	}
+	if len(n) == 0 || len(e) == 0 {
+		return nil, fmt.Errorf("%w: missing RSA parameters", ErrUnsupportedKey)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (k Key) ecPublicKey() (*ecdsa.PublicKey, error) {
+	if k.Curve != CurveP256 {
+		return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decode x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decode y: %w", err)
+	}
+	if len(x) != p256CoordinateSize || len(y) != p256CoordinateSize {
+		return nil, fmt.Errorf("%w: invalid P-256 coordinates", ErrUnsupportedKey)
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if _, err = key.ECDH(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	return key, nil
+}
+
+func (k Key) okpPublicKey() (ed25519.PublicKey, error) {
+	if k.Curve != CurveEd25519 {
+		return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decode x: %w", err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: invalid Ed25519 key size", ErrUnsupportedKey)
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// Key returns the key with the given identifier.
+func (s Set) Key(keyID string) (Key, bool) {
+	for _, key := range s.Keys {
+		if key.KeyID == keyID {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
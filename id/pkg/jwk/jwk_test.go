@@ -0,0 +1,113 @@
+package jwk_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwk"
+)
+
+func TestKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		set := jwk.Set{
+			Keys: []jwk.Key{jwk.NewRSAKey("auth", &privateKey.PublicKey)},
+		}
+
+		raw, err := json.Marshal(set)
+		require.NoError(t, err)
+
+		var decoded jwk.Set
+		require.NoError(t, json.Unmarshal(raw, &decoded))
+
+		key, ok := decoded.Key("auth")
+		require.True(t, ok)
+		require.Equal(t, "RSA", key.KeyType)
+		require.Equal(t, "RS256", key.Algorithm)
+		require.Equal(t, "sig", key.Use)
+
+		publicKey, err := key.PublicKey()
+		require.NoError(t, err)
+		require.True(t, privateKey.PublicKey.Equal(publicKey))
+	})
+
+	t.Run("from pem", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		require.NoError(t, err)
+
+		key, err := jwk.NewRSAKeyFromPEM("auth", pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: der,
+		}))
+		require.NoError(t, err)
+		require.Equal(t, jwk.NewRSAKey("auth", &privateKey.PublicKey), key)
+	})
+
+	t.Run("invalid pem", func(t *testing.T) {
+		_, err := jwk.NewRSAKeyFromPEM("auth", []byte("invalid"))
+		require.Error(t, err)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, ok := jwk.Set{}.Key("auth")
+		require.False(t, ok)
+	})
+
+	t.Run("ecdsa and ed25519 round trip", func(t *testing.T) {
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			publicKey crypto.PublicKey
+			algorithm string
+		}{
+			{publicKey: &ecdsaKey.PublicKey, algorithm: "ES256"},
+			{publicKey: ed25519Key, algorithm: "EdDSA"},
+		} {
+			key, err := jwk.NewKey("auth", tt.publicKey)
+			require.NoError(t, err)
+			require.Equal(t, tt.algorithm, key.Algorithm)
+
+			raw, err := json.Marshal(key)
+			require.NoError(t, err)
+
+			var decoded jwk.Key
+			require.NoError(t, json.Unmarshal(raw, &decoded))
+
+			publicKey, err := decoded.PublicKey()
+			require.NoError(t, err)
+			require.True(t, publicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.publicKey))
+
+			algorithm, err := jwk.Algorithm(publicKey)
+			require.NoError(t, err)
+			require.Equal(t, tt.algorithm, algorithm)
+		}
+	})
+
+	t.Run("unsupported curve", func(t *testing.T) {
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = jwk.NewKey("auth", &ecdsaKey.PublicKey)
+		require.ErrorIs(t, err, jwk.ErrUnsupportedKey)
+	})
+
+	t.Run("unsupported key type", func(t *testing.T) {
+		_, err := jwk.Key{KeyType: "oct"}.PublicKey()
+		require.ErrorIs(t, err, jwk.ErrUnsupportedKey)
+	})
+}
@@ -1,12 +1,16 @@
 /*
 Package jwt provides JWT (JSON Web Token) generation and validation functionality.
 
-It supports RSA-based signing and verification of tokens with custom claims.
+It supports RS256, ES256 (ECDSA P-256) and EdDSA (Ed25519) signing and
+verification of tokens with custom claims. Keys are parsed once and kept in a
+KeyRing, so several keys can be accepted for verification while one of them is
+used for signing.
 The package is built on top of github.com/golang-jwt/jwt/v5 library.
 */
 package jwt
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -14,58 +18,139 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Validation errors returned by ValidateToken. Every error returned by
+// ValidateToken wraps ErrTokenInvalid and, if known, a more specific reason.
+var (
+	ErrTokenInvalid          = errors.New("token is invalid")
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenUnknownKey       = errors.New("token is signed with an unknown key")
+)
+
 // Claims represents custom JWT claims along with standard registered claims.
-// It includes user login and name in addition to standard JWT fields.
+// It includes user login, name and session ID in addition to standard JWT fields.
 type Claims struct {
-	Login string `json:"login"` // User login identifier
-	Name  string `json:"name"`  // User display name
+	Login     string `json:"login"` // User login identifier
+	Name      string `json:"name"`  // User display name
+	SessionID string `json:"sid"`   // Login session the token belongs to
 	jwt.RegisteredClaims
 }
 
 // JWT provides methods for token generation, validation and inspection.
-// It requires RSA private and public keys for cryptographic operations.
 type JWT struct {
-	privateKey []byte // RSA private key in PEM format
-	publicKey  []byte // RSA public key in PEM format
+	keys     *KeyRing // Signing and verification keys
+	keyID    string   // Key identifier of the key pair passed to NewJWT
+	issuer   string   // Token issuer ('iss' claim)
+	audience []string // Token audience ('aud' claim)
+}
+
+// Option configures optional JWT settings.
+type Option func(*JWT)
+
+// WithKeyID sets the identifier of the key pair passed to NewJWT. It is put
+// into the 'kid' header of generated tokens, so verifiers can pick the
+// matching key from a JWKS.
+func WithKeyID(keyID string) Option {
+	return func(j *JWT) {
+		j.keyID = keyID
+	}
+}
+
+// WithIssuer sets the 'iss' claim of generated tokens and requires it when
+// validating tokens.
+func WithIssuer(issuer string) Option {
+	return func(j *JWT) {
+		j.issuer = issuer
+	}
+}
+
+// WithAudience sets the 'aud' claim of generated tokens. Validated tokens
+// must be intended for the first audience.
+func WithAudience(audience ...string) Option {
+	return func(j *JWT) {
+		j.audience = audience
+	}
 }
 
-// NewJWT creates a new JWT instance with provided RSA keys.
-// Both privateKey and publicKey should be in PEM format.
-func NewJWT(privateKey, publicKey []byte) *JWT {
-	return &JWT{
-		privateKey: privateKey,
-		publicKey:  publicKey,
+// NewJWT creates a new JWT instance with a single key pair in PEM format.
+// The private key may be nil for an instance that only validates tokens.
+//
+// Returns:
+//   - JWT instance
+//   - error wrapping ErrInvalidKey if the keys cannot be parsed or do not match
+func NewJWT(privateKey, publicKey []byte, opts ...Option) (*JWT, error) {
+	j := newJWT(opts)
+
+	keys := &KeyRing{}
+	err := keys.replace(j.keyID, false, []Key{{
+		ID:         j.keyID,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	j.keys = keys
+
+	return j, nil
+}
+
+// NewJWTWithKeyRing creates a new JWT instance using the keys of the ring.
+// Later changes to the ring apply to the instance.
+func NewJWTWithKeyRing(keys *KeyRing, opts ...Option) *JWT {
+	j := newJWT(opts)
+	j.keys = keys
+
+	return j
+}
+
+func newJWT(opts []Option) *JWT {
+	j := &JWT{}
+	for _, opt := range opts {
+		opt(j)
 	}
+
+	return j
 }
 
 // GenerateToken creates a new JWT token for the specified user.
 //
 // Parameters:
 //   - userID: unique identifier of the user (will be set as 'sub' claim)
+//   - sessionID: identifier of the login session (will be set as 'sid' claim)
 //   - login: user login identifier
 //   - name: user display name
 //   - expiresIn: duration until token expiration
 //
 // Returns:
 //   - signed JWT token string
-//   - error if key parsing or signing fails
-func (j *JWT) GenerateToken(userID int64, login, name string, expiresIn time.Duration) (string, error) {
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(j.privateKey)
-	if err != nil {
-		return "", fmt.Errorf("generate: parse key: %w", err)
+//   - error if there is no signing key or signing fails
+func (j *JWT) GenerateToken(userID int64, sessionID, login, name string, expiresIn time.Duration) (string, error) {
+	key := j.keys.signingKey()
+	if key == nil || key.privateKey == nil {
+		return "", fmt.Errorf("generate: %w", ErrNoSigningKey)
 	}
 
 	claims := Claims{
-		Login: login,
-		Name:  name,
+		Login:     login,
+		Name:      name,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.issuer,
 			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  j.audience,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	t := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		t.Header["kid"] = key.id
+	}
+
+	token, err := t.SignedString(key.privateKey)
 	if err != nil {
 		return "", fmt.Errorf("create: sign token: %w", err)
 	}
@@ -74,33 +159,90 @@ func (j *JWT) GenerateToken(userID int64, login, name string, expiresIn time.Dur
 }
 
 // ValidateToken checks if the token is properly signed and returns its claims.
+// The verification key is picked by the 'kid' header. Tokens without one are
+// checked against the signing key. A token is only accepted with the
+// algorithm of its key.
 //
 // Parameters:
 //   - token: JWT token string to validate
 //
 // Returns:
-//   - bool indicating if token is valid
-//   - Claims struct populated with token claims (only valid if first return value is true)
-func (j *JWT) ValidateToken(token string) (bool, Claims) {
-	key, err := jwt.ParseRSAPublicKeyFromPEM(j.publicKey)
-	if err != nil {
-		return false, Claims{}
+//   - claims of the token, also returned along with an error if the signature
+//     is valid but the claims are not, e.g. when the token is expired
+//   - error wrapping ErrTokenInvalid and the reason, nil if the token is valid
+func (j *JWT) ValidateToken(token string) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, j.verificationKey, j.parserOptions()...)
+	if err == nil {
+		return claims, nil
+	}
+
+	reason := validationReason(err)
+	if reason != ErrTokenInvalid {
+		err = fmt.Errorf("%w: %w: %w", ErrTokenInvalid, reason, err)
+	} else {
+		err = fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	}
+
+	// Claims of tokens with an unverified signature must not be trusted.
+	switch reason {
+	case ErrTokenMalformed, ErrTokenSignatureInvalid, ErrTokenUnknownKey:
+		return nil, err
+	default:
+		return claims, err
 	}
+}
 
-	claims := Claims{}
+func (j *JWT) verificationKey(token *jwt.Token) (interface{}, error) {
+	keyID, hasKeyID := token.Header["kid"].(string)
 
-	t, err := jwt.ParseWithClaims(token, &claims, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected method: %s", jwtToken.Header["alg"])
+	key := j.keys.signingKey()
+	if hasKeyID {
+		var ok bool
+		key, ok = j.keys.parsedKey(keyID)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTokenUnknownKey, keyID)
 		}
+	}
+	if key == nil {
+		return nil, ErrTokenUnknownKey
+	}
 
-		return key, nil
-	})
-	if err != nil || !t.Valid {
-		return false, Claims{}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected method: %s", token.Method.Alg())
 	}
 
-	return true, claims
+	return key.publicKey, nil
+}
+
+func (j *JWT) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}),
+	}
+	if j.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.issuer))
+	}
+	if len(j.audience) > 0 {
+		opts = append(opts, jwt.WithAudience(j.audience[0]))
+	}
+
+	return opts
+}
+
+func validationReason(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, ErrTokenUnknownKey):
+		return ErrTokenUnknownKey
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignatureInvalid
+	default:
+		return ErrTokenInvalid
+	}
 }
 
 // ExtractUserID retrieves user ID from the token's subject claim.
@@ -112,9 +254,9 @@ func (j *JWT) ValidateToken(token string) (bool, Claims) {
 //   - user ID as string (from 'sub' claim)
 //   - error if token is invalid or doesn't contain subject claim
 func (j *JWT) ExtractUserID(token string) (string, error) {
-	valid, claims := j.ValidateToken(token)
-	if !valid {
-		return "", fmt.Errorf("invalid token")
+	claims, err := j.ValidateToken(token)
+	if err != nil {
+		return "", err
 	}
 	if claims.Subject == "" {
 		return "", fmt.Errorf("token does not contain subject (sub)")
@@ -131,10 +273,6 @@ func (j *JWT) ExtractUserID(token string) (string, error) {
 // Returns:
 //   - true if token is expired or invalid, false if still valid
 func (j *JWT) IsTokenExpired(token string) bool {
-	_, claims := j.ValidateToken(token)
-	if claims.ExpiresAt == nil {
-		return true
-	}
-
-	return claims.ExpiresAt.Before(time.Now())
+	_, err := j.ValidateToken(token)
+	return err != nil
 }
@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrNoSigningKey is returned when the designated signing key is missing from
+// the ring or has no private key.
+var ErrNoSigningKey = errors.New("no signing key")
+
+// Key is a key pair identified by its 'kid'. Keys that are only accepted for
+// verification have no private key.
+type Key struct {
+	ID         string // Key identifier, stamped into the 'kid' header
+	PrivateKey []byte // RSA, ECDSA P-256 or Ed25519 private key in PEM format, optional
+	PublicKey  []byte // Matching public key in PEM format
+}
+
+// PublicKey is a parsed verification key of the ring.
+type PublicKey struct {
+	ID        string           // Key identifier
+	Algorithm string           // Signing algorithm of the key
+	Key       crypto.PublicKey // *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+}
+
+// KeyRing holds every key accepted for verification and the one used for
+// signing. Keys are parsed when they are added, and can be replaced at
+// runtime, which lets tokens signed with a retired key stay valid while its
+// public key is still in the ring. It is safe for concurrent use.
+type KeyRing struct {
+	mu           sync.RWMutex
+	signingKeyID string
+	keys         map[string]Key
+	parsed       map[string]*parsedKey
+}
+
+// NewKeyRing creates a key ring signing with the key signingKeyID.
+func NewKeyRing(signingKeyID string, keys ...Key) (*KeyRing, error) {
+	r := &KeyRing{}
+	if err := r.Replace(signingKeyID, keys...); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Replace atomically swaps the keys of the ring. On error the ring is left
+// unchanged.
+func (r *KeyRing) Replace(signingKeyID string, keys ...Key) error {
+	return r.replace(signingKeyID, true, keys)
+}
+
+func (r *KeyRing) replace(signingKeyID string, requireSigningKey bool, keys []Key) error {
+	byID := make(map[string]Key, len(keys))
+	parsed := make(map[string]*parsedKey, len(keys))
+	for _, key := range keys {
+		p, err := parseKey(key)
+		if err != nil {
+			return err
+		}
+
+		byID[key.ID] = key
+		parsed[key.ID] = p
+	}
+
+	signingKey, ok := parsed[signingKeyID]
+	if requireSigningKey && (!ok || signingKey.privateKey == nil) {
+		return fmt.Errorf("%w: %q", ErrNoSigningKey, signingKeyID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signingKeyID = signingKeyID
+	r.keys = byID
+	r.parsed = parsed
+
+	return nil
+}
+
+// SigningKey returns the key new tokens are signed with.
+func (r *KeyRing) SigningKey() Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.keys[r.signingKeyID]
+}
+
+// Key returns the key with the given identifier.
+func (r *KeyRing) Key(keyID string) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[keyID]
+	return key, ok
+}
+
+// Keys returns every key of the ring ordered by identifier.
+func (r *KeyRing) Keys() []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
+
+// PublicKeys returns the parsed public keys of the ring ordered by
+// identifier.
+func (r *KeyRing) PublicKeys() []PublicKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]PublicKey, 0, len(r.parsed))
+	for _, key := range r.parsed {
+		keys = append(keys, PublicKey{
+			ID:        key.id,
+			Algorithm: key.method.Alg(),
+			Key:       key.publicKey,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
+
+func (r *KeyRing) signingKey() *parsedKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.parsed[r.signingKeyID]
+}
+
+func (r *KeyRing) parsedKey(keyID string) (*parsedKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.parsed[keyID]
+	return key, ok
+}
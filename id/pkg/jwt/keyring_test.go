@@ -0,0 +1,74 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+)
+
+func TestKeyRing(t *testing.T) {
+	oldPrivateKey, oldPublicKey := generateTestRSAKeys(t)
+	newPrivateKey, newPublicKey := generateTestRSAKeys(t)
+
+	oldKey := jwt.Key{ID: "old", PrivateKey: oldPrivateKey, PublicKey: oldPublicKey}
+	newKey := jwt.Key{ID: "new", PrivateKey: newPrivateKey, PublicKey: newPublicKey}
+
+	t.Run("signing key must have private key", func(t *testing.T) {
+		_, err := jwt.NewKeyRing("old", jwt.Key{ID: "old", PublicKey: oldPublicKey})
+		require.ErrorIs(t, err, jwt.ErrNoSigningKey)
+
+		_, err = jwt.NewKeyRing("missing", oldKey)
+		require.ErrorIs(t, err, jwt.ErrNoSigningKey)
+	})
+
+	t.Run("rotation keeps old tokens valid", func(t *testing.T) {
+		ring, err := jwt.NewKeyRing("old", oldKey)
+		require.NoError(t, err)
+		j := jwt.NewJWTWithKeyRing(ring)
+
+		oldToken, err := j.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, ring.Replace("new", oldKey, newKey))
+		require.Equal(t, "new", ring.SigningKey().ID)
+		require.Equal(t, []jwt.Key{newKey, oldKey}, ring.Keys())
+
+		newToken, err := j.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		_, err = j.ValidateToken(oldToken)
+		require.NoError(t, err)
+		_, err = j.ValidateToken(newToken)
+		require.NoError(t, err)
+
+		// Retiring the old public key invalidates the tokens it signed.
+		require.NoError(t, ring.Replace("new", newKey))
+
+		_, err = j.ValidateToken(oldToken)
+		require.ErrorIs(t, err, jwt.ErrTokenUnknownKey)
+		_, err = j.ValidateToken(newToken)
+		require.NoError(t, err)
+	})
+
+	t.Run("failed replace keeps keys", func(t *testing.T) {
+		ring, err := jwt.NewKeyRing("old", oldKey)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, ring.Replace("new", oldKey), jwt.ErrNoSigningKey)
+		require.Equal(t, "old", ring.SigningKey().ID)
+	})
+
+	t.Run("token without kid is checked against signing key", func(t *testing.T) {
+		token, err := newTestJWT(t, oldPrivateKey, oldPublicKey).GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		ring, err := jwt.NewKeyRing("old", oldKey)
+		require.NoError(t, err)
+
+		_, err = jwt.NewJWTWithKeyRing(ring).ValidateToken(token)
+		require.NoError(t, err)
+	})
+}
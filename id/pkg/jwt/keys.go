@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms. The algorithm of a key follows from its type:
+// RSA keys sign with RS256, ECDSA P-256 keys with ES256 and Ed25519 keys with
+// EdDSA.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// ErrInvalidKey is returned for keys that cannot be parsed or are of an
+// unsupported type.
+var ErrInvalidKey = errors.New("invalid key")
+
+// parsedKey is a key of the ring ready for signing and verification.
+type parsedKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey // nil for verification-only keys
+	publicKey  crypto.PublicKey
+}
+
+func parseKey(key Key) (*parsedKey, error) {
+	publicKey, err := parsePublicKey(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidKey, key.ID, err)
+	}
+
+	method, err := signingMethod(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidKey, key.ID, err)
+	}
+
+	parsed := &parsedKey{
+		id:        key.ID,
+		method:    method,
+		publicKey: publicKey,
+	}
+
+	if len(key.PrivateKey) == 0 {
+		return parsed, nil
+	}
+
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidKey, key.ID, err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok || !publicKeysEqual(signer.Public(), publicKey) {
+		return nil, fmt.Errorf("%w %q: private key does not match public key", ErrInvalidKey, key.ID)
+	}
+	parsed.privateKey = privateKey
+
+	return parsed, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unknown private key format")
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+
+	return nil, errors.New("unknown public key format")
+}
+
+func signingMethod(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+		return jwt.SigningMethodES256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
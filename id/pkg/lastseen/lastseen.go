@@ -0,0 +1,84 @@
+package lastseen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+)
+
+// recordRequest is the body of PUT /internal/users/{id}/last-seen.
+type recordRequest struct {
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// errorResponse is the part of an ID service error response the client reads.
+type errorResponse struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Client reports to the ID service when users were last connected to another
+// service, so their profile can show it.
+type Client struct {
+	serviceURL   string
+	serviceToken string
+	httpClient   *http.Client
+}
+
+type Config struct {
+	ServiceURL   string
+	ServiceToken string // Shared token of the ID service internal API
+}
+
+func NewClient(conf Config) *Client {
+	return &Client{
+		serviceURL:   conf.ServiceURL,
+		serviceToken: conf.ServiceToken,
+		httpClient: &http.Client{
+			Transport: tracing.Transport(nil),
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Record sets the last seen time of the user unless a later one is already
+// recorded.
+func (c *Client) Record(ctx context.Context, userID int64, seenAt time.Time) error {
+	body, err := json.Marshal(recordRequest{
+		LastSeenAt: seenAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/internal/users/%d/last-seen", c.serviceURL, userID)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.serviceToken)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apiRes errorResponse
+	if err = json.NewDecoder(resp.Body).Decode(&apiRes); err != nil || apiRes.Error == nil {
+		return fmt.Errorf("recording last seen: unexpected status %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("recording last seen: %s", apiRes.Error.Message)
+}
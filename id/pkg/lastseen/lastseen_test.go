@@ -0,0 +1,59 @@
+package lastseen_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/lastseen"
+
+	"github.com/maximegorov13/chat-app/id/internal/apperrors"
+	"github.com/maximegorov13/chat-app/id/internal/res"
+	"github.com/maximegorov13/chat-app/id/internal/user"
+)
+
+func TestClient_Record(t *testing.T) {
+	seenAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("recorded", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPut, r.Method)
+			require.Equal(t, "/internal/users/42/last-seen", r.URL.Path)
+			require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+			var body user.RecordLastSeenRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.True(t, seenAt.Equal(body.LastSeenAt))
+
+			res.JSON[any](w, http.StatusOK, nil, nil)
+		}))
+		defer ts.Close()
+
+		client := lastseen.NewClient(lastseen.Config{
+			ServiceURL:   ts.URL,
+			ServiceToken: "secret",
+		})
+
+		require.NoError(t, client.Record(context.Background(), 42, seenAt))
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res.Error(w, apperrors.ErrUnauthorized)
+		}))
+		defer ts.Close()
+
+		client := lastseen.NewClient(lastseen.Config{
+			ServiceURL:   ts.URL,
+			ServiceToken: "wrong",
+		})
+
+		err := client.Record(context.Background(), 42, seenAt)
+		require.ErrorContains(t, err, "unauthorized")
+	})
+}
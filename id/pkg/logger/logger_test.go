@@ -0,0 +1,50 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+
+	log, err := logger.New(&buf, "warn", logger.FormatJSON)
+	require.NoError(t, err)
+
+	ctx := logger.WithAttrs(context.Background(), slog.String("request_id", "abc"))
+	ctx = logger.WithAttrs(ctx, slog.Int("attempt", 2))
+
+	log.InfoContext(ctx, "skipped")
+	require.Zero(t, buf.Len())
+
+	log.WarnContext(ctx, "logged", "key", "value")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "logged", record["msg"])
+	require.Equal(t, "value", record["key"])
+	require.Equal(t, "abc", record["request_id"])
+	require.Equal(t, float64(2), record["attempt"])
+
+	buf.Reset()
+	log.With("component", "test").WarnContext(ctx, "with attrs")
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "test", record["component"])
+	require.Equal(t, "abc", record["request_id"])
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := logger.New(&bytes.Buffer{}, "verbose", logger.FormatText)
+	require.Error(t, err)
+
+	_, err = logger.New(&bytes.Buffer{}, "info", "xml")
+	require.Error(t, err)
+}
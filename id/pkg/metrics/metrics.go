@@ -0,0 +1,139 @@
+/*
+Package metrics provides Prometheus collectors shared by the services: HTTP
+request metrics per route pattern and storage call latency.
+*/
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests no route pattern matched, so unknown paths
+// do not create new series.
+const unmatchedRoute = "unmatched"
+
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// NewHTTPMetrics creates and registers the HTTP collectors with namespace as
+// the metric name prefix.
+func NewHTTPMetrics(reg prometheus.Registerer, namespace string) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.inFlight)
+
+	return m
+}
+
+// Middleware records the requests served by mux. It has to wrap the
+// http.ServeMux directly, since the mux sets the matched pattern on the
+// request it is given.
+func (m *HTTPMetrics) Middleware(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		mux.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		m.duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder records the response status. It supports hijacking, so
+// WebSocket upgrades pass through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// StorageMetrics records the latency of Postgres and Redis calls made by
+// repositories.
+type StorageMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewStorageMetrics(reg prometheus.Registerer, namespace string) *StorageMetrics {
+	m := &StorageMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_call_duration_seconds",
+			Help:      "Latency of repository calls by store, repository and operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"store", "repository", "operation"}),
+	}
+	reg.MustRegister(m.duration)
+
+	return m
+}
+
+// Observe records a call that started at start. It is meant to be deferred
+// at the top of repository methods:
+//
+//	defer metrics.Storage.Observe("postgres", "user", "FindByID", time.Now())
+func (m *StorageMetrics) Observe(store, repository, operation string, start time.Time) {
+	m.duration.WithLabelValues(store, repository, operation).Observe(time.Since(start).Seconds())
+}
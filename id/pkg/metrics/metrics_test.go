@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/metrics"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.NewHTTPMetrics(reg, "test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	expected := `
+# HELP test_http_requests_total HTTP requests by method, route pattern and status code.
+# TYPE test_http_requests_total counter
+test_http_requests_total{method="GET",route="GET /users/{id}",status="404"} 2
+test_http_requests_total{method="GET",route="unmatched",status="404"} 1
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "test_http_requests_total")
+	require.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(reg, "test_http_request_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestStorageMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.NewStorageMetrics(reg, "test")
+
+	m.Observe("postgres", "user", "FindByID", time.Now().Add(-time.Millisecond))
+	m.Observe("redis", "session", "FindByID", time.Now())
+
+	count, err := testutil.GatherAndCount(reg, "test_storage_call_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
@@ -0,0 +1,129 @@
+/*
+Package ratelimit limits requests with token buckets.
+
+A bucket holds up to Limit.Burst tokens and is refilled evenly, so a full
+bucket is restored after Limit.Period. Every request takes a token and is
+rejected if the bucket is empty. Buckets are kept in memory for a single
+instance or in Redis to be shared between instances.
+*/
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled over Period.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses limits in the "<burst>/<period>" format, e.g. "10/1m".
+func ParseLimit(s string) (Limit, error) {
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: expected <burst>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: burst must be a positive integer", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d < time.Millisecond {
+		return Limit{}, fmt.Errorf("invalid limit %q: period must be at least 1ms", s)
+	}
+
+	return Limit{
+		Burst:  n,
+		Period: d,
+	}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// perMilli returns how many tokens are added per millisecond.
+func (l Limit) perMilli() float64 {
+	return float64(l.Burst) / float64(l.Period.Milliseconds())
+}
+
+// Policy is a limit applied to a group of requests. Requests of the same
+// subject under the same policy name share a bucket.
+type Policy struct {
+	Name  string
+	Limit Limit
+}
+
+// Key returns the bucket key of the subject under the policy.
+func (p Policy) Key(subject string) string {
+	return p.Name + ":" + subject
+}
+
+// Result is the state of a bucket after a request.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Until the next token, zero if allowed
+	ResetAfter time.Duration // Until the bucket is full again
+}
+
+// Limiter takes a token from the bucket with key. Implementations are safe
+// for concurrent use.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// newResult describes a bucket of limit with tokens left.
+func newResult(limit Limit, allowed bool, tokens float64) *Result {
+	result := &Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: millis((float64(limit.Burst) - tokens) / limit.perMilli()),
+	}
+	if !allowed {
+		result.RetryAfter = millis((1 - tokens) / limit.perMilli())
+	}
+
+	return result
+}
+
+// refill returns the tokens in a bucket of limit that had tokens elapsed ago.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	if elapsed > 0 {
+		tokens += float64(elapsed.Milliseconds()) * limit.perMilli()
+	}
+
+	return min(tokens, float64(limit.Burst))
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
+
+// SetHeaders sets the RateLimit-* headers of the IETF draft "RateLimit header
+// fields for HTTP" and, for rejected requests, Retry-After. Durations are
+// rounded up to whole seconds.
+func SetHeaders(h http.Header, policy Policy, result *Result) {
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit.Burst, seconds(policy.Limit.Period)))
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(seconds(result.ResetAfter), 10))
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(seconds(result.RetryAfter), 10))
+	}
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
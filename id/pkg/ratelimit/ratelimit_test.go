@@ -0,0 +1,132 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("10/1m")
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.Limit{Burst: 10, Period: time.Minute}, limit)
+	require.Equal(t, "10/1m0s", limit.String())
+
+	for _, s := range []string{"", "10", "0/1m", "-1/1m", "x/1m", "10/0s", "10/x", "10/1us"} {
+		_, err = ratelimit.ParseLimit(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("burst then reject", func(t *testing.T) {
+		limiter := ratelimit.NewMemoryLimiter()
+		limit := ratelimit.Limit{Burst: 2, Period: time.Hour}
+
+		result, err := limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 2, result.Limit)
+		require.Equal(t, 1, result.Remaining)
+
+		result, err = limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 0, result.Remaining)
+
+		result, err = limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.False(t, result.Allowed)
+		require.Equal(t, 0, result.Remaining)
+		require.InDelta(t, 30*time.Minute, result.RetryAfter, float64(time.Second))
+		require.InDelta(t, time.Hour, result.ResetAfter, float64(time.Second))
+
+		// Other keys have their own buckets.
+		result, err = limiter.Allow(ctx, "b", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		limiter := ratelimit.NewMemoryLimiter()
+		limit := ratelimit.Limit{Burst: 1, Period: 50 * time.Millisecond}
+
+		result, err := limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+
+		result, err = limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.False(t, result.Allowed)
+
+		time.Sleep(result.RetryAfter)
+
+		result, err = limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	})
+}
+
+type evalerStub struct {
+	reply any
+	keys  []string
+	args  []any
+}
+
+func (e *evalerStub) Eval(_ context.Context, _ string, keys []string, args ...any) (any, error) {
+	e.keys = keys
+	e.args = args
+	return e.reply, nil
+}
+
+func TestRedisLimiter(t *testing.T) {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Burst: 10, Period: 10 * time.Second}
+
+	evaler := &evalerStub{
+		reply: []any{int64(0), "0.5"},
+	}
+	limiter := ratelimit.NewRedisLimiter(evaler)
+
+	result, err := limiter.Allow(ctx, "login:ip:127.0.0.1", limit)
+	require.NoError(t, err)
+	require.Equal(t, []string{"rate_limit:login:ip:127.0.0.1"}, evaler.keys)
+	require.Equal(t, []any{10, int64(10000)}, evaler.args)
+	require.False(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	require.Equal(t, 9500*time.Millisecond, result.ResetAfter)
+
+	evaler.reply = []any{"unexpected"}
+	_, err = limiter.Allow(ctx, "key", limit)
+	require.Error(t, err)
+}
+
+func TestSetHeaders(t *testing.T) {
+	policy := ratelimit.Policy{
+		Name:  "messages",
+		Limit: ratelimit.Limit{Burst: 30, Period: time.Minute},
+	}
+
+	h := http.Header{}
+	ratelimit.SetHeaders(h, policy, &ratelimit.Result{
+		Allowed:    false,
+		Limit:      30,
+		Remaining:  0,
+		RetryAfter: 1500 * time.Millisecond,
+		ResetAfter: 59 * time.Second,
+	})
+
+	require.Equal(t, "30;w=60", h.Get("RateLimit-Policy"))
+	require.Equal(t, "30", h.Get("RateLimit-Limit"))
+	require.Equal(t, "0", h.Get("RateLimit-Remaining"))
+	require.Equal(t, "59", h.Get("RateLimit-Reset"))
+	require.Equal(t, "2", h.Get("Retry-After"))
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// keyPrefix namespaces bucket keys in Redis.
+const keyPrefix = "rate_limit:"
+
+// allowScript takes a token from the bucket hash in KEYS[1]. ARGV holds the
+// burst and the period in milliseconds. The Redis clock is used, so instances
+// with skewed clocks share buckets correctly. It returns whether the request
+// is allowed and the tokens left, as a string to keep the fraction.
+const allowScript = `
+local burst = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "at")
+local tokens = tonumber(state[1]) or burst
+local at = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - at) * burst / period)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "at", now)
+redis.call("PEXPIRE", KEYS[1], period)
+
+return {allowed, tostring(tokens)}
+`
+
+// Evaler runs Lua scripts on Redis.
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// RedisLimiter keeps buckets in Redis, so limits are shared by all instances.
+type RedisLimiter struct {
+	redis Evaler
+}
+
+func NewRedisLimiter(redis Evaler) *RedisLimiter {
+	return &RedisLimiter{
+		redis: redis,
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	reply, err := l.redis.Eval(ctx, allowScript, []string{keyPrefix + key}, limit.Burst, limit.Period.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	tokensStr, ok := values[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rate limit reply: %w", err)
+	}
+
+	return newResult(limit, allowed == 1, tokens), nil
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+
 	"github.com/maximegorov13/chat-app/id/internal/auth"
 	"github.com/maximegorov13/chat-app/id/internal/res"
 )
@@ -23,7 +25,9 @@ type Config struct {
 func NewClient(conf Config) *Client {
 	return &Client{
 		serviceURL: conf.ServiceURL,
-		httpClient: &http.Client{},
+		httpClient: &http.Client{
+			Transport: tracing.Transport(nil),
+		},
 	}
 }
 
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/maximegorov13/chat-app/id/pkg/logger"
+)
+
+// Middleware starts a server span for the requests served by mux, continuing
+// the trace of the caller if the request carries one. The trace ID is added
+// to the records logged with the request context.
+//
+// Like the metrics middleware it has to wrap the http.ServeMux, or handlers
+// passing the request on unchanged, so the span can be named after the
+// matched route pattern.
+func Middleware(mux http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if sc := span.SpanContext(); sc.IsValid() {
+			r = r.WithContext(logger.WithAttrs(r.Context(), slog.String("trace_id", sc.TraceID().String())))
+		}
+
+		mux.ServeHTTP(w, r)
+
+		if r.Pattern == "" {
+			return
+		}
+
+		// Patterns may start with a method, e.g. "GET /api/users/{id}"
+		route := r.Pattern
+		if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+			route = path
+		}
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	})
+
+	return otelhttp.NewHandler(named, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// Transport wraps base, http.DefaultTransport if nil, so outgoing requests
+// are traced and carry the trace context of their request context.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return otelhttp.NewTransport(base)
+}
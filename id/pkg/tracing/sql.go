@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// OpenPostgres opens a database with the "postgres" driver, which the caller
+// registers, and starts a client span for every query and transaction. The
+// spans record the SQL text with its placeholders but not the arguments.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	return otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanNameFormatter(sqlSpanName),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+		}),
+	)
+}
+
+// sqlSpanName names query spans after the SQL command, e.g. "SELECT", and
+// other calls after the database/sql method.
+func sqlSpanName(_ context.Context, method otelsql.Method, query string) string {
+	if command, _, _ := strings.Cut(strings.TrimSpace(query), " "); command != "" {
+		return strings.ToUpper(command)
+	}
+
+	return string(method)
+}
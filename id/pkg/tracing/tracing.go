@@ -0,0 +1,115 @@
+/*
+Package tracing configures OpenTelemetry tracing for the services: the tracer
+provider and its exporter, W3C trace context propagation, and spans for
+inbound HTTP requests and Redis commands.
+*/
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+const instrumentationName = "github.com/maximegorov13/chat-app/id/pkg/tracing"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	ServiceName string
+	Exporter    string  // none, stdout or otlp
+	FilePath    string  // File the stdout exporter appends to, stdout if empty
+	OTLPURL     string  // OTLP/HTTP endpoint of the collector, e.g. http://localhost:4318
+	SampleRatio float64 // Share of new traces that are sampled, from 0 to 1
+}
+
+// Setup installs the global tracer provider and propagator and returns a
+// function flushing the pending spans and releasing the exporter.
+//
+// Trace context is propagated with every exporter. With ExporterNone no spans
+// are recorded, but the trace of an incoming request is still passed on to
+// outgoing ones.
+func Setup(ctx context.Context, conf Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if conf.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(conf.ServiceName),
+	))
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create resource: %w", err), closeOutput())
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closeOutput())
+	}, nil
+}
+
+// newExporter creates the exporter selected by the config along with a
+// function closing the file it writes to.
+func newExporter(ctx context.Context, conf Config) (sdktrace.SpanExporter, func() error, error) {
+	noop := func() error { return nil }
+
+	switch conf.Exporter {
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		closeOutput := noop
+
+		if conf.FilePath != "" {
+			f, err := os.OpenFile(conf.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			w, closeOutput = f, f.Close
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("failed to create stdout exporter: %w", err), closeOutput())
+		}
+
+		return exporter, closeOutput, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(conf.OTLPURL))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+
+		return exporter, noop, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", conf.Exporter)
+	}
+}
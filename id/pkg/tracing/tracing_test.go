@@ -0,0 +1,69 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+)
+
+func TestPropagation(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "test",
+		Exporter:    tracing.ExporterNone,
+	})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(tracing.Middleware(mux))
+	defer server.Close()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/users/1", nil)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	var serverSpan *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			serverSpan = &spans[i]
+		}
+	}
+	require.NotNil(t, serverSpan)
+	require.Equal(t, "GET /users/{id}", serverSpan.Name)
+	require.Equal(t, parent.SpanContext().TraceID(), serverSpan.SpanContext.TraceID())
+	require.Contains(t, serverSpan.Attributes, attribute.String("http.route", "/users/{id}"))
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "test",
+		Exporter:    "jaeger",
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,314 @@
+/*
+Package verifier validates access tokens issued by the ID service locally.
+
+The verifier fetches the public keys from the ID service JWKS endpoint, caches
+them and checks token signature, expiry, issuer and audience without a call to
+the ID service per request. It does not see tokens revoked by logout or
+session revocation, services check those with the ID service separately, and
+access tokens should be short-lived.
+*/
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwk"
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/tracing"
+)
+
+const (
+	// DefaultCacheTTL is how long fetched keys are used before the JWKS is
+	// fetched again.
+	DefaultCacheTTL = 5 * time.Minute
+	// minRefreshInterval limits fetching the JWKS, for tokens signed with an
+	// unknown key and while the ID service is unreachable.
+	minRefreshInterval = 10 * time.Second
+)
+
+var (
+	// ErrInvalidToken is returned for malformed, expired or wrongly signed
+	// tokens and for tokens of another issuer or audience.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrKeysUnavailable is returned when the JWKS cannot be fetched.
+	ErrKeysUnavailable = errors.New("keys unavailable")
+)
+
+// ErrorHandler writes the response for a request that failed verification.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+type Config struct {
+	JWKSURL      string        // URL of the ID service JWKS endpoint
+	Issuer       string        // Required 'iss' claim, not checked if empty
+	Audience     string        // Required 'aud' claim, not checked if empty
+	CacheTTL     time.Duration // Defaults to DefaultCacheTTL
+	HTTPClient   *http.Client  // Defaults to a traced client with a 10 second timeout
+	ErrorHandler ErrorHandler  // Defaults to a plain text 401 or 500 response
+}
+
+// Verifier validates tokens against the cached JWKS. It is safe for
+// concurrent use.
+type Verifier struct {
+	conf Config
+
+	mu          sync.Mutex
+	keys        map[string]publicKey
+	fetchedAt   time.Time     // Last successful fetch
+	attemptedAt time.Time     // Last fetch, successful or not
+	fetchErr    error         // Error of the last fetch
+	refreshing  chan struct{} // Closed when the fetch in flight is done
+}
+
+// publicKey is a verification key along with the only algorithm accepted for
+// it, which prevents algorithm confusion.
+type publicKey struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+func NewVerifier(conf Config) *Verifier {
+	if conf.CacheTTL <= 0 {
+		conf.CacheTTL = DefaultCacheTTL
+	}
+	if conf.HTTPClient == nil {
+		conf.HTTPClient = &http.Client{
+			Transport: tracing.Transport(nil),
+			Timeout:   10 * time.Second,
+		}
+	}
+	if conf.ErrorHandler == nil {
+		conf.ErrorHandler = defaultErrorHandler
+	}
+
+	return &Verifier{
+		conf: conf,
+	}
+}
+
+// Verify checks the token and returns its claims.
+//
+// Returns:
+//   - claims of a valid token
+//   - error wrapping ErrInvalidToken or ErrKeysUnavailable otherwise
+func (v *Verifier) Verify(ctx context.Context, token string) (*jwt.Claims, error) {
+	opts := []gojwt.ParserOption{
+		gojwt.WithValidMethods([]string{jwt.AlgorithmRS256, jwt.AlgorithmES256, jwt.AlgorithmEdDSA}),
+		gojwt.WithExpirationRequired(),
+	}
+	if v.conf.Issuer != "" {
+		opts = append(opts, gojwt.WithIssuer(v.conf.Issuer))
+	}
+	if v.conf.Audience != "" {
+		opts = append(opts, gojwt.WithAudience(v.conf.Audience))
+	}
+
+	claims := &jwt.Claims{}
+
+	_, err := gojwt.ParseWithClaims(token, claims, func(t *gojwt.Token) (interface{}, error) {
+		keyID, _ := t.Header["kid"].(string)
+
+		key, err := v.key(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.algorithm {
+			return nil, fmt.Errorf("unexpected method: %s", t.Method.Alg())
+		}
+
+		return key.key, nil
+	}, opts...)
+	if errors.Is(err, ErrKeysUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
+
+// Middleware verifies the bearer token of every request and puts its claims
+// into the request context. Failed requests are passed to the error handler.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			v.conf.ErrorHandler(w, r, ErrInvalidToken)
+			return
+		}
+
+		claims, err := v.Verify(r.Context(), token)
+		if err != nil {
+			v.conf.ErrorHandler(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+	})
+}
+
+// key returns the public key with the given identifier. The JWKS is fetched
+// when the cache is stale or when the key is unknown, which happens right
+// after the ID service starts using a new key. Fetches run outside the lock,
+// one at a time, and are attempted at most every minRefreshInterval, so an
+// unreachable ID service does not hold up requests.
+func (v *Verifier) key(ctx context.Context, keyID string) (publicKey, error) {
+	v.mu.Lock()
+
+	key, ok := v.keys[keyID]
+	if ok && time.Since(v.fetchedAt) < v.conf.CacheTTL {
+		v.mu.Unlock()
+		return key, nil
+	}
+
+	if v.refreshing == nil && time.Since(v.attemptedAt) < minRefreshInterval {
+		err := v.missingKeyError(keyID)
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+		return publicKey{}, err
+	}
+
+	done := v.refresh(ctx)
+	v.mu.Unlock()
+
+	// Keep serving the stale key while the keys are refetched.
+	if ok {
+		return key, nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return publicKey{}, fmt.Errorf("%w: %w", ErrKeysUnavailable, ctx.Err())
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok = v.keys[keyID]
+	if !ok {
+		return publicKey{}, v.missingKeyError(keyID)
+	}
+
+	return key, nil
+}
+
+// refresh starts fetching the JWKS unless a fetch is in flight already. It
+// returns a channel closed once the fetch is done. The caller holds v.mu.
+func (v *Verifier) refresh(ctx context.Context) <-chan struct{} {
+	if v.refreshing != nil {
+		return v.refreshing
+	}
+
+	done := make(chan struct{})
+	v.refreshing = done
+	v.attemptedAt = time.Now()
+
+	// The fetch outlives the request that started it, others may wait on it.
+	go func() {
+		keys, err := v.fetch(context.WithoutCancel(ctx))
+
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+			v.fetchedAt = time.Now()
+		}
+		v.fetchErr = err
+		v.refreshing = nil
+		v.mu.Unlock()
+
+		close(done)
+	}()
+
+	return done
+}
+
+// missingKeyError returns the error for a key that is not cached. The caller
+// holds v.mu.
+func (v *Verifier) missingKeyError(keyID string) error {
+	if v.keys == nil {
+		return fmt.Errorf("%w: %w", ErrKeysUnavailable, v.fetchErr)
+	}
+
+	return fmt.Errorf("unknown key %q", keyID)
+}
+
+func (v *Verifier) fetch(ctx context.Context) (map[string]publicKey, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, v.conf.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.conf.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwk.Set
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.PublicKey()
+		if err != nil {
+			// Keys this verifier cannot use are skipped, not fatal.
+			continue
+		}
+
+		algorithm, err := jwk.Algorithm(key)
+		if err != nil {
+			continue
+		}
+
+		keys[k.KeyID] = publicKey{
+			algorithm: algorithm,
+			key:       key,
+		}
+	}
+
+	return keys, nil
+}
+
+type contextKey string
+
+const contextClaimsKey contextKey = "ContextClaimsKey"
+
+func ContextWithClaims(ctx context.Context, claims *jwt.Claims) context.Context {
+	return context.WithValue(ctx, contextClaimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims put into the context by Middleware.
+func ClaimsFromContext(ctx context.Context) (*jwt.Claims, bool) {
+	claims, ok := ctx.Value(contextClaimsKey).(*jwt.Claims)
+	return claims, ok
+}
+
+// defaultErrorHandler writes a plain text status. Services map the errors to
+// their own API responses with Config.ErrorHandler.
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	if errors.Is(err, ErrKeysUnavailable) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
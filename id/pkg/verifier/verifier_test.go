@@ -0,0 +1,254 @@
+package verifier_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maximegorov13/chat-app/id/pkg/jwk"
+	"github.com/maximegorov13/chat-app/id/pkg/jwt"
+	"github.com/maximegorov13/chat-app/id/pkg/verifier"
+)
+
+const (
+	issuer   = "http://id"
+	audience = "chat-app"
+)
+
+type testKey struct {
+	jwt *jwt.JWT
+	jwk jwk.Key
+}
+
+func generateTestKey(t testing.TB, keyID string, opts ...jwt.Option) testKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	opts = append([]jwt.Option{jwt.WithKeyID(keyID), jwt.WithIssuer(issuer), jwt.WithAudience(audience)}, opts...)
+
+	j, err := jwt.NewJWT(
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}),
+		opts...,
+	)
+	require.NoError(t, err)
+
+	return testKey{
+		jwt: j,
+		jwk: jwk.NewRSAKey(keyID, &privateKey.PublicKey),
+	}
+}
+
+// jwksServer serves the keys currently stored in set and counts requests.
+func jwksServer(t testing.TB, set *atomic.Pointer[jwk.Set]) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, "/.well-known/jwks.json", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(set.Load()))
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requests
+}
+
+func newVerifier(ts *httptest.Server) *verifier.Verifier {
+	return verifier.NewVerifier(verifier.Config{
+		JWKSURL:  ts.URL + "/.well-known/jwks.json",
+		Issuer:   issuer,
+		Audience: audience,
+	})
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+	key := generateTestKey(t, "auth")
+
+	var set atomic.Pointer[jwk.Set]
+	set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk}})
+	ts, requests := jwksServer(t, &set)
+
+	t.Run("valid token", func(t *testing.T) {
+		v := newVerifier(ts)
+
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		claims, err := v.Verify(ctx, token)
+		require.NoError(t, err)
+		require.Equal(t, "1", claims.Subject)
+		require.Equal(t, "session", claims.SessionID)
+
+		before := requests.Load()
+		_, err = v.Verify(ctx, token)
+		require.NoError(t, err)
+		require.Equal(t, before, requests.Load(), "keys should be cached")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", -time.Hour)
+		require.NoError(t, err)
+
+		_, err = newVerifier(ts).Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrInvalidToken)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		other := generateTestKey(t, "auth", jwt.WithIssuer("http://other"))
+		set.Store(&jwk.Set{Keys: []jwk.Key{other.jwk}})
+		t.Cleanup(func() {
+			set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk}})
+		})
+
+		token, err := other.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		_, err = newVerifier(ts).Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrInvalidToken)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		other := generateTestKey(t, "auth", jwt.WithAudience("other"))
+		set.Store(&jwk.Set{Keys: []jwk.Key{other.jwk}})
+		t.Cleanup(func() {
+			set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk}})
+		})
+
+		token, err := other.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		_, err = newVerifier(ts).Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrInvalidToken)
+	})
+
+	t.Run("wrong signature", func(t *testing.T) {
+		forged := generateTestKey(t, "auth")
+
+		token, err := forged.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		_, err = newVerifier(ts).Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrInvalidToken)
+	})
+
+	t.Run("unknown key refetches keys", func(t *testing.T) {
+		v := newVerifier(ts)
+
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, token)
+		require.NoError(t, err)
+
+		rotated := generateTestKey(t, "rotated")
+		set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk, rotated.jwk}})
+		t.Cleanup(func() {
+			set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk}})
+		})
+
+		token, err = rotated.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		// The first fetch was just made, so the unknown key is not refetched
+		// right away.
+		_, err = v.Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrInvalidToken)
+
+		_, err = newVerifier(ts).Verify(ctx, token)
+		require.NoError(t, err)
+	})
+
+	t.Run("keys unavailable", func(t *testing.T) {
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		v := verifier.NewVerifier(verifier.Config{
+			JWKSURL: "http://127.0.0.1:0/.well-known/jwks.json",
+		})
+
+		_, err = v.Verify(ctx, token)
+		require.ErrorIs(t, err, verifier.ErrKeysUnavailable)
+	})
+
+	t.Run("backs off while keys are unavailable", func(t *testing.T) {
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		var failures atomic.Int32
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failures.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(down.Close)
+
+		v := newVerifier(down)
+
+		for range 5 {
+			_, err = v.Verify(ctx, token)
+			require.ErrorIs(t, err, verifier.ErrKeysUnavailable)
+		}
+		require.Equal(t, int32(1), failures.Load())
+	})
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	key := generateTestKey(t, "auth")
+
+	var set atomic.Pointer[jwk.Set]
+	set.Store(&jwk.Set{Keys: []jwk.Key{key.jwk}})
+	ts, _ := jwksServer(t, &set)
+
+	handler := newVerifier(ts).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := verifier.ClaimsFromContext(r.Context())
+		require.True(t, ok)
+		w.Write([]byte(claims.Subject))
+	}))
+
+	t.Run("valid token", func(t *testing.T) {
+		token, err := key.jwt.GenerateToken(1, "session", "login", "name", time.Hour)
+		require.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "1", w.Body.String())
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer invalid")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}